@@ -8,6 +8,7 @@ var allAuditFunctions = []interface{}{
 	auditAllowPrivilegeEscalation, auditReadOnlyRootFS, auditRunAsNonRoot,
 	auditAutomountServiceAccountToken, auditPrivileged, auditCapabilities,
 	auditLimits, auditImages, auditMountDockerSock, auditAppArmor, auditSeccomp, auditNetworkPolicies, auditNamespaces,
+	auditHostPaths, auditIDs,
 }
 
 var auditAllCmd = &cobra.Command{
@@ -11,6 +11,14 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+type autofixFlags struct {
+	podSecurityContext bool
+	pinDigests         bool
+	limits             bool
+}
+
+var autofixConfig autofixFlags
+
 // The fix function does not preserve comments (because kubernetes resources do not support comments) so we convert
 // both the original manifest file and the fixed manifest file into MapSlices (an array representation of a map which
 // preserves the order of the keys) using the Shopify/yaml fork of go-yaml/yaml (the fork adds comment support) and
@@ -112,11 +120,30 @@ var autofixCmd = &cobra.Command{
 	Short: "Automagically fixes a manifest to be secure",
 	Long: `"autofix" will examine a manifest file and automagically fill in the blanks to leave your yaml file more secure than it found it
 
+With --pod-security-context, settings which can be set for the whole pod, such
+as runAsNonRoot, are set once in the pod security context instead of in every
+container which doesn't set them.
+
+With --pin-digests, the image of every container is pinned to the digest its
+tag points to, e.g. nginx:1.17 becomes nginx:1.17@sha256:..., by asking the
+registry. Credentials for private registries are read from the docker config
+file.
+
+With --limits, the requests and limits containers don't set are filled in from
+the defaults of the LimitRange of their namespace, when the manifest has one, or
+else from the defaults of the limits section of the config file.
+
 Example usage:
-kubeaudit autofix -f /path/to/yaml`,
+kubeaudit autofix -f /path/to/yaml
+kubeaudit autofix --pod-security-context -f /path/to/yaml
+kubeaudit autofix --pin-digests -f /path/to/yaml
+kubeaudit autofix --limits -k config.yaml -f /path/to/yaml`,
 	Run: autofix,
 }
 
 func init() {
 	RootCmd.AddCommand(autofixCmd)
+	autofixCmd.Flags().BoolVar(&autofixConfig.podSecurityContext, "pod-security-context", false, "Set hardened defaults in the pod security context instead of in each container")
+	autofixCmd.Flags().BoolVar(&autofixConfig.pinDigests, "pin-digests", false, "Pin container images to the digest of their tag")
+	autofixCmd.Flags().BoolVar(&autofixConfig.limits, "limits", false, "Fill in missing resource requests and limits from the namespace LimitRange or the config defaults")
 }
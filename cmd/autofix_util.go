@@ -16,7 +16,8 @@ func getAuditFunctions() []interface{} {
 	return []interface{}{
 		auditAllowPrivilegeEscalation, auditReadOnlyRootFS, auditRunAsNonRoot,
 		auditAutomountServiceAccountToken, auditPrivileged, auditCapabilities,
-		auditAppArmor, auditSeccomp, auditNetworkPolicies, auditNamespaces,
+		auditAppArmor, auditSeccomp, auditNetworkPolicies, auditNamespaces, auditHostPaths,
+		auditIDs,
 	}
 }
 
@@ -50,6 +51,10 @@ func fixPotentialSecurityIssue(resource Resource, result Result) Resource {
 			resource = fixNetworkPolicy(resource, occurrence)
 		case ErrorNamespaceHostIPCTrue, ErrorNamespaceHostNetworkTrue, ErrorNamespaceHostPIDTrue:
 			resource = fixNamespace(&result, resource)
+		case ErrorHostPathSensitive:
+			resource = fixHostPath(&result, resource, occurrence)
+		case ErrorIDRoot, ErrorIDShared, ErrorIDOutOfRange, ErrorRunAsNonRootContradiction:
+			resource = fixID(&result, resource, occurrence)
 		}
 	}
 	return resource
@@ -60,7 +65,8 @@ func prepareResourceForFix(resource Resource, result Result) Resource {
 		ErrorPrivilegedNil, ErrorPrivilegedTrue, ErrorReadOnlyRootFilesystemFalse, ErrorReadOnlyRootFilesystemNil,
 		ErrorRunAsNonRootPSCTrueFalseCSCFalse, ErrorRunAsNonRootPSCNilCSCNil, ErrorRunAsNonRootPSCFalseCSCNil, ErrorServiceAccountTokenDeprecated,
 		ErrorAutomountServiceAccountTokenTrueAndNoName, ErrorAutomountServiceAccountTokenNilAndNoName,
-		ErrorCapabilityNotDropped, ErrorCapabilityAdded, ErrorMisconfiguredKubeauditAllow}
+		ErrorCapabilityNotDropped, ErrorCapabilityAdded, ErrorMisconfiguredKubeauditAllow,
+		ErrorIDRoot, ErrorIDShared, ErrorIDOutOfRange, ErrorRunAsNonRootContradiction}
 	needCapabilitiesDefined := []int{ErrorCapabilityNotDropped, ErrorCapabilityAdded, ErrorMisconfiguredKubeauditAllow}
 
 	// Set of errors to fix
@@ -87,6 +93,15 @@ func prepareResourceForFix(resource Resource, result Result) Resource {
 }
 
 func fix(resources []Resource) (fixedResources []Resource, extraResources []Resource) {
+	var registry *registryClient
+	if autofixConfig.pinDigests {
+		config, err := loadDockerConfig(rootConfig.dockerConfig)
+		if err != nil {
+			log.Error(err)
+		}
+		registry = newRegistryClient(nil, config)
+	}
+
 	for _, resource := range resources {
 		if !IsSupportedResourceType(resource) {
 			fixedResources = append(fixedResources, resource)
@@ -104,6 +119,12 @@ func fix(resources []Resource) (fixedResources []Resource, extraResources []Reso
 				resource = fixPotentialSecurityIssue(resource, result)
 			}
 		}
+		if autofixConfig.limits {
+			resource = fixResourceLimits(resource)
+		}
+		if registry != nil {
+			resource = pinImageDigests(resource, registry)
+		}
 		fixedResources = append(fixedResources, resource)
 	}
 	return
@@ -641,7 +662,7 @@ func splitYamlResources(filename string, toWriteFile string) (splitDecoded [][]b
 		log.Error("File not found")
 		return
 	}
-	splitDecoded = bytes.Split(buf, []byte("---"))
+	splitDecoded = splitManifest(buf)
 	if err != nil {
 		log.Error(err)
 		return nil, false, err
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// getClustersResources lists the resources of every kubeconfig context concurrently. A cluster which can't be reached
+// is recorded in scanCoverage and the other clusters are still audited.
+func getClustersResources(ctx context.Context, contexts []string) ([]Resource, error) {
+	resourcesPerCluster := make([][]Resource, len(contexts))
+	errs := make([]error, len(contexts))
+	var wg sync.WaitGroup
+	for i, kubeContext := range contexts {
+		wg.Add(1)
+		go func(i int, kubeContext string) {
+			defer wg.Done()
+			kube, err := kubeClientForContext(kubeContext)
+			if err != nil {
+				scanCoverage.addGap(kubeContext, "", "", err.Error())
+				return
+			}
+			resourcesPerCluster[i], errs[i] = getKubeResources(ctx, kube, kubeContext)
+		}(i, kubeContext)
+	}
+	wg.Wait()
+
+	var resources []Resource
+	for i, clusterResources := range resourcesPerCluster {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		resources = append(resources, clusterResources...)
+	}
+	return resources, nil
+}
+
+func setClusterName(resources []Resource, cluster string) {
+	if cluster == "" {
+		return
+	}
+	for _, resource := range resources {
+		if meta, ok := resource.(metav1.Object); ok {
+			meta.SetClusterName(cluster)
+		}
+	}
+}
+
+func clusterNameOf(resource Resource) string {
+	if meta, ok := resource.(metav1.Object); ok {
+		return meta.GetClusterName()
+	}
+	return ""
+}
+
+// printClusterSummary logs the number of errors and warnings found in each audited cluster. Nothing is logged when
+// only the current context is audited.
+func printClusterSummary(resources []Resource, results []Result) {
+	type counts struct{ errors, warnings int }
+	perCluster := map[string]*counts{}
+	for _, resource := range resources {
+		if meta, ok := resource.(metav1.Object); ok && meta.GetClusterName() != "" {
+			perCluster[meta.GetClusterName()] = &counts{}
+		}
+	}
+	for _, result := range results {
+		clusterCounts, ok := perCluster[result.Cluster]
+		if !ok {
+			continue
+		}
+		for _, occ := range result.Occurrences {
+			switch occ.kind {
+			case Error:
+				clusterCounts.errors++
+			case Warn:
+				clusterCounts.warnings++
+			}
+		}
+	}
+
+	var clusters []string
+	for cluster := range perCluster {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+	for _, cluster := range clusters {
+		log.WithFields(log.Fields{
+			"Cluster":  cluster,
+			"Errors":   perCluster[cluster].errors,
+			"Warnings": perCluster[cluster].warnings,
+		}).Info("Cluster audit summary")
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: staging
+  cluster:
+    server: https://staging.example.com
+- name: production
+  cluster:
+    server: https://production.example.com
+contexts:
+- name: staging
+  context:
+    cluster: staging
+    user: auditor
+- name: production
+  context:
+    cluster: production
+    user: auditor
+current-context: staging
+users:
+- name: auditor
+  user:
+    token: not-a-real-token
+`
+
+func writeTestKubeConfig(t *testing.T) string {
+	file, err := ioutil.TempFile("", "kubeaudit_kubeconfig")
+	assert.Nil(t, err)
+	_, err = file.WriteString(testKubeConfig)
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+	return file.Name()
+}
+
+func TestKubeContexts(t *testing.T) {
+	kubeConfig := writeTestKubeConfig(t)
+	defer os.Remove(kubeConfig)
+	defer func() { rootConfig = rootFlags{} }()
+
+	rootConfig = rootFlags{kubeConfig: kubeConfig}
+	contexts, err := kubeContexts()
+	assert.Nil(t, err)
+	assert.Nil(t, contexts)
+
+	rootConfig = rootFlags{kubeConfig: kubeConfig, allContexts: true}
+	contexts, err = kubeContexts()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"production", "staging"}, contexts)
+
+	config, err := kubeClientConfig(TestK8sClientInCluster{}, "production")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://production.example.com", config.Host)
+}
+
+func TestUnreachableClusterIsACoverageGap(t *testing.T) {
+	kubeConfig := writeTestKubeConfig(t)
+	defer os.Remove(kubeConfig)
+	defer func() { rootConfig = rootFlags{} }()
+	rootConfig = rootFlags{kubeConfig: kubeConfig}
+	scanCoverage.reset()
+
+	resources, err := getClustersResources(context.Background(), []string{"missing"})
+	assert.Nil(t, err)
+	assert.Empty(t, resources)
+	gaps := scanCoverage.Gaps()
+	assert.Len(t, gaps, 1)
+	assert.Equal(t, "missing", gaps[0].Cluster)
+	assert.Equal(t, "", gaps[0].Kind)
+}
+
+func TestResultsAreTaggedByCluster(t *testing.T) {
+	rootConfig.namespaces = nil
+	scanCoverage.reset()
+	scanOwnership.reset()
+	var resources []Resource
+	for _, cluster := range []string{"staging", "production"} {
+		client := fakeclientset.NewSimpleClientset(
+			&NamespaceV1{ObjectMeta: ObjectMetaV1{Name: "shop"}},
+			&PodV1{ObjectMeta: ObjectMetaV1{Name: "web", Namespace: "shop"}},
+		)
+		allowAccessReviews(client, func(*authorizationv1.ResourceAttributes) bool { return true })
+		clusterResources, err := getKubeResources(context.Background(), client, cluster)
+		assert.Nil(t, err)
+		resources = append(resources, clusterResources...)
+	}
+
+	results := getResults(resources, func(resource Resource) (results []Result) {
+		result, _, _ := newResultFromResource(resource)
+		return []Result{*result}
+	})
+	assert.Len(t, results, 4)
+	var clusters []string
+	for _, result := range results {
+		clusters = append(clusters, result.Cluster+"/"+result.KubeType)
+	}
+	assert.Equal(t, []string{"production/namespace", "production/pod", "staging/namespace", "staging/pod"}, clusters)
+}
@@ -1,5 +1,15 @@
 package cmd
 
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/yaml"
+	log "github.com/sirupsen/logrus"
+)
+
 // KubeauditConfig sets up config for kubeaudit from flag `config`
 type KubeauditConfig struct {
 	APIVersion string               `yaml:"apiVersion"`
@@ -10,9 +20,18 @@ type KubeauditConfig struct {
 
 // KubeauditConfigSpec contains Config Spec
 type KubeauditConfigSpec struct {
-	Manifest     []*KubeauditConfigManifest   `yaml:"manifest"`
-	Capabilities *KubeauditConfigCapabilities `yaml:"capabilities"`
-	Overrides    *KubeauditConfigOverrides    `yaml:"overrides"`
+	Manifest        []*KubeauditConfigManifest        `yaml:"manifest"`
+	Capabilities    *KubeauditConfigCapabilities      `yaml:"capabilities"`
+	Overrides       *KubeauditConfigOverrides         `yaml:"overrides"`
+	Filters         *KubeauditConfigFilters           `yaml:"filters"`
+	Contexts        []string                          `yaml:"contexts"`
+	HostPaths       []*KubeauditConfigHostPath        `yaml:"hostPaths"`
+	IDs             *KubeauditConfigIDs               `yaml:"ids"`
+	Images          *KubeauditConfigImages            `yaml:"images"`
+	Vulnerabilities *KubeauditConfigVulnerabilities   `yaml:"vulnerabilities"`
+	Signatures      []*KubeauditConfigSignaturePolicy `yaml:"signatures"`
+	Limits          *KubeauditConfigLimits            `yaml:"limits"`
+	Secrets         *KubeauditConfigSecrets           `yaml:"secrets"`
 }
 
 // KubeauditConfigManifest contains path to the manifests to audit
@@ -39,6 +58,67 @@ type KubeauditConfigCapabilities struct {
 	SetFCAP        string `yaml:"SETFCAP"`
 }
 
+// KubeauditConfigHostPath is a sensitive host path and the severity of mounting it, which is one of error, warning,
+// info or ignore. ReadOnlySeverity applies when the volume is mounted read-only and defaults to Severity.
+type KubeauditConfigHostPath struct {
+	Path             string `yaml:"path"`
+	Severity         string `yaml:"severity"`
+	ReadOnlySeverity string `yaml:"readOnlySeverity"`
+}
+
+// KubeauditConfigIDs is the range of user and group IDs containers are allowed to run as. Bounds which aren't set
+// keep their default.
+type KubeauditConfigIDs struct {
+	MinUID int64 `yaml:"minUID"`
+	MaxUID int64 `yaml:"maxUID"`
+	MinGID int64 `yaml:"minGID"`
+	MaxGID int64 `yaml:"maxGID"`
+}
+
+// KubeauditConfigImages is the policy container images must follow. Registries are host names, optionally with a
+// port, and repositories are image names, ending with /* to allow every repository below them.
+type KubeauditConfigImages struct {
+	AllowedRegistries   []string `yaml:"allowedRegistries"`
+	AllowedRepositories []string `yaml:"allowedRepositories"`
+	ForbiddenTags       []string `yaml:"forbiddenTags"`
+	RequireDigest       bool     `yaml:"requireDigest"`
+}
+
+// KubeauditConfigVulnerabilities sets which vulnerabilities of the scan reports are reported. Severities are unknown,
+// negligible, low, medium, high or critical, and vulnerabilities are ignored by id.
+type KubeauditConfigVulnerabilities struct {
+	Reports       string   `yaml:"reports"`
+	MinSeverity   string   `yaml:"minSeverity"`
+	ErrorSeverity string   `yaml:"errorSeverity"`
+	IgnoreUnfixed bool     `yaml:"ignoreUnfixed"`
+	Ignore        []string `yaml:"ignore"`
+}
+
+// KubeauditConfigSignaturePolicy requires the images matching a pattern, such as registry.local:5000/* or
+// gcr.io/project/app, to be signed with each of the keys. Keys are PEM encoded public keys or files containing one.
+type KubeauditConfigSignaturePolicy struct {
+	Images string   `yaml:"images"`
+	Keys   []string `yaml:"keys"`
+}
+
+// KubeauditConfigLimits are the thresholds of the limits audit, keyed by resource name: cpu, memory or
+// ephemeral-storage. Max are the highest limits containers may set and maxLimitRequestRatio how many times their
+// requests they may be. The defaults are used by autofix to fill in the requests and limits containers don't set.
+type KubeauditConfigLimits struct {
+	Max                     map[string]string  `yaml:"max"`
+	MaxLimitRequestRatio    map[string]float64 `yaml:"maxLimitRequestRatio"`
+	RequireEphemeralStorage bool               `yaml:"requireEphemeralStorage"`
+	DefaultLimits           map[string]string  `yaml:"defaultLimits"`
+	DefaultRequests         map[string]string  `yaml:"defaultRequests"`
+}
+
+// KubeauditConfigSecrets sets up the secrets audit. FingerprintKey is the key of the HMAC fingerprints of findings, and
+// Ignore lists the fingerprints of findings which aren't credentials, as printed by the audit with that key.
+type KubeauditConfigSecrets struct {
+	FingerprintKey string   `yaml:"fingerprintKey"`
+	Ignore         []string `yaml:"ignore"`
+}
+
 // KubeauditConfigOverrides contains list of available overrides
 type KubeauditConfigOverrides struct {
 	PrivilegeEscalation                string `yaml:"privilege-escalation"`
@@ -51,6 +131,109 @@ type KubeauditConfigOverrides struct {
 	HostNetwork                        string `yaml:"namespace-host-network"`
 	HostPID                            string `yaml:"namespace-host-PID"`
 	HostIPC                            string `yaml:"namespace-host-IPC"`
+	WildcardVerbs                      string `yaml:"wildcard-verbs"`
+	WildcardResources                  string `yaml:"wildcard-resources"`
+	EscalationVerbs                    string `yaml:"escalation-verbs"`
+	SecretsRead                        string `yaml:"secrets-read"`
+	PodExec                            string `yaml:"pod-exec"`
+	PodCreate                          string `yaml:"pod-create"`
+	ClusterAdminBinding                string `yaml:"cluster-admin-binding"`
+	AnonymousBinding                   string `yaml:"anonymous-binding"`
+	PrivilegedServiceAccountToken      string `yaml:"privileged-service-account-token"`
+	SensitiveHostPath                  string `yaml:"sensitive-host-path"`
+	UIDGID                             string `yaml:"uid-gid"`
+	Probes                             string `yaml:"probes"`
+	SecretEnv                          string `yaml:"secret-env"`
+	PublicExposure                     string `yaml:"public-exposure"`
+}
+
+// KubeauditConfigFilters restricts which resources are audited. Flags given on the command line take precedence over
+// the config file.
+type KubeauditConfigFilters struct {
+	Namespaces        []string `yaml:"namespaces"`
+	ExcludeNamespaces []string `yaml:"excludeNamespaces"`
+	Selector          string   `yaml:"selector"`
+	Kinds             []string `yaml:"kinds"`
+}
+
+// parsedAuditConfig is the config of --auditconfig, parsed once when the flags are processed rather than by every audit
+// needing it.
+var parsedAuditConfig struct {
+	sync.Mutex
+	filename string
+	config   *KubeauditConfig
+}
+
+func parseAuditConfig(data []byte) (*KubeauditConfig, error) {
+	config := &KubeauditConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// validate checks the settings which only take some values, so that a typo doesn't silently turn off part of an
+// audit.
+func (config *KubeauditConfig) validate() error {
+	if config.Spec == nil {
+		return nil
+	}
+	for _, entry := range config.Spec.HostPaths {
+		if entry == nil {
+			continue
+		}
+		for _, severity := range []string{entry.Severity, entry.ReadOnlySeverity} {
+			if severity != "" && !containsString(hostPathSeverities, severity) {
+				return fmt.Errorf("unknown severity %q for host path %s, use one of %s", severity, entry.Path,
+					strings.Join(hostPathSeverities, ", "))
+			}
+		}
+	}
+	if vulnerabilities := config.Spec.Vulnerabilities; vulnerabilities != nil {
+		settings := []string{"minSeverity", "errorSeverity"}
+		for i, severity := range []string{vulnerabilities.MinSeverity, vulnerabilities.ErrorSeverity} {
+			if severity != "" && !isVulnerabilitySeverity(severity) {
+				return fmt.Errorf("unknown vulnerability severity %q for %s, use one of %s", severity, settings[i],
+					strings.Join(vulnerabilitySeverities, ", "))
+			}
+		}
+	}
+	if secrets := config.Spec.Secrets; secrets != nil && len(secrets.Ignore) > 0 && secrets.FingerprintKey == "" {
+		return fmt.Errorf("secrets.ignore requires secrets.fingerprintKey, without it fingerprints change on every run")
+	}
+	return nil
+}
+
+func setAuditConfig(filename string, config *KubeauditConfig) {
+	parsedAuditConfig.Lock()
+	defer parsedAuditConfig.Unlock()
+	parsedAuditConfig.filename, parsedAuditConfig.config = filename, config
+}
+
+// auditConfigSpec returns the spec of the config given with --auditconfig, which is empty when there is no config or
+// it can't be read. A config which isn't the one parsed when the flags were processed is parsed on first use.
+func auditConfigSpec() KubeauditConfigSpec {
+	parsedAuditConfig.Lock()
+	defer parsedAuditConfig.Unlock()
+	if rootConfig.auditConfig == "" {
+		return KubeauditConfigSpec{}
+	}
+	if parsedAuditConfig.config == nil || parsedAuditConfig.filename != rootConfig.auditConfig {
+		config := &KubeauditConfig{}
+		if data, err := ioutil.ReadFile(rootConfig.auditConfig); err == nil {
+			if config, err = parseAuditConfig(data); err != nil {
+				log.Fatalf("Unable to use auditConfig file %s: %v", rootConfig.auditConfig, err)
+			}
+		}
+		parsedAuditConfig.filename, parsedAuditConfig.config = rootConfig.auditConfig, config
+	}
+	if parsedAuditConfig.config.Spec == nil {
+		return KubeauditConfigSpec{}
+	}
+	return *parsedAuditConfig.config.Spec
 }
 
 func mapOverridesToStructFields(label string) string {
@@ -75,6 +258,34 @@ func mapOverridesToStructFields(label string) string {
 		return "HostIPC"
 	case "allow-namespace-host-PID":
 		return "HostPID"
+	case "allow-wildcard-verbs":
+		return "WildcardVerbs"
+	case "allow-wildcard-resources":
+		return "WildcardResources"
+	case "allow-escalation-verbs":
+		return "EscalationVerbs"
+	case "allow-secrets-read":
+		return "SecretsRead"
+	case "allow-pod-exec":
+		return "PodExec"
+	case "allow-pod-create":
+		return "PodCreate"
+	case "allow-cluster-admin-binding":
+		return "ClusterAdminBinding"
+	case "allow-anonymous-binding":
+		return "AnonymousBinding"
+	case "allow-privileged-service-account-token":
+		return "PrivilegedServiceAccountToken"
+	case "allow-sensitive-host-path":
+		return "SensitiveHostPath"
+	case "allow-uid-gid":
+		return "UIDGID"
+	case "allow-probes":
+		return "Probes"
+	case "allow-secret-env":
+		return "SecretEnv"
+	case "allow-public-exposure":
+		return "PublicExposure"
 	}
 	return ""
 }
@@ -1,11 +1,57 @@
 package cmd
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMapOverridesToStructFields(t *testing.T) {
 	assert.Equal(t, "", mapOverridesToStructFields("something-random"))
 }
+
+func TestAuditConfigSpecParsedOnce(t *testing.T) {
+	file, err := ioutil.TempFile("", "kubeaudit_config")
+	require.Nil(t, err)
+	_, err = file.WriteString("apiVersion: v1\nkind: kubeauditConfig\nspec:\n  ids:\n    minUID: 2000\n")
+	require.Nil(t, err)
+	file.Close()
+
+	rootConfig.auditConfig = file.Name()
+	defer func() { rootConfig.auditConfig = "" }()
+	assert.Equal(t, int64(2000), auditConfigSpec().IDs.MinUID)
+	// The config isn't read again once parsed
+	require.Nil(t, os.Remove(file.Name()))
+	assert.Equal(t, int64(2000), auditConfigSpec().IDs.MinUID)
+
+	rootConfig.auditConfig = ""
+	assert.Nil(t, auditConfigSpec().IDs)
+}
+
+func TestParseAuditConfigHostPathSeverities(t *testing.T) {
+	_, err := parseAuditConfig([]byte("spec:\n  hostPaths:\n  - path: /srv\n    severity: warning\n    readOnlySeverity: ignore\n"))
+	assert.Nil(t, err)
+	_, err = parseAuditConfig([]byte("spec:\n  hostPaths:\n  - path: /srv\n    severity: warn\n"))
+	assert.EqualError(t, err, `unknown severity "warn" for host path /srv, use one of error, warning, info, ignore`)
+	_, err = parseAuditConfig([]byte("spec:\n  hostPaths:\n  - path: /srv\n    readOnlySeverity: high\n"))
+	assert.NotNil(t, err)
+}
+
+func TestParseAuditConfigVulnerabilitySeverities(t *testing.T) {
+	_, err := parseAuditConfig([]byte("spec:\n  vulnerabilities:\n    minSeverity: Low\n    errorSeverity: CRITICAL\n"))
+	assert.Nil(t, err)
+	_, err = parseAuditConfig([]byte("spec:\n  vulnerabilities:\n    minSeverity: moderate\n"))
+	assert.EqualError(t, err, `unknown vulnerability severity "moderate" for minSeverity, use one of unknown, negligible, low, medium, high, critical`)
+	_, err = parseAuditConfig([]byte("spec:\n  vulnerabilities:\n    errorSeverity: crit\n"))
+	assert.NotNil(t, err)
+}
+
+func TestParseAuditConfigSecretsFingerprintKey(t *testing.T) {
+	_, err := parseAuditConfig([]byte("spec:\n  secrets:\n    fingerprintKey: key\n    ignore: [hmac-sha256:0123456789ab]\n"))
+	assert.Nil(t, err)
+	_, err = parseAuditConfig([]byte("spec:\n  secrets:\n    ignore: [hmac-sha256:0123456789ab]\n"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"os"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ExitCodeIncompleteScan is the exit status used when kubeaudit could not read every resource it needed, so a report
+// without findings cannot be trusted to mean the cluster is clean.
+const ExitCodeIncompleteScan = 2
+
+// CoverageGap describes a kind, and optionally a namespace, that kubeaudit was unable to read from the cluster. A gap
+// without a kind means the whole cluster couldn't be reached.
+type CoverageGap struct {
+	Cluster   string
+	Kind      string
+	Namespace string
+	Reason    string
+}
+
+// Coverage collects the parts of the cluster that could not be audited. It is safe for concurrent use.
+type Coverage struct {
+	mu   sync.Mutex
+	gaps []CoverageGap
+}
+
+var scanCoverage Coverage
+
+func (c *Coverage) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gaps = nil
+}
+
+func (c *Coverage) addGap(cluster, kind, namespace, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gaps = append(c.gaps, CoverageGap{Cluster: cluster, Kind: kind, Namespace: namespace, Reason: reason})
+}
+
+// Gaps returns the recorded gaps sorted by cluster, kind and namespace.
+func (c *Coverage) Gaps() []CoverageGap {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	gaps := append([]CoverageGap{}, c.gaps...)
+	sort.SliceStable(gaps, func(i, j int) bool {
+		if gaps[i].Cluster != gaps[j].Cluster {
+			return gaps[i].Cluster < gaps[j].Cluster
+		}
+		if gaps[i].Kind != gaps[j].Kind {
+			return gaps[i].Kind < gaps[j].Kind
+		}
+		return gaps[i].Namespace < gaps[j].Namespace
+	})
+	return gaps
+}
+
+// Complete returns true if every resource needed by the audit could be read.
+func (c *Coverage) Complete() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.gaps) == 0
+}
+
+// Print logs the coverage section of the report.
+func (c *Coverage) Print() {
+	for _, gap := range c.Gaps() {
+		fields := log.Fields{"Coverage": "incomplete", "Reason": gap.Reason}
+		if gap.Cluster != "" {
+			fields["Cluster"] = gap.Cluster
+		}
+		if gap.Kind != "" {
+			fields["Kind"] = gap.Kind
+		}
+		if gap.Namespace != apiv1.NamespaceAll {
+			fields["Namespace"] = gap.Namespace
+		}
+		log.WithFields(fields).Error("Unable to read resources, the audit results for them are missing")
+	}
+}
+
+// abortIncompleteScan records why the scan stopped before everything was audited, such as an expired --timeout or an
+// interrupt, prints the coverage and exits with ExitCodeIncompleteScan so that the partial scan doesn't pass for a clean
+// one.
+func abortIncompleteScan(err error) {
+	scanCoverage.addGap("", "", "", err.Error())
+	scanCoverage.Print()
+	os.Exit(ExitCodeIncompleteScan)
+}
+
+// canList asks the API server, through a SelfSubjectAccessReview, whether the current user may list the resource in
+// the namespace. An empty namespace means all namespaces. If the review itself fails kubeaudit assumes access is
+// allowed and lets the List call report the actual error.
+func canList(clientset kubernetes.Interface, group, resource, namespace string) (allowed bool, reason string) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "list",
+				Group:     group,
+				Resource:  resource,
+			},
+		},
+	}
+	response, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(review)
+	if err != nil {
+		log.Debugf("Unable to review access to %s: %v", resource, err)
+		return true, ""
+	}
+	if response.Status.Allowed {
+		return true, ""
+	}
+	reason = response.Status.Reason
+	if reason == "" {
+		reason = "list " + resource + " is not allowed for the current user"
+	}
+	return false, reason
+}
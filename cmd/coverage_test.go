@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8sRuntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// allowAccessReviews answers SelfSubjectAccessReviews on the fake clientset using the allowed function.
+func allowAccessReviews(client *fakeclientset.Clientset, allowed func(*authorizationv1.ResourceAttributes) bool) {
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, k8sRuntime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = allowed(review.Spec.ResourceAttributes)
+		return true, review, nil
+	})
+}
+
+func newCoverageTestClient() *fakeclientset.Clientset {
+	return fakeclientset.NewSimpleClientset(
+		&NamespaceV1{ObjectMeta: ObjectMetaV1{Name: "ns1"}},
+		&NamespaceV1{ObjectMeta: ObjectMetaV1{Name: "ns2"}},
+		&PodV1{ObjectMeta: ObjectMetaV1{Name: "pod1", Namespace: "ns1"}},
+		&PodV1{ObjectMeta: ObjectMetaV1{Name: "pod2", Namespace: "ns2"}},
+		&DeploymentV1{ObjectMeta: ObjectMetaV1{Name: "deployment1", Namespace: "ns2"}},
+	)
+}
+
+func TestCoverageFallsBackToAllowedNamespaces(t *testing.T) {
+	rootConfig.namespaces = nil
+	client := newCoverageTestClient()
+	allowAccessReviews(client, func(attributes *authorizationv1.ResourceAttributes) bool {
+		return attributes.Resource != "pods" || attributes.Namespace == "ns1"
+	})
+	scanCoverage.reset()
+
+	resources, err := getKubeResources(context.Background(), client, "")
+	assert.Nil(t, err)
+	// 2 namespaces, 1 deployment and only the pod from ns1
+	assert.Len(t, resources, 4)
+	assert.False(t, scanCoverage.Complete())
+	gaps := scanCoverage.Gaps()
+	assert.Len(t, gaps, 1)
+	assert.Equal(t, "Pod", gaps[0].Kind)
+	assert.Equal(t, "ns2", gaps[0].Namespace)
+}
+
+func TestCoverageRecordsDeniedKinds(t *testing.T) {
+	rootConfig.namespaces = nil
+	client := newCoverageTestClient()
+	allowAccessReviews(client, func(attributes *authorizationv1.ResourceAttributes) bool {
+		return attributes.Resource != "namespaces" && attributes.Resource != "deployments"
+	})
+	scanCoverage.reset()
+
+	resources, err := getKubeResources(context.Background(), client, "")
+	assert.Nil(t, err)
+	assert.Len(t, resources, 2)
+	gaps := scanCoverage.Gaps()
+	assert.Len(t, gaps, 2)
+	assert.Equal(t, "Deployment", gaps[0].Kind)
+	assert.Equal(t, "Namespace", gaps[1].Kind)
+}
+
+func TestCoverageRecordsListErrors(t *testing.T) {
+	rootConfig.namespaces = nil
+	client := newCoverageTestClient()
+	allowAccessReviews(client, func(attributes *authorizationv1.ResourceAttributes) bool { return true })
+	client.PrependReactor("list", "statefulsets", func(action k8stesting.Action) (bool, k8sRuntime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "statefulsets"}, "", nil)
+	})
+	scanCoverage.reset()
+
+	resources, err := getKubeResources(context.Background(), client, "")
+	assert.Nil(t, err)
+	assert.Len(t, resources, 5)
+	gaps := scanCoverage.Gaps()
+	assert.Len(t, gaps, 1)
+	assert.Equal(t, "StatefulSet", gaps[0].Kind)
+	assert.Contains(t, gaps[0].Reason, "forbidden")
+}
+
+func TestCoverageGapsSorted(t *testing.T) {
+	var coverage Coverage
+	assert.True(t, coverage.Complete())
+	coverage.addGap("", "Pod", "ns2", "denied")
+	coverage.addGap("", "Deployment", "", "denied")
+	coverage.addGap("", "Pod", "ns1", "denied")
+	gaps := coverage.Gaps()
+	assert.False(t, coverage.Complete())
+	assert.Equal(t, []CoverageGap{
+		{Kind: "Deployment", Reason: "denied"},
+		{Kind: "Pod", Namespace: "ns1", Reason: "denied"},
+		{Kind: "Pod", Namespace: "ns2", Reason: "denied"},
+	}, gaps)
+	coverage.reset()
+	assert.True(t, coverage.Complete())
+}
@@ -115,4 +115,170 @@ const (
 	WarningAllowAllIngressNetworkPolicyExists
 	// WarningAllowAllEgressNetworkPolicyExists occurs when a namespace has an allow all egress NetworkPolicy
 	WarningAllowAllEgressNetworkPolicyExists
+	// ErrorRBACWildcardVerbs occurs when a role grants every verb with a wildcard.
+	ErrorRBACWildcardVerbs
+	// ErrorRBACWildcardVerbsAllowed occurs when a role grants every verb with a wildcard but it's allowed.
+	ErrorRBACWildcardVerbsAllowed
+	// ErrorRBACWildcardResources occurs when a role grants access to every resource with a wildcard.
+	ErrorRBACWildcardResources
+	// ErrorRBACWildcardResourcesAllowed occurs when a role grants access to every resource with a wildcard but it's
+	// allowed.
+	ErrorRBACWildcardResourcesAllowed
+	// ErrorRBACEscalationVerbs occurs when a role grants the escalate, bind or impersonate verbs.
+	ErrorRBACEscalationVerbs
+	// ErrorRBACEscalationVerbsAllowed occurs when a role grants the escalate, bind or impersonate verbs but it's
+	// allowed.
+	ErrorRBACEscalationVerbsAllowed
+	// ErrorRBACSecretsRead occurs when a role grants read access to secrets.
+	ErrorRBACSecretsRead
+	// ErrorRBACSecretsReadAllowed occurs when a role grants read access to secrets but it's allowed.
+	ErrorRBACSecretsReadAllowed
+	// ErrorRBACPodExec occurs when a role allows executing commands in or attaching to pods.
+	ErrorRBACPodExec
+	// ErrorRBACPodExecAllowed occurs when a role allows executing commands in or attaching to pods but it's allowed.
+	ErrorRBACPodExecAllowed
+	// ErrorRBACPodCreate occurs when a role allows creating pods, directly or through a workload controller.
+	ErrorRBACPodCreate
+	// ErrorRBACPodCreateAllowed occurs when a role allows creating pods but it's allowed.
+	ErrorRBACPodCreateAllowed
+	// ErrorRBACClusterAdminBinding occurs when a binding grants the cluster-admin ClusterRole.
+	ErrorRBACClusterAdminBinding
+	// ErrorRBACClusterAdminBindingAllowed occurs when a binding grants the cluster-admin ClusterRole but it's allowed.
+	ErrorRBACClusterAdminBindingAllowed
+	// ErrorRBACAnonymousBinding occurs when a binding grants a role to system:anonymous or system:unauthenticated.
+	ErrorRBACAnonymousBinding
+	// ErrorRBACAnonymousBindingAllowed occurs when a binding grants a role to system:anonymous or
+	// system:unauthenticated but it's allowed.
+	ErrorRBACAnonymousBindingAllowed
+	// ErrorServiceAccountTokenPrivileged occurs when a pod mounts the token of a service account which is granted
+	// dangerous permissions.
+	ErrorServiceAccountTokenPrivileged
+	// ErrorServiceAccountTokenPrivilegedAllowed occurs when a pod mounts the token of a service account which is
+	// granted dangerous permissions but it's allowed.
+	ErrorServiceAccountTokenPrivilegedAllowed
+	// ErrorHostPathSensitive occurs when a container mounts a hostPath volume exposing a sensitive path of the host.
+	ErrorHostPathSensitive
+	// ErrorHostPathSensitiveAllowed occurs when a container mounts a hostPath volume exposing a sensitive path of the
+	// host but it's allowed.
+	ErrorHostPathSensitiveAllowed
+	// ErrorIDRoot occurs when runAsUser, runAsGroup, fsGroup or one of the supplementalGroups is 0.
+	ErrorIDRoot
+	// ErrorIDShared occurs when a user or group ID is the one of nobody, which is shared by many workloads.
+	ErrorIDShared
+	// ErrorIDOutOfRange occurs when a user or group ID is out of the allowed range.
+	ErrorIDOutOfRange
+	// ErrorIDAllowed occurs when a user or group ID doesn't follow the policy but it's allowed.
+	ErrorIDAllowed
+	// ErrorRunAsNonRootContradiction occurs when runAsUser is 0 while runAsNonRoot is true, which keeps the container
+	// from starting.
+	ErrorRunAsNonRootContradiction
+	// ErrorImageReferenceInvalid occurs when an image reference can't be parsed.
+	ErrorImageReferenceInvalid
+	// ErrorImageRegistryNotAllowed occurs when an image isn't from one of the allowed registries or repositories.
+	ErrorImageRegistryNotAllowed
+	// ErrorImageTagForbidden occurs when an image uses a forbidden tag such as latest.
+	ErrorImageTagForbidden
+	// ErrorImageDigestMissing occurs when an image isn't pinned to a digest but the policy requires it.
+	ErrorImageDigestMissing
+	// ErrorImagePullPolicyInconsistent occurs when the imagePullPolicy doesn't suit the image reference.
+	ErrorImagePullPolicyInconsistent
+	// ErrorImageVulnerable occurs when the scan report of an image lists a vulnerability.
+	ErrorImageVulnerable
+	// ErrorImageNotScanned occurs when there is no scan report for an image.
+	ErrorImageNotScanned
+	// ErrorImageUnsigned occurs when an image which must be signed has no signature.
+	ErrorImageUnsigned
+	// ErrorImageSignatureInvalid occurs when an image has no valid signature made with one of the required keys.
+	ErrorImageSignatureInvalid
+	// ErrorImageSignatureUnverified occurs when the signatures of an image can't be fetched from the registry.
+	ErrorImageSignatureUnverified
+	// InfoImageSignatureVerified occurs when an image has a valid signature made with each of the required keys.
+	InfoImageSignatureVerified
+	// ErrorImageRunsAsRoot occurs when the image of a container which doesn't set runAsUser runs as root.
+	ErrorImageRunsAsRoot
+	// ErrorImageUserNotNumeric occurs when the image of a container which doesn't set runAsUser runs as a named user, so
+	// whether it runs as root can't be told.
+	ErrorImageUserNotNumeric
+	// InfoImageRunsAsNonRoot occurs when the image of a container which doesn't set runAsUser runs as another user than
+	// root.
+	InfoImageRunsAsNonRoot
+	// ErrorImageSetuidFiles occurs when the image of a container allowed to escalate privileges has setuid or setgid
+	// binaries.
+	ErrorImageSetuidFiles
+	// ErrorImageInspectionFailed occurs when the config of an image can't be read.
+	ErrorImageInspectionFailed
+	// ErrorResourcesRequestsNil occurs when a container sets neither resource requests nor limits.
+	ErrorResourcesRequestsNil
+	// ErrorResourcesRequestsCPUNil occurs when a container sets neither a CPU request nor a CPU limit.
+	ErrorResourcesRequestsCPUNil
+	// ErrorResourcesRequestsMemoryNil occurs when a container sets neither a memory request nor a memory limit.
+	ErrorResourcesRequestsMemoryNil
+	// ErrorResourcesRequestExceedsLimit occurs when a resource request is higher than its limit.
+	ErrorResourcesRequestExceedsLimit
+	// ErrorResourcesLimitRequestRatioExceeded occurs when a limit is more times its request than allowed.
+	ErrorResourcesLimitRequestRatioExceeded
+	// ErrorResourcesLimitsEphemeralStorageNil occurs when the ephemeral storage limit is required but not set.
+	ErrorResourcesLimitsEphemeralStorageNil
+	// ErrorResourcesLimitsEphemeralStorageExceeded occurs when the ephemeral storage limit is exceeded.
+	ErrorResourcesLimitsEphemeralStorageExceeded
+	// ErrorNamespaceLimitRangeMissing occurs when a namespace has no LimitRange.
+	ErrorNamespaceLimitRangeMissing
+	// ErrorNamespaceResourceQuotaMissing occurs when a namespace has no ResourceQuota.
+	ErrorNamespaceResourceQuotaMissing
+	// ErrorProbeReadinessNil occurs when a container of a long-running workload has no readiness probe.
+	ErrorProbeReadinessNil
+	// ErrorProbeLivenessNil occurs when a container of a long-running workload has no liveness probe.
+	ErrorProbeLivenessNil
+	// ErrorProbeSameEndpoint occurs when the liveness probe hits the same endpoint as the readiness probe and fails as
+	// fast.
+	ErrorProbeSameEndpoint
+	// ErrorProbeExecShell occurs when an exec probe runs its command through a shell.
+	ErrorProbeExecShell
+	// ErrorProbesAllowed occurs when probes don't follow the policy but it's allowed.
+	ErrorProbesAllowed
+	// ErrorSecretLiteral occurs when an env value, arg or command of a container holds a probable credential.
+	ErrorSecretLiteral
+	// ErrorSecretInConfigMap occurs when the data of a ConfigMap holds a probable credential.
+	ErrorSecretInConfigMap
+	// ErrorSecretEnv occurs when a container consumes a Secret through environment variables instead of a volume.
+	ErrorSecretEnv
+	// ErrorSecretEnvAllowed occurs when a container consumes a Secret through environment variables but it's allowed.
+	ErrorSecretEnvAllowed
+	// ErrorServiceNodePort occurs when a Service opens a port on every node.
+	ErrorServiceNodePort
+	// ErrorServiceLoadBalancerSourceRangesNil occurs when a public LoadBalancer Service accepts traffic from any
+	// address.
+	ErrorServiceLoadBalancerSourceRangesNil
+	// ErrorServicePublicExposureAllowed occurs when a Service is exposed outside of the cluster but it's allowed.
+	ErrorServicePublicExposureAllowed
+	// ErrorServiceExternalIPs occurs when a Service sets externalIPs.
+	ErrorServiceExternalIPs
+	// ErrorServiceExternalTrafficPolicyCluster occurs when an exposed Service hides the address of its clients.
+	ErrorServiceExternalTrafficPolicyCluster
+	// ErrorServiceHostNetwork occurs when a Service selects pods which use the network of their node.
+	ErrorServiceHostNetwork
+	// ErrorIngressTLSNil occurs when an Ingress serves a host without TLS.
+	ErrorIngressTLSNil
+	// ErrorIngressWildcardHost occurs when an Ingress serves every host name, or every name of a domain.
+	ErrorIngressWildcardHost
+	// ErrorIngressSnippetAnnotation occurs when an Ingress injects raw configuration into its controller.
+	ErrorIngressSnippetAnnotation
+	// ErrorNetworkPolicyIngressUnselected occurs when a workload isn't selected by any ingress NetworkPolicy of its
+	// namespace.
+	ErrorNetworkPolicyIngressUnselected
+	// ErrorNetworkPolicyEgressUnselected occurs when a workload isn't selected by any egress NetworkPolicy of its
+	// namespace.
+	ErrorNetworkPolicyEgressUnselected
+	// ErrorNetworkPolicySelectsNothing occurs when the podSelector of a NetworkPolicy selects no workload.
+	ErrorNetworkPolicySelectsNothing
+	// ErrorNetworkPolicyAllNamespaces occurs when a NetworkPolicy rule allows traffic with every namespace.
+	ErrorNetworkPolicyAllNamespaces
+	// ErrorNetworkPolicyAllAddresses occurs when a NetworkPolicy rule allows traffic with every address.
+	ErrorNetworkPolicyAllAddresses
+	// ErrorNetworkPolicyAllowAllOverridesDefaultDeny occurs when a NetworkPolicy allows all traffic of a workload in a
+	// namespace with a default deny NetworkPolicy.
+	ErrorNetworkPolicyAllowAllOverridesDefaultDeny
+	// ErrorHostPathSensitiveUnmounted occurs when a pod has a hostPath volume exposing a sensitive path of the host
+	// which none of its containers mounts.
+	ErrorHostPathSensitiveUnmounted
 )
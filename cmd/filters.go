@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func applyConfigFilters(filters *KubeauditConfigFilters) {
+	if filters == nil {
+		return
+	}
+	if len(rootConfig.namespaces) == 0 {
+		rootConfig.namespaces = filters.Namespaces
+	}
+	if len(rootConfig.excludeNamespaces) == 0 {
+		rootConfig.excludeNamespaces = filters.ExcludeNamespaces
+	}
+	if rootConfig.selector == "" {
+		rootConfig.selector = filters.Selector
+	}
+	if len(rootConfig.kinds) == 0 {
+		rootConfig.kinds = filters.Kinds
+	}
+}
+
+func validateFilters() {
+	if _, err := labels.Parse(rootConfig.selector); err != nil {
+		log.Fatalf("Invalid label selector %q: %v", rootConfig.selector, err)
+	}
+}
+
+// kindPlurals maps the plurals which aren't the kind followed by an s to their kind.
+var kindPlurals = map[string]string{
+	"ingresses":       "ingress",
+	"networkpolicies": "networkpolicy",
+}
+
+// normalizeKind makes "Deployment", "deployment" and "deployments" equivalent.
+func normalizeKind(kind string) string {
+	lower := strings.ToLower(kind)
+	if singular, ok := kindPlurals[lower]; ok {
+		return singular
+	}
+	if strings.HasSuffix(lower, "ss") {
+		return lower
+	}
+	return strings.TrimSuffix(lower, "s")
+}
+
+func isAuditedKind(kind string) bool {
+	if len(rootConfig.kinds) == 0 {
+		return true
+	}
+	for _, auditedKind := range rootConfig.kinds {
+		if normalizeKind(auditedKind) == normalizeKind(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAuditedNamespace(namespace string) bool {
+	for _, excluded := range rootConfig.excludeNamespaces {
+		if excluded == namespace {
+			return false
+		}
+	}
+	if len(rootConfig.namespaces) == 0 {
+		return true
+	}
+	for _, included := range rootConfig.namespaces {
+		if included == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesSelector(objectLabels map[string]string) bool {
+	if rootConfig.selector == "" {
+		return true
+	}
+	selector, err := labels.Parse(rootConfig.selector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(objectLabels))
+}
+
+// isAudited returns true if the resource passes the namespace, kind and label selector filters. Namespaces are
+// filtered by their own name and are not subject to the label selector, which only applies to workloads. Cluster
+// scoped RBAC resources are only filtered by the label selector.
+func isAudited(resource Resource) bool {
+	meta, ok := resource.(metav1.Object)
+	if !ok {
+		return true
+	}
+	if !isAuditedKind(kindOf(resource)) {
+		return false
+	}
+	if IsNamespaceType(resource) {
+		return isAuditedNamespace(meta.GetName())
+	}
+	switch resource.(type) {
+	case *ClusterRoleV1, *ClusterRoleBindingV1:
+		return matchesSelector(meta.GetLabels())
+	}
+	return isAuditedNamespace(meta.GetNamespace()) && matchesSelector(meta.GetLabels())
+}
+
+func filterResources(resources []Resource) (filtered []Resource) {
+	for _, resource := range resources {
+		if isAudited(resource) {
+			filtered = append(filtered, resource)
+		}
+	}
+	return filtered
+}
+
+// auditedNamespaceScopes returns the namespaces to list namespaced kinds in. An empty namespace means all namespaces,
+// in which case excluded namespaces are filtered out after listing.
+func auditedNamespaceScopes() (scopes []string) {
+	if len(rootConfig.namespaces) == 0 {
+		return []string{metav1.NamespaceAll}
+	}
+	for _, namespace := range rootConfig.namespaces {
+		if isAuditedNamespace(namespace) {
+			scopes = append(scopes, namespace)
+		}
+	}
+	return scopes
+}
+
+func workloadListOptions() ListOptionsV1 {
+	return ListOptionsV1{LabelSelector: rootConfig.selector}
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func resetFilters() {
+	rootConfig.namespaces = nil
+	rootConfig.excludeNamespaces = nil
+	rootConfig.selector = ""
+	rootConfig.kinds = nil
+}
+
+func resourceNames(resources []Resource) (names []string) {
+	for _, resource := range resources {
+		names = append(names, kindOf(resource)+"/"+resource.(metav1.Object).GetName())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func filteredManifestNames(t *testing.T) []string {
+	resources, err := getKubeResourcesManifest("../fixtures/filters_v1.yml")
+	assert.Nil(t, err)
+	return resourceNames(filterResources(resources))
+}
+
+func TestFilterManifestByNamespace(t *testing.T) {
+	defer resetFilters()
+	rootConfig.excludeNamespaces = []string{"kube-system"}
+	assert.Equal(t, []string{"Deployment/web", "Namespace/shop", "StatefulSet/db"}, filteredManifestNames(t))
+
+	resetFilters()
+	rootConfig.namespaces = []string{"kube-system", "other"}
+	assert.Equal(t, []string{"DaemonSet/proxy", "Namespace/kube-system"}, filteredManifestNames(t))
+}
+
+func TestFilterManifestBySelector(t *testing.T) {
+	defer resetFilters()
+	rootConfig.selector = "tier=frontend"
+	assert.Equal(t, []string{"DaemonSet/proxy", "Deployment/web", "Namespace/kube-system", "Namespace/shop"}, filteredManifestNames(t))
+
+	rootConfig.selector = "tier=frontend,app!=proxy"
+	rootConfig.kinds = []string{"deployments", "StatefulSet"}
+	assert.Equal(t, []string{"Deployment/web"}, filteredManifestNames(t))
+}
+
+func TestIsAuditedKind(t *testing.T) {
+	defer resetFilters()
+	rootConfig.kinds = []string{"ingresses", "networkpolicies", "Deployments"}
+	assert.True(t, isAuditedKind("Ingress"))
+	assert.True(t, isAuditedKind("NetworkPolicy"))
+	assert.True(t, isAuditedKind("Deployment"))
+	assert.False(t, isAuditedKind("Service"))
+
+	rootConfig.kinds = []string{"ingress", "NetworkPolicy"}
+	assert.True(t, isAuditedKind("Ingress"))
+	assert.True(t, isAuditedKind("NetworkPolicy"))
+	assert.False(t, isAuditedKind("Deployment"))
+}
+
+func TestGetKubeResourcesWithFilters(t *testing.T) {
+	defer resetFilters()
+	client := fakeclientset.NewSimpleClientset(
+		&NamespaceV1{ObjectMeta: ObjectMetaV1{Name: "ns1"}},
+		&NamespaceV1{ObjectMeta: ObjectMetaV1{Name: "ns2"}},
+		&NamespaceV1{ObjectMeta: ObjectMetaV1{Name: "ns3"}},
+		&PodV1{ObjectMeta: ObjectMetaV1{Name: "pod1", Namespace: "ns1", Labels: map[string]string{"app": "web"}}},
+		&PodV1{ObjectMeta: ObjectMetaV1{Name: "pod2", Namespace: "ns2", Labels: map[string]string{"app": "db"}}},
+		&PodV1{ObjectMeta: ObjectMetaV1{Name: "pod3", Namespace: "ns3", Labels: map[string]string{"app": "web"}}},
+		&DeploymentV1{ObjectMeta: ObjectMetaV1{Name: "deployment1", Namespace: "ns1", Labels: map[string]string{"app": "web"}}},
+	)
+	allowAccessReviews(client, func(*authorizationv1.ResourceAttributes) bool { return true })
+	scanCoverage.reset()
+
+	rootConfig.excludeNamespaces = []string{"ns2"}
+	rootConfig.selector = "app=web"
+	rootConfig.kinds = []string{"pod"}
+	resources, err := getKubeResources(context.Background(), client, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Pod/pod1", "Pod/pod3"}, resourceNames(resources))
+
+	rootConfig.namespaces = []string{"ns1", "ns2"}
+	rootConfig.kinds = nil
+	resources, err = getKubeResources(context.Background(), client, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Deployment/deployment1", "Namespace/ns1", "Pod/pod1"}, resourceNames(resources))
+	assert.True(t, scanCoverage.Complete())
+}
+
+func TestConfigFiltersDontOverrideFlags(t *testing.T) {
+	defer resetFilters()
+	rootConfig.selector = "app=web"
+	applyConfigFilters(&KubeauditConfigFilters{
+		ExcludeNamespaces: []string{"kube-system"},
+		Selector:          "app=db",
+	})
+	assert.Equal(t, "app=web", rootConfig.selector)
+	assert.Equal(t, []string{"kube-system"}, rootConfig.excludeNamespaces)
+}
@@ -0,0 +1,494 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type graphFlags struct {
+	format string
+	output string
+}
+
+var graphConfig graphFlags
+
+// graphNode is a workload, service account, binding, role, secret, hostPath, privileged container, or one of the
+// targets an attacker ultimately wants: the node a pod runs on and control over the whole cluster.
+type graphNode struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Cluster   string `json:"cluster,omitempty"`
+}
+
+// graphEdge means that an attacker controlling From also gets access to To.
+type graphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label"`
+}
+
+// attackGraph shows how compromising one workload leads to others, to secrets, to the node or to the whole cluster.
+type attackGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+	nodes map[string]bool
+	edges map[graphEdge]bool
+}
+
+func newAttackGraph() *attackGraph {
+	return &attackGraph{nodes: map[string]bool{}, edges: map[graphEdge]bool{}}
+}
+
+func graphNodeID(node graphNode) string {
+	id := node.Kind + ":"
+	if node.Namespace != "" {
+		id += node.Namespace + "/"
+	}
+	id += node.Name
+	if node.Cluster != "" {
+		id = node.Cluster + "/" + id
+	}
+	return id
+}
+
+func (g *attackGraph) addNode(node graphNode) string {
+	node.ID = graphNodeID(node)
+	if !g.nodes[node.ID] {
+		g.nodes[node.ID] = true
+		g.Nodes = append(g.Nodes, node)
+	}
+	return node.ID
+}
+
+func (g *attackGraph) addEdge(from, to, label string) {
+	edge := graphEdge{From: from, To: to, Label: label}
+	if !g.edges[edge] {
+		g.edges[edge] = true
+		g.Edges = append(g.Edges, edge)
+	}
+}
+
+func (g *attackGraph) sort() {
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		if g.Edges[i].To != g.Edges[j].To {
+			return g.Edges[i].To < g.Edges[j].To
+		}
+		return g.Edges[i].Label < g.Edges[j].Label
+	})
+}
+
+// graphWorkload is a workload together with the service account its pods run as.
+type graphWorkload struct {
+	id             string
+	cluster        string
+	namespace      string
+	serviceAccount string
+}
+
+// graphBuilder remembers the workloads, service accounts and secrets already in the graph so that bindings can point
+// at the ones they give access to.
+type graphBuilder struct {
+	graph           *attackGraph
+	workloads       []graphWorkload
+	serviceAccounts []graphNode
+	secrets         []graphNode
+}
+
+// nodes returns the nodes the workload runs on: the node of a scheduled Pod and the nodes of the Pods rolled up into a
+// controller. A workload whose nodes aren't known, such as one read from a manifest, gets a node of its own so that
+// it doesn't appear to share a node with every other workload.
+func (b *graphBuilder) nodes(resource Resource, meta metav1.Object, podSpec PodSpecV1) []graphNode {
+	cluster := meta.GetClusterName()
+	names := scanOwnership.nodeNames(resource)
+	if podSpec.NodeName != "" && !containsString(names, podSpec.NodeName) {
+		names = append(names, podSpec.NodeName)
+	}
+	if len(names) == 0 {
+		return []graphNode{{Kind: "Node", Namespace: meta.GetNamespace(), Name: kindOf(resource) + "/" + meta.GetName(), Cluster: cluster}}
+	}
+	var nodes []graphNode
+	for _, name := range names {
+		nodes = append(nodes, graphNode{Kind: "Node", Name: name, Cluster: cluster})
+	}
+	return nodes
+}
+
+// admin returns the node standing for full control over what the grant is scoped to: the cluster for a
+// ClusterRoleBinding, and only the namespace of a RoleBinding, even one of the cluster-admin ClusterRole.
+func (b *graphBuilder) admin(cluster string, grant rbacGrant) string {
+	if !grant.cluster {
+		return b.graph.addNode(graphNode{Kind: "Namespace", Namespace: grant.namespace, Name: "namespace-admin", Cluster: cluster})
+	}
+	return b.graph.addNode(graphNode{Kind: "Cluster", Name: "cluster-admin", Cluster: cluster})
+}
+
+func (b *graphBuilder) serviceAccount(cluster, namespace, name string) string {
+	node := graphNode{Kind: "ServiceAccount", Namespace: namespace, Name: name, Cluster: cluster}
+	if !b.graph.nodes[graphNodeID(node)] {
+		b.serviceAccounts = append(b.serviceAccounts, node)
+	}
+	return b.graph.addNode(node)
+}
+
+func (b *graphBuilder) secret(cluster, namespace, name string) string {
+	node := graphNode{Kind: "Secret", Namespace: namespace, Name: name, Cluster: cluster}
+	if !b.graph.nodes[graphNodeID(node)] && namespace != "*" && name != "*" {
+		b.secrets = append(b.secrets, node)
+	}
+	return b.graph.addNode(node)
+}
+
+func (b *graphBuilder) addWorkload(resource Resource, meta metav1.Object, podSpec PodSpecV1) {
+	cluster, namespace := meta.GetClusterName(), meta.GetNamespace()
+	id := b.graph.addNode(graphNode{Kind: kindOf(resource), Namespace: namespace, Name: meta.GetName(), Cluster: cluster})
+
+	serviceAccount := podSpec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = podSpec.DeprecatedServiceAccount
+	}
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	serviceAccountID := b.serviceAccount(cluster, namespace, serviceAccount)
+	if scanPrivileges.mountsToken(cluster, namespace, serviceAccount, podSpec.AutomountServiceAccountToken) {
+		b.graph.addEdge(id, serviceAccountID, "mounts token")
+	}
+	b.workloads = append(b.workloads, graphWorkload{id: id, cluster: cluster, namespace: namespace, serviceAccount: serviceAccount})
+	nodes := b.nodes(resource, meta, podSpec)
+	toNodes := func(from, label string) {
+		for _, node := range nodes {
+			b.graph.addEdge(from, b.graph.addNode(node), label)
+		}
+	}
+
+	for _, volume := range podSpec.Volumes {
+		if volume.Secret != nil {
+			b.graph.addEdge(id, b.secret(cluster, namespace, volume.Secret.SecretName), "mounts secret")
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					b.graph.addEdge(id, b.secret(cluster, namespace, source.Secret.Name), "mounts secret")
+				}
+			}
+		}
+		if volume.HostPath != nil {
+			// The path is a node of its own on every node, so that workloads only share it when they share the node
+			for _, node := range nodes {
+				hostPath := graphNode{Kind: "HostPath", Namespace: node.Namespace, Name: node.Name + ":" + volume.HostPath.Path, Cluster: cluster}
+				hostPathID := b.graph.addNode(hostPath)
+				b.graph.addEdge(id, hostPathID, "mounts hostPath")
+				b.graph.addEdge(hostPathID, b.graph.addNode(node), "host filesystem")
+			}
+		}
+	}
+
+	for _, container := range append(append([]ContainerV1{}, podSpec.InitContainers...), podSpec.Containers...) {
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				b.graph.addEdge(id, b.secret(cluster, namespace, env.ValueFrom.SecretKeyRef.Name), "reads secret from env")
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				b.graph.addEdge(id, b.secret(cluster, namespace, envFrom.SecretRef.Name), "reads secret from env")
+			}
+		}
+		if container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+			containerID := b.graph.addNode(graphNode{Kind: "Container", Namespace: namespace, Name: meta.GetName() + "/" + container.Name, Cluster: cluster})
+			b.graph.addEdge(id, containerID, "runs privileged container")
+			toNodes(containerID, "escapes to node")
+		}
+	}
+
+	if podSpec.HostPID {
+		toNodes(id, "shares host PID namespace")
+	}
+	if podSpec.HostIPC {
+		toNodes(id, "shares host IPC namespace")
+	}
+	if podSpec.HostNetwork {
+		toNodes(id, "shares host network namespace")
+	}
+}
+
+// subjectServiceAccounts returns the service accounts in the graph which are subjects of the binding, directly or
+// through the groups every service account token belongs to. Users are left out since they aren't workloads.
+func (b *graphBuilder) subjectServiceAccounts(cluster, bindingNamespace string, subjects []SubjectV1) (ids []string) {
+	for _, subject := range subjects {
+		switch {
+		case subject.Kind == "ServiceAccount":
+			namespace := subject.Namespace
+			if namespace == "" {
+				namespace = bindingNamespace
+			}
+			ids = append(ids, b.serviceAccount(cluster, namespace, subject.Name))
+		case subject.Kind == "Group" && (subject.Name == "system:serviceaccounts" || subject.Name == "system:authenticated"):
+			for _, serviceAccount := range b.serviceAccounts {
+				if serviceAccount.Cluster == cluster {
+					ids = append(ids, graphNodeID(serviceAccount))
+				}
+			}
+		case subject.Kind == "Group" && strings.HasPrefix(subject.Name, "system:serviceaccounts:"):
+			namespace := strings.TrimPrefix(subject.Name, "system:serviceaccounts:")
+			for _, serviceAccount := range b.serviceAccounts {
+				if serviceAccount.Cluster == cluster && serviceAccount.Namespace == namespace {
+					ids = append(ids, graphNodeID(serviceAccount))
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// addBinding links the service accounts bound by a RoleBinding or ClusterRoleBinding to what the role gives them
+// access to. The edges start at the binding rather than the role since a role bound in a namespace only gives access
+// to that namespace.
+func (b *graphBuilder) addBinding(meta metav1.Object, grant rbacGrant, subjects []SubjectV1) {
+	cluster := meta.GetClusterName()
+	serviceAccountIDs := b.subjectServiceAccounts(cluster, grant.namespace, subjects)
+	if len(serviceAccountIDs) == 0 {
+		return
+	}
+
+	kind := "RoleBinding"
+	if grant.cluster {
+		kind = "ClusterRoleBinding"
+	}
+	bindingID := b.graph.addNode(graphNode{Kind: kind, Namespace: grant.namespace, Name: meta.GetName(), Cluster: cluster})
+	for _, serviceAccountID := range serviceAccountIDs {
+		b.graph.addEdge(serviceAccountID, bindingID, "bound by")
+	}
+	role := graphNode{Kind: grant.roleRef.Kind, Name: grant.roleRef.Name, Cluster: cluster}
+	if grant.roleRef.Kind == "Role" {
+		role.Namespace = grant.namespace
+	}
+	b.graph.addEdge(bindingID, b.graph.addNode(role), "grants")
+
+	inScope := func(namespace string) bool { return grant.cluster || namespace == grant.namespace }
+	rules := scanPrivileges.rules(cluster, grant)
+	b.addSecretReads(bindingID, cluster, grant, rules, inScope)
+	for _, check := range matchingRuleChecks(rules) {
+		switch check.id {
+		case ErrorRBACPodExec:
+			for _, workload := range b.workloads {
+				if workload.cluster == cluster && inScope(workload.namespace) {
+					b.graph.addEdge(bindingID, workload.id, check.permission)
+				}
+			}
+		case ErrorRBACPodCreate:
+			// Pods can be created with any service account of the namespace
+			for _, serviceAccount := range b.serviceAccounts {
+				if serviceAccount.Cluster == cluster && inScope(serviceAccount.Namespace) {
+					b.graph.addEdge(bindingID, graphNodeID(serviceAccount), check.permission+" as")
+				}
+			}
+		case ErrorRBACEscalationVerbs:
+			b.graph.addEdge(bindingID, b.admin(cluster, grant), check.permission)
+		}
+	}
+	for _, rule := range rules {
+		if containsString(rule.Verbs, "*") && containsString(rule.Resources, "*") {
+			b.graph.addEdge(bindingID, b.admin(cluster, grant), "every verb on every resource")
+			break
+		}
+	}
+}
+
+// addSecretReads links the binding to the secrets its rules allow reading. A rule listing resourceNames leads to the
+// secrets with those names, a rule without leads to every secret in the graph within the scope of the binding and to
+// a wildcard secret standing for the secrets no workload uses.
+func (b *graphBuilder) addSecretReads(bindingID, cluster string, grant rbacGrant, rules []PolicyRuleV1, inScope func(string) bool) {
+	const permission = "read secrets"
+	wildcardNamespace := grant.namespace
+	if grant.cluster {
+		wildcardNamespace = "*"
+	}
+	for _, rule := range rules {
+		if !ruleGrants(rule, []string{""}, "secrets", "get", "list", "watch") {
+			continue
+		}
+		if len(rule.ResourceNames) == 0 {
+			b.graph.addEdge(bindingID, b.secret(cluster, wildcardNamespace, "*"), permission)
+		}
+		for _, secret := range b.secrets {
+			if secret.Cluster != cluster || !inScope(secret.Namespace) {
+				continue
+			}
+			if len(rule.ResourceNames) == 0 || containsString(rule.ResourceNames, secret.Name) {
+				b.graph.addEdge(bindingID, graphNodeID(secret), permission)
+			}
+		}
+		if !grant.cluster {
+			for _, name := range rule.ResourceNames {
+				b.graph.addEdge(bindingID, b.secret(cluster, grant.namespace, name), permission)
+			}
+		}
+	}
+}
+
+// buildAttackGraph builds the graph from the workloads, service accounts and bindings among the resources. The roles
+// granted by the bindings are resolved with scanPrivileges.
+func buildAttackGraph(resources []Resource) *attackGraph {
+	builder := &graphBuilder{graph: newAttackGraph()}
+	for _, resource := range resources {
+		meta, ok := resource.(metav1.Object)
+		if !ok {
+			continue
+		}
+		if serviceAccount, ok := resource.(*ServiceAccountV1); ok {
+			builder.serviceAccount(meta.GetClusterName(), serviceAccount.Namespace, serviceAccount.Name)
+		} else if podSpec, ok := podSpecOf(resource); ok {
+			builder.addWorkload(resource, meta, podSpec)
+		}
+	}
+	for _, resource := range resources {
+		switch kubeType := resource.(type) {
+		case *RoleBindingV1:
+			grant := rbacGrant{binding: "RoleBinding/" + kubeType.Name, roleRef: kubeType.RoleRef, namespace: kubeType.Namespace}
+			builder.addBinding(kubeType, grant, kubeType.Subjects)
+		case *ClusterRoleBindingV1:
+			grant := rbacGrant{binding: "ClusterRoleBinding/" + kubeType.Name, roleRef: kubeType.RoleRef, cluster: true}
+			builder.addBinding(kubeType, grant, kubeType.Subjects)
+		}
+	}
+	builder.graph.sort()
+	return builder.graph
+}
+
+var dotShapes = map[string]string{
+	"ServiceAccount":     "ellipse",
+	"RoleBinding":        "cds",
+	"ClusterRoleBinding": "cds",
+	"Role":               "hexagon",
+	"ClusterRole":        "hexagon",
+	"Secret":             "cylinder",
+	"HostPath":           "folder",
+	"Container":          "box3d",
+	"Node":               "doubleoctagon",
+	"Cluster":            "doubleoctagon",
+	"Namespace":          "doubleoctagon",
+}
+
+func (g *attackGraph) writeDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph kubeaudit {\n\trankdir=LR;\n")
+	for _, node := range g.Nodes {
+		shape, ok := dotShapes[node.Kind]
+		if !ok {
+			shape = "box"
+		}
+		label := node.Kind + "\n" + node.Name
+		if node.Namespace != "" {
+			label = node.Kind + "\n" + node.Namespace + "/" + node.Name
+		}
+		attributes := fmt.Sprintf("label=%s, shape=%s", strconv.Quote(label), shape)
+		if node.Kind == "Node" || node.Kind == "Cluster" || node.Kind == "Namespace" {
+			attributes += ", color=red"
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", strconv.Quote(node.ID), attributes)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s];\n", strconv.Quote(edge.From), strconv.Quote(edge.To), strconv.Quote(edge.Label))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (g *attackGraph) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(g)
+}
+
+func (g *attackGraph) write(w io.Writer, format string) error {
+	switch format {
+	case "dot":
+		return g.writeDOT(w)
+	case "json":
+		return g.writeJSON(w)
+	}
+	return fmt.Errorf("unsupported graph format %q, use dot or json", format)
+}
+
+func runGraph(*cobra.Command, []string) {
+	setFormatter()
+	ctx, cancel := newAuditContext()
+	defer cancel()
+	scanCoverage.reset()
+	scanOwnership.reset()
+	scanPrivileges.reset()
+	scanWorkloads.reset()
+	resources, err := getResources(ctx)
+	if err != nil {
+		log.Error("getResources failed")
+		abortIncompleteScan(err)
+	}
+	graph := buildAttackGraph(resources)
+
+	out := io.Writer(os.Stdout)
+	if graphConfig.output != "" {
+		f, err := os.Create(graphConfig.output)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := graph.write(out, graphConfig.format); err != nil {
+		log.Error(err)
+		return
+	}
+	scanCoverage.Print()
+	if !scanCoverage.Complete() {
+		os.Exit(ExitCodeIncompleteScan)
+	}
+}
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Export an attack-path graph of workloads, service accounts, roles and secrets",
+	Long: `This command builds a graph showing how compromising one workload leads to
+others, to secrets, to the node or to the whole cluster, and writes it in DOT
+or JSON format. It works offline from a manifest file or a snapshot.
+
+The graph has an edge when a workload:
+- mounts the token of a service account
+- mounts a secret or reads one into its environment
+- mounts a hostPath, runs a privileged container or shares a host namespace,
+  which lead to the nodes its pods run on
+and when a service account is bound to a role which:
+- reads secrets
+- executes commands in workloads
+- creates pods, and so can run as any service account in their namespace
+- escalates its privileges or has every verb on every resource
+
+Example usage:
+kubeaudit graph -f /path/to/yaml | dot -Tsvg > graph.svg
+kubeaudit graph --format json -o graph.json`,
+	PreRun: func(*cobra.Command, []string) { auditsRBAC = true },
+	Run:    runGraph,
+}
+
+func init() {
+	RootCmd.AddCommand(graphCmd)
+	graphCmd.Flags().StringVar(&graphConfig.format, "format", "dot", "Graph format, dot or json")
+	graphCmd.Flags().StringVarP(&graphConfig.output, "output", "o", "", "File to write the graph to, standard output by default")
+}
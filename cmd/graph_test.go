@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadAttackGraph(t *testing.T, file string) *attackGraph {
+	resources, err := getKubeResourcesManifest(filepath.Join(path, file))
+	require.Nil(t, err)
+	scanPrivileges.reset()
+	scanPrivileges.index(resources)
+	defer scanPrivileges.reset()
+	return buildAttackGraph(resources)
+}
+
+func TestAttackGraphEdges(t *testing.T) {
+	graph := loadAttackGraph(t, "graph_v1.yml")
+	expected := []graphEdge{
+		{From: "Deployment:web/frontend", To: "ServiceAccount:web/frontend", Label: "mounts token"},
+		{From: "ServiceAccount:web/frontend", To: "RoleBinding:web/frontend-debugger", Label: "bound by"},
+		{From: "RoleBinding:web/frontend-debugger", To: "Role:web/debugger", Label: "grants"},
+		{From: "RoleBinding:web/frontend-debugger", To: "Deployment:web/backend", Label: "exec into pods"},
+		{From: "Deployment:web/frontend", To: "Secret:web/api-key", Label: "reads secret from env"},
+		{From: "Deployment:web/frontend", To: "HostPath:web/Deployment/frontend:/var/log", Label: "mounts hostPath"},
+		{From: "HostPath:web/Deployment/frontend:/var/log", To: "Node:web/Deployment/frontend", Label: "host filesystem"},
+		{From: "Deployment:web/backend", To: "Secret:web/backend-tls", Label: "mounts secret"},
+		{From: "Deployment:web/backend", To: "Container:web/backend/backend", Label: "runs privileged container"},
+		{From: "Container:web/backend/backend", To: "Node:web/Deployment/backend", Label: "escapes to node"},
+		{From: "ClusterRoleBinding:backend-secret-reader", To: "Secret:*/*", Label: "read secrets"},
+		{From: "ClusterRoleBinding:backend-secret-reader", To: "Secret:web/api-key", Label: "read secrets"},
+		{From: "ClusterRoleBinding:backend-secret-reader", To: "Secret:monitoring/scraper-env", Label: "read secrets"},
+		{From: "ClusterRoleBinding:backend-admin", To: "Cluster:cluster-admin", Label: "every verb on every resource"},
+		{From: "ClusterRoleBinding:backend-admin", To: "ServiceAccount:jobs/default", Label: "create pods as"},
+	}
+	for _, edge := range expected {
+		assert.Contains(t, graph.Edges, edge)
+	}
+	// The batch Deployment doesn't mount its token
+	for _, edge := range graph.Edges {
+		assert.NotEqual(t, "Deployment:jobs/batch", edge.From)
+	}
+}
+
+func TestAttackGraphSharedNodes(t *testing.T) {
+	graph := loadAttackGraph(t, "graph_v1.yml")
+	assert.Contains(t, graph.Edges, graphEdge{From: "Pod:monitoring/agent", To: "Node:worker-1", Label: "shares host network namespace"})
+	assert.Contains(t, graph.Edges, graphEdge{From: "Container:monitoring/scraper/scraper", To: "Node:worker-1", Label: "escapes to node"})
+	// Workloads whose nodes aren't known don't share one
+	assert.NotContains(t, graph.Edges, graphEdge{From: "Container:web/backend/backend", To: "Node:worker-1", Label: "escapes to node"})
+}
+
+func TestAttackGraphSecretResourceNames(t *testing.T) {
+	graph := loadAttackGraph(t, "graph_v1.yml")
+	assert.Contains(t, graph.Edges, graphEdge{From: "Pod:monitoring/scraper", To: "Secret:monitoring/scraper-env", Label: "reads secret from env"})
+	assert.Contains(t, graph.Edges, graphEdge{From: "RoleBinding:monitoring/agent-tls-reader", To: "Secret:monitoring/scraper-tls", Label: "read secrets"})
+	assert.NotContains(t, graph.Edges, graphEdge{From: "RoleBinding:monitoring/agent-tls-reader", To: "Secret:monitoring/scraper-env", Label: "read secrets"})
+	assert.NotContains(t, graph.Edges, graphEdge{From: "RoleBinding:monitoring/agent-tls-reader", To: "Secret:monitoring/*", Label: "read secrets"})
+}
+
+func TestAttackGraphNamespacedAdmin(t *testing.T) {
+	graph := loadAttackGraph(t, "graph_v1.yml")
+	// Binding cluster-admin in a namespace only gives control over that namespace
+	assert.Contains(t, graph.Edges, graphEdge{From: "RoleBinding:monitoring/agent-admin", To: "Namespace:monitoring/namespace-admin", Label: "every verb on every resource"})
+	assert.NotContains(t, graph.Edges, graphEdge{From: "RoleBinding:monitoring/agent-admin", To: "Cluster:cluster-admin", Label: "every verb on every resource"})
+}
+
+func TestAttackGraphWriteDOT(t *testing.T) {
+	var out bytes.Buffer
+	require.Nil(t, loadAttackGraph(t, "graph_v1.yml").write(&out, "dot"))
+	assert.Contains(t, out.String(), "digraph kubeaudit {")
+	assert.Contains(t, out.String(), `"Node:worker-1" [label="Node\nworker-1", shape=doubleoctagon, color=red];`)
+	assert.Contains(t, out.String(), `"HostPath:web/Deployment/frontend:/var/log" -> "Node:web/Deployment/frontend" [label="host filesystem"];`)
+}
+
+func TestAttackGraphWriteJSON(t *testing.T) {
+	var out bytes.Buffer
+	graph := loadAttackGraph(t, "graph_v1.yml")
+	require.Nil(t, graph.write(&out, "json"))
+	decoded := attackGraph{}
+	require.Nil(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, graph.Nodes, decoded.Nodes)
+	assert.Equal(t, graph.Edges, decoded.Edges)
+
+	assert.NotNil(t, graph.write(&out, "svg"))
+}
+
+func TestAttackGraphClusterNames(t *testing.T) {
+	resources, err := getKubeResourcesManifest(filepath.Join(path, "graph_v1.yml"))
+	require.Nil(t, err)
+	setClusterName(resources, "staging")
+	scanPrivileges.reset()
+	scanPrivileges.index(resources)
+	defer scanPrivileges.reset()
+	graph := buildAttackGraph(resources)
+	assert.Contains(t, graph.Edges, graphEdge{From: "staging/Pod:monitoring/agent", To: "staging/Node:worker-1", Label: "shares host network namespace"})
+}
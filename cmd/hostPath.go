@@ -0,0 +1,349 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Severities of a sensitive host path. A path with the ignore severity isn't reported.
+const (
+	hostPathSeverityError   = "error"
+	hostPathSeverityWarning = "warning"
+	hostPathSeverityInfo    = "info"
+	hostPathSeverityIgnore  = "ignore"
+)
+
+// hostPathSeverities are the severities the catalog entries of the config may use.
+var hostPathSeverities = []string{hostPathSeverityError, hostPathSeverityWarning, hostPathSeverityInfo, hostPathSeverityIgnore}
+
+// defaultHostPaths is the catalog of sensitive host paths used when the config doesn't override them. The read-only
+// severity is lower when mounting the path read-only takes away most of the danger, and the same for container runtime
+// sockets since connecting to a socket works on a read-only mount.
+var defaultHostPaths = []KubeauditConfigHostPath{
+	{Path: "/", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityError},
+	{Path: "/boot", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityWarning},
+	{Path: "/dev", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityError},
+	{Path: "/etc", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityWarning},
+	{Path: "/home", Severity: hostPathSeverityWarning, ReadOnlySeverity: hostPathSeverityWarning},
+	{Path: "/proc", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityError},
+	{Path: "/root", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityWarning},
+	{Path: "/sys", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityWarning},
+	{Path: "/run/containerd/containerd.sock", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityError},
+	{Path: "/run/crio/crio.sock", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityError},
+	{Path: "/run/docker.sock", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityError},
+	{Path: "/var/run/containerd/containerd.sock", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityError},
+	{Path: "/var/run/crio/crio.sock", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityError},
+	{Path: "/var/run/cri-dockerd.sock", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityError},
+	{Path: "/var/run/docker.sock", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityError},
+	{Path: "/var/lib/containerd", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityWarning},
+	{Path: "/var/lib/docker", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityWarning},
+	{Path: "/var/lib/etcd", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityError},
+	{Path: "/var/lib/kubelet", Severity: hostPathSeverityError, ReadOnlySeverity: hostPathSeverityWarning},
+	{Path: "/var/log", Severity: hostPathSeverityWarning, ReadOnlySeverity: hostPathSeverityInfo},
+}
+
+// hostPathCatalog returns the default catalog with the entries of the config added to it. A config entry replaces the
+// default entry for the same path.
+func hostPathCatalog() []KubeauditConfigHostPath {
+	catalog := append([]KubeauditConfigHostPath{}, defaultHostPaths...)
+	for _, entry := range auditConfigSpec().HostPaths {
+		if entry == nil || entry.Path == "" {
+			continue
+		}
+		configured := *entry
+		configured.Path = cleanHostPath(configured.Path)
+		if configured.Severity == "" {
+			configured.Severity = hostPathSeverityError
+		}
+		replaced := false
+		for i := range catalog {
+			if catalog[i].Path == configured.Path {
+				catalog[i], replaced = configured, true
+			}
+		}
+		if !replaced {
+			catalog = append(catalog, configured)
+		}
+	}
+	return catalog
+}
+
+func cleanHostPath(hostPath string) string {
+	return filepath.ToSlash(filepath.Clean("/" + hostPath))
+}
+
+// hostPathExposes returns true if mounting the host path gives access to the sensitive path, either because it is the
+// path itself, one of its parents or a path below it. Anything is below /, so it only matches itself.
+func hostPathExposes(hostPath, sensitivePath string) bool {
+	if hostPath == sensitivePath || hostPath == "/" {
+		return true
+	}
+	if strings.HasPrefix(sensitivePath, hostPath+"/") {
+		return true
+	}
+	return sensitivePath != "/" && strings.HasPrefix(hostPath, sensitivePath+"/")
+}
+
+func hostPathSeverityRank(severity string) int {
+	switch severity {
+	case hostPathSeverityError:
+		return 3
+	case hostPathSeverityWarning:
+		return 2
+	case hostPathSeverityInfo:
+		return 1
+	}
+	return 0
+}
+
+func hostPathSeverityKind(severity string) int {
+	switch severity {
+	case hostPathSeverityWarning:
+		return Warn
+	case hostPathSeverityInfo:
+		return Info
+	}
+	return Error
+}
+
+// sensitiveHostPath returns the most severe catalog entry exposed by the host path along with its severity given
+// whether the path is mounted read-only. It returns false if the host path isn't sensitive.
+func sensitiveHostPath(catalog []KubeauditConfigHostPath, hostPath string, readOnly bool) (KubeauditConfigHostPath, string, bool) {
+	var match KubeauditConfigHostPath
+	matchSeverity := ""
+	for _, entry := range catalog {
+		if !hostPathExposes(hostPath, entry.Path) {
+			continue
+		}
+		severity := entry.Severity
+		if readOnly && entry.ReadOnlySeverity != "" {
+			severity = entry.ReadOnlySeverity
+		}
+		rank := hostPathSeverityRank(severity)
+		if rank > hostPathSeverityRank(matchSeverity) ||
+			(rank > 0 && rank == hostPathSeverityRank(matchSeverity) && len(entry.Path) > len(match.Path)) {
+			match, matchSeverity = entry, severity
+		}
+	}
+	return match, matchSeverity, matchSeverity != ""
+}
+
+// sensitiveHostPathMount is a volume mount of a container which exposes a sensitive host path.
+type sensitiveHostPathMount struct {
+	volume    string
+	hostPath  string
+	mountPath string
+	readOnly  bool
+	entry     KubeauditConfigHostPath
+	severity  string
+	// readOnlyHelps is set when the mount is read-write and mounting it read-only would make it less severe, in which
+	// case the autofix sets readOnly on the mount.
+	readOnlyHelps bool
+}
+
+// sensitiveHostPathMounts returns the mounts of the container which expose a sensitive host path. The path is the one
+// on the host, including the subPath of the mount, rather than where it's mounted in the container.
+func sensitiveHostPathMounts(catalog []KubeauditConfigHostPath, podSpec PodSpecV1, container ContainerV1) (mounts []sensitiveHostPathMount) {
+	hostPaths := map[string]string{}
+	for _, volume := range podSpec.Volumes {
+		if volume.HostPath != nil {
+			hostPaths[volume.Name] = volume.HostPath.Path
+		}
+	}
+	for _, volumeMount := range container.VolumeMounts {
+		hostPath, ok := hostPaths[volumeMount.Name]
+		if !ok {
+			continue
+		}
+		if volumeMount.SubPath != "" {
+			hostPath += "/" + volumeMount.SubPath
+		}
+		hostPath = cleanHostPath(hostPath)
+		entry, severity, sensitive := sensitiveHostPath(catalog, hostPath, volumeMount.ReadOnly)
+		if !sensitive {
+			continue
+		}
+		_, readOnlySeverity, _ := sensitiveHostPath(catalog, hostPath, true)
+		mounts = append(mounts, sensitiveHostPathMount{
+			volume:        volumeMount.Name,
+			hostPath:      hostPath,
+			mountPath:     volumeMount.MountPath,
+			readOnly:      volumeMount.ReadOnly,
+			entry:         entry,
+			severity:      severity,
+			readOnlyHelps: hostPathSeverityRank(readOnlySeverity) < hostPathSeverityRank(severity),
+		})
+	}
+	return mounts
+}
+
+// unmountedSensitiveHostPaths returns the hostPath volumes of the pod which expose a sensitive host path but which no
+// container, including init containers, mounts. They can still be mounted by containers added to the pod later.
+func unmountedSensitiveHostPaths(catalog []KubeauditConfigHostPath, podSpec PodSpecV1) (volumes []sensitiveHostPathMount) {
+	mounted := map[string]bool{}
+	for _, container := range podContainers(podSpec) {
+		for _, volumeMount := range container.VolumeMounts {
+			mounted[volumeMount.Name] = true
+		}
+	}
+	for _, volume := range podSpec.Volumes {
+		if volume.HostPath == nil || mounted[volume.Name] {
+			continue
+		}
+		hostPath := cleanHostPath(volume.HostPath.Path)
+		entry, severity, sensitive := sensitiveHostPath(catalog, hostPath, false)
+		if !sensitive {
+			continue
+		}
+		volumes = append(volumes, sensitiveHostPathMount{volume: volume.Name, hostPath: hostPath, entry: entry, severity: severity})
+	}
+	return volumes
+}
+
+// podContainers returns the init containers and the containers of the pod.
+func podContainers(podSpec PodSpecV1) []ContainerV1 {
+	return append(append([]ContainerV1{}, podSpec.InitContainers...), podSpec.Containers...)
+}
+
+func hostPathMetadata(mount sensitiveHostPathMount) Metadata {
+	return Metadata{
+		"Volume":        mount.volume,
+		"HostPath":      mount.hostPath,
+		"MountPath":     mount.mountPath,
+		"SensitivePath": mount.entry.Path,
+		"ReadOnly":      fmt.Sprint(mount.readOnly),
+	}
+}
+
+func checkHostPaths(podSpec PodSpecV1, container ContainerV1, result *Result) {
+	mounts := sensitiveHostPathMounts(hostPathCatalog(), podSpec, container)
+
+	if labelExists, reason := getContainerOverrideLabelReason(result, container, "allow-sensitive-host-path"); labelExists {
+		if len(mounts) == 0 {
+			occ := Occurrence{
+				container: container.Name,
+				id:        ErrorMisconfiguredKubeauditAllow,
+				kind:      Warn,
+				message:   "Allowed mounting a sensitive host path, but no sensitive host path is mounted",
+				metadata:  Metadata{"Reason": prettifyReason(reason)},
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+		for _, mount := range mounts {
+			metadata := hostPathMetadata(mount)
+			metadata["Reason"] = prettifyReason(reason)
+			occ := Occurrence{
+				container: container.Name,
+				id:        ErrorHostPathSensitiveAllowed,
+				kind:      Warn,
+				message:   "Allowed mounting a sensitive host path",
+				metadata:  metadata,
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+		return
+	}
+
+	for _, mount := range mounts {
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorHostPathSensitive,
+			kind:      hostPathSeverityKind(mount.severity),
+			message:   fmt.Sprintf("%s is mounted from the host, please avoid mounting sensitive host paths", mount.hostPath),
+			metadata:  hostPathMetadata(mount),
+		}
+		if mount.readOnlyHelps {
+			occ.message = fmt.Sprintf("%s is mounted read-write from the host, please avoid mounting it or set readOnly to true", mount.hostPath)
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+}
+
+func checkUnmountedHostPaths(podSpec PodSpecV1, result *Result) {
+	volumes := unmountedSensitiveHostPaths(hostPathCatalog(), podSpec)
+	labelExists, reason := getPodOverrideLabelReason(result, "allow-sensitive-host-path")
+	for _, volume := range volumes {
+		metadata := Metadata{"Volume": volume.volume, "HostPath": volume.hostPath, "SensitivePath": volume.entry.Path}
+		occ := Occurrence{
+			id:       ErrorHostPathSensitiveUnmounted,
+			kind:     Warn,
+			message:  fmt.Sprintf("%s is a volume of the pod which no container mounts, please remove it", volume.hostPath),
+			metadata: metadata,
+		}
+		if labelExists {
+			metadata["Reason"] = prettifyReason(reason)
+			occ.id, occ.message = ErrorHostPathSensitiveAllowed, "Allowed mounting a sensitive host path"
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+}
+
+func auditHostPaths(resource Resource) (results []Result) {
+	podSpec, ok := podSpecOf(resource)
+	if !ok {
+		return
+	}
+	for _, container := range podContainers(podSpec) {
+		result, err, warn := newResultFromResource(resource)
+		if warn != nil {
+			log.Warn(warn)
+			return
+		}
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		checkHostPaths(podSpec, container, result)
+		if len(result.Occurrences) > 0 {
+			results = append(results, *result)
+		}
+	}
+
+	result, err, warn := newResultFromResource(resource)
+	if warn != nil || err != nil {
+		return
+	}
+	checkUnmountedHostPaths(podSpec, result)
+	if len(result.Occurrences) > 0 {
+		results = append(results, *result)
+	}
+	return
+}
+
+func formatHostPathCatalog(catalog []KubeauditConfigHostPath) string {
+	var lines []string
+	for _, entry := range catalog {
+		lines = append(lines, fmt.Sprintf("  %-36s %-8s (read-only: %s)", entry.Path, entry.Severity, entry.ReadOnlySeverity))
+	}
+	return strings.Join(lines, "\n")
+}
+
+var hostPathCmd = &cobra.Command{
+	Use:   "hostpath",
+	Short: "Audit containers that mount sensitive host paths",
+	Long: fmt.Sprintf(`This command determines which containers, including init containers, mount a
+hostPath volume exposing a sensitive path of the host. The path on the host is
+checked, including the subPath of the mount, so mounting a parent of a
+sensitive path such as /var/run is reported as well as mounting a path below it
+such as /etc/kubernetes. Sensitive hostPath volumes which no container mounts
+are reported too.
+
+The severity depends on whether the volume is mounted read-only. The catalog
+of sensitive paths can be extended or overridden in the config file, and the
+default is:
+
+%s
+
+Example usage:
+kubeaudit hostpath`, formatHostPathCatalog(defaultHostPaths)),
+	Run: runAudit(auditHostPaths),
+}
+
+func init() {
+	RootCmd.AddCommand(hostPathCmd)
+}
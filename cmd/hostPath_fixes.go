@@ -0,0 +1,33 @@
+package cmd
+
+// fixHostPath mounts the sensitive host path read-only when that makes it less dangerous. Mounts of paths which are
+// as dangerous read-only, such as container runtime sockets, are left for the user to remove.
+func fixHostPath(result *Result, resource Resource, occurrence Occurrence) Resource {
+	podSpec, ok := podSpecOf(resource)
+	if !ok {
+		return resource
+	}
+	catalog := hostPathCatalog()
+	fixContainers := func(containers []ContainerV1) (fixed []ContainerV1) {
+		for _, container := range containers {
+			if labelExists, _ := getContainerOverrideLabelReason(result, container, "allow-sensitive-host-path"); occurrence.container == container.Name && !labelExists {
+				for _, mount := range sensitiveHostPathMounts(catalog, podSpec, container) {
+					if !mount.readOnlyHelps || mount.mountPath != occurrence.metadata["MountPath"] {
+						continue
+					}
+					for i := range container.VolumeMounts {
+						if container.VolumeMounts[i].MountPath == mount.mountPath {
+							container.VolumeMounts[i].ReadOnly = true
+						}
+					}
+				}
+			}
+			fixed = append(fixed, container)
+		}
+		return fixed
+	}
+	if len(podSpec.InitContainers) > 0 {
+		resource = setInitContainers(resource, fixContainers(podSpec.InitContainers))
+	}
+	return setContainers(resource, fixContainers(podSpec.Containers))
+}
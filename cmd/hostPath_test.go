@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// byVolume indexes the occurrences by hostPath volume.
+func byVolume(result Result, occ Occurrence) string {
+	return occ.metadata["Volume"]
+}
+
+func TestHostPathSensitiveV1(t *testing.T) {
+	results := runAuditTest(t, "host_path_sensitive_v1.yml", auditHostPaths, []int{ErrorHostPathSensitive})
+	occurrences := indexOccurrences(results, byVolume)
+	assert.Len(t, occurrences, 4)
+
+	// /var/run holds the container runtime sockets, which are as dangerous read-only
+	assert.Equal(t, Error, occurrences["run"][0].kind)
+	assert.Equal(t, "/var/run", occurrences["run"][0].metadata["HostPath"])
+	assert.Contains(t, occurrences["run"][0].metadata["SensitivePath"], "/var/run/")
+
+	assert.Equal(t, Error, occurrences["etc"][0].kind)
+	assert.Equal(t, "/etc/kubernetes", occurrences["etc"][0].metadata["HostPath"])
+	assert.Equal(t, "/etc", occurrences["etc"][0].metadata["SensitivePath"])
+
+	assert.Equal(t, Warn, occurrences["kubelet"][0].kind)
+	assert.Equal(t, "true", occurrences["kubelet"][0].metadata["ReadOnly"])
+
+	// The subPath is part of the path on the host
+	assert.Equal(t, Warn, occurrences["var"][0].kind)
+	assert.Equal(t, "/var/log", occurrences["var"][0].metadata["HostPath"])
+	assert.Equal(t, "logs", occurrences["var"][0].container)
+}
+
+func TestHostPathInitContainersAndUnmountedVolumesV1(t *testing.T) {
+	results := runAuditTest(t, "host_path_init_containers_v1.yml", auditHostPaths, []int{ErrorHostPathSensitive, ErrorHostPathSensitiveUnmounted})
+	occurrences := indexOccurrences(results, byVolume)
+	assert.Len(t, occurrences, 3)
+	assert.Equal(t, ErrorHostPathSensitive, occurrences["root"][0].id)
+	assert.Equal(t, Error, occurrences["root"][0].kind)
+	assert.Equal(t, "install", occurrences["root"][0].container)
+	assert.Equal(t, Info, occurrences["logs"][0].kind)
+	assert.Equal(t, ErrorHostPathSensitiveUnmounted, occurrences["sockets"][0].id)
+	assert.Equal(t, "", occurrences["sockets"][0].container)
+}
+
+func TestHostPathSensitiveAllowedV1(t *testing.T) {
+	runAuditTest(t, "host_path_sensitive_allowed_v1.yml", auditHostPaths, []int{ErrorHostPathSensitiveAllowed, ErrorMisconfiguredKubeauditAllow, ErrorHostPathSensitive})
+}
+
+func TestHostPathsFromConfig(t *testing.T) {
+	rootConfig.auditConfig = "../configs/host_paths_from_config.yml"
+	defer func() { rootConfig.auditConfig = "" }()
+	results := runAuditTest(t, "host_path_sensitive_v1.yml", auditHostPaths, []int{ErrorHostPathSensitive})
+	occurrences := indexOccurrences(results, byVolume)
+	assert.Len(t, occurrences, 4)
+	assert.NotContains(t, occurrences, "kubelet")
+	assert.Equal(t, Warn, occurrences["data"][0].kind)
+}
+
+func TestHostPathExposes(t *testing.T) {
+	assert.True(t, hostPathExposes("/var/run/docker.sock", "/var/run/docker.sock"))
+	assert.True(t, hostPathExposes("/var", "/var/run/docker.sock"))
+	assert.True(t, hostPathExposes("/etc/kubernetes", "/etc"))
+	assert.True(t, hostPathExposes("/", "/etc"))
+	assert.False(t, hostPathExposes("/etcd", "/etc"))
+	assert.False(t, hostPathExposes("/mnt/data", "/"))
+	assert.Equal(t, "/var/run", cleanHostPath("var//run/"))
+}
+
+func TestFixHostPathV1(t *testing.T) {
+	assert, resources := FixTestSetupMultipleResources(t, "host_path_sensitive_v1.yml", auditHostPaths)
+	readOnly := map[string]bool{}
+	for _, container := range getContainers(resources[0]) {
+		for _, mount := range container.VolumeMounts {
+			readOnly[mount.Name] = mount.ReadOnly
+		}
+	}
+	// Mounting the sockets read-only wouldn't help and /mnt/data isn't sensitive
+	assert.False(readOnly["run"])
+	assert.True(readOnly["etc"])
+	assert.True(readOnly["kubelet"])
+	assert.False(readOnly["data"])
+	assert.True(readOnly["var"])
+}
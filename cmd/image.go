@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -10,29 +11,148 @@ import (
 var imgConfig imgFlags
 
 type imgFlags struct {
-	img  string
-	name string
-	tag  string
+	img string
+	ref ImageReference
 }
 
-func (image *imgFlags) splitImageString() {
-	tokens := strings.Split(image.img, ":")
-	if len(tokens) > 0 {
-		image.name = tokens[0]
+// parseImageFlag parses the image given with --image, if any.
+func (image *imgFlags) parseImageFlag() (err error) {
+	if image.img == "" {
+		return nil
 	}
-	if len(tokens) > 1 {
-		image.tag = tokens[1]
+	image.ref, err = parseImageReference(image.img)
+	return err
+}
+
+// defaultForbiddenTags are the tags images can't use when the config doesn't list them.
+var defaultForbiddenTags = []string{"latest"}
+
+// imagePolicy returns the image policy from the config, or the default one.
+func imagePolicy() KubeauditConfigImages {
+	policy := KubeauditConfigImages{ForbiddenTags: defaultForbiddenTags}
+	configured := auditConfigSpec().Images
+	if configured == nil {
+		return policy
+	}
+	policy = *configured
+	if policy.ForbiddenTags == nil {
+		policy.ForbiddenTags = defaultForbiddenTags
+	}
+	return policy
+}
+
+// imageMatchesPattern returns true if the image is in the repository of the pattern or, for a pattern ending with /*,
+// in one of the repositories below it. A registry followed by /*, such as registry.local:5000/*, matches every image of
+// the registry and * matches every image.
+func imageMatchesPattern(ref ImageReference, pattern string) (bool, error) {
+	if pattern == "*" {
+		return true, nil
+	}
+	prefix := strings.HasSuffix(pattern, "/*")
+	name := strings.TrimSuffix(pattern, "/*")
+	if prefix && !strings.Contains(name, "/") && (strings.ContainsAny(name, ".:") || name == "localhost") {
+		return normalizeRegistryHost(name) == ref.Registry, nil
+	}
+	matched, err := parseImageReference(name)
+	if err != nil {
+		return false, err
+	}
+	if prefix {
+		return strings.HasPrefix(ref.Name(), matched.Name()+"/"), nil
+	}
+	return ref.Name() == matched.Name(), nil
+}
+
+// imageRepositoryAllowed returns true if the image is in one of the allowed registries or repositories. A repository
+// ending with /* allows every repository below it. Every image is allowed when the policy doesn't list any.
+func imageRepositoryAllowed(ref ImageReference, policy KubeauditConfigImages) bool {
+	if len(policy.AllowedRegistries) == 0 && len(policy.AllowedRepositories) == 0 {
+		return true
+	}
+	for _, registry := range policy.AllowedRegistries {
+		if ref.Registry == normalizeRegistryHost(registry) {
+			return true
+		}
+	}
+	for _, pattern := range policy.AllowedRepositories {
+		matches, err := imageMatchesPattern(ref, pattern)
+		if err != nil {
+			log.Warnf("Ignoring invalid allowed repository %q: %v", pattern, err)
+			continue
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+func checkImagePolicy(container ContainerV1, ref ImageReference, policy KubeauditConfigImages, result *Result) {
+	metadata := func() Metadata { return Metadata{"Image": container.Image} }
+
+	if !imageRepositoryAllowed(ref, policy) {
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorImageRegistryNotAllowed,
+			kind:      Error,
+			message:   "Image is not from an allowed registry or repository",
+			metadata:  metadata(),
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+
+	if ref.Tag != "" && containsString(policy.ForbiddenTags, ref.Tag) {
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorImageTagForbidden,
+			kind:      Error,
+			message:   fmt.Sprintf("Image tag %s is not allowed, please use a version tag or a digest", ref.Tag),
+			metadata:  metadata(),
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+
+	if policy.RequireDigest && ref.Digest == "" {
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorImageDigestMissing,
+			kind:      Error,
+			message:   "Image is not pinned to a digest, please add @sha256:<digest> to the image",
+			metadata:  metadata(),
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+
+	// Kubernetes defaults the pull policy to Always for the latest tag, so only explicit policies are checked
+	mutable := ref.Digest == "" && (ref.Tag == "" || ref.Tag == "latest")
+	switch {
+	case container.ImagePullPolicy == PullNever:
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorImagePullPolicyInconsistent,
+			kind:      Warn,
+			message:   "imagePullPolicy is Never, the image must already be on the node and any pod can use it without registry credentials",
+			metadata:  Metadata{"Image": container.Image, "ImagePullPolicy": string(container.ImagePullPolicy)},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	case mutable && container.ImagePullPolicy == PullIfNotPresent:
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorImagePullPolicyInconsistent,
+			kind:      Warn,
+			message:   "Image uses the latest tag but imagePullPolicy is IfNotPresent, nodes may run different versions of the image",
+			metadata:  Metadata{"Image": container.Image, "ImagePullPolicy": string(container.ImagePullPolicy)},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
 	}
 }
 
 func checkImage(container ContainerV1, image imgFlags, result *Result) {
-	image.splitImageString()
-	contImage := imgFlags{img: container.Image}
-	contImage.splitImageString()
-	result.ImageName = contImage.name
-	result.ImageTag = contImage.tag
+	ref, err := parseImageReference(container.Image)
+	result.ImageName = ref.FamiliarName()
+	result.ImageTag = ref.Tag
 
-	if len(contImage.tag) == 0 {
+	if err == errEmptyImageReference || (err == nil && ref.Tag == "" && ref.Digest == "") {
 		occ := Occurrence{
 			container: container.Name,
 			id:        ErrorImageTagMissing,
@@ -40,30 +160,44 @@ func checkImage(container ContainerV1, image imgFlags, result *Result) {
 			message:   "Image tag was missing",
 		}
 		result.Occurrences = append(result.Occurrences, occ)
+	}
+	if err == errEmptyImageReference {
 		return
 	}
-
-	if contImage.name == image.name && contImage.tag != image.tag {
+	if err != nil {
 		occ := Occurrence{
 			container: container.Name,
-			id:        ErrorImageTagIncorrect,
+			id:        ErrorImageReferenceInvalid,
 			kind:      Error,
-			message:   "Image tag was incorrect",
+			message:   "Image reference is invalid: " + err.Error(),
+			metadata:  Metadata{"Image": container.Image},
 		}
 		result.Occurrences = append(result.Occurrences, occ)
 		return
 	}
 
-	if contImage.name == image.name && contImage.tag == image.tag {
-		occ := Occurrence{
-			container: container.Name,
-			id:        InfoImageCorrect,
-			kind:      Info,
-			message:   "Image tag was correct",
+	if image.img != "" && ref.Name() == image.ref.Name() && (ref.Tag != "" || ref.Digest != "") {
+		// Only the tag and digest given with --image have to match
+		if (image.ref.Tag != "" && ref.Tag != image.ref.Tag) || (image.ref.Digest != "" && ref.Digest != image.ref.Digest) {
+			occ := Occurrence{
+				container: container.Name,
+				id:        ErrorImageTagIncorrect,
+				kind:      Error,
+				message:   "Image tag was incorrect",
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		} else {
+			occ := Occurrence{
+				container: container.Name,
+				id:        InfoImageCorrect,
+				kind:      Info,
+				message:   "Image tag was correct",
+			}
+			result.Occurrences = append(result.Occurrences, occ)
 		}
-		result.Occurrences = append(result.Occurrences, occ)
-		return
 	}
+
+	checkImagePolicy(container, ref, imagePolicy(), result)
 }
 
 func auditImages(image imgFlags, resource Resource) (results []Result) {
@@ -89,11 +223,19 @@ func auditImages(image imgFlags, resource Resource) (results []Result) {
 var imageCmd = &cobra.Command{
 	Use:   "image",
 	Short: "Audit container images",
-	Long: `This command audits a container against a given image:tag
-An INFO log is given when a container has a matching image:tag
-An ERROR log is generated when a container does not match the image:tag
-This command is also a root command, check kubeaudit sc --help
+	Long: `This command audits container images against the image policy of the
+config file, and optionally against a given image:tag.
+
+The policy can restrict images to allowed registries and repositories, forbid
+tags (latest by default), and require images to be pinned to a digest. A
+WARN is generated when an image has no tag, when imagePullPolicy is Never, or
+when it is IfNotPresent for the latest tag.
+
+With --image, an INFO log is given when a container has a matching image:tag
+and an ERROR log is generated when a container runs another tag of the image.
+
 Example usage:
+kubeaudit image
 kubeaudit image --image gcr.io/google_containers/echoserver:1.7
 kubeaudit image -i gcr.io/google_containers/echoserver:1.7`,
 	Run: runAudit(auditImages),
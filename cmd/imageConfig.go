@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// maxReportedSetuidFiles is how many setuid files are listed in the metadata of a finding.
+const maxReportedSetuidFiles = 10
+
+type inspectFlags struct {
+	sources   []string
+	offline   bool
+	setuid    bool
+	platform  string
+	inspector *imageInspector
+}
+
+var inspectConfig inspectFlags
+
+// imageInspection is what was found out about an image.
+type imageInspection struct {
+	image  *inspectedImage
+	source string
+	setuid []string
+	err    error
+}
+
+// imageInspector inspects images from the first source which has them, and remembers what it found since images are
+// usually shared by several containers.
+type imageInspector struct {
+	sources []imageSource
+	setuid  bool
+	mutex   sync.Mutex
+	// inspections holds an entry for every image asked for, so that an image is inspected once even when containers ask
+	// for it concurrently, without holding the mutex while fetching it
+	inspections map[string]*inspectionEntry
+}
+
+type inspectionEntry struct {
+	once       sync.Once
+	inspection *imageInspection
+}
+
+// loadSources sets up the local images given with --image-source followed, unless --offline is given, by the
+// registries of the images.
+func (flags *inspectFlags) loadSources() error {
+	inspector := &imageInspector{setuid: flags.setuid, inspections: map[string]*inspectionEntry{}}
+	for _, name := range flags.sources {
+		source, err := newLocalSource(name)
+		if err != nil {
+			return err
+		}
+		source.platform = flags.platform
+		inspector.sources = append(inspector.sources, source)
+	}
+	if !flags.offline {
+		config, err := loadDockerConfig(rootConfig.dockerConfig)
+		if err != nil {
+			return err
+		}
+		inspector.sources = append(inspector.sources, registrySource{registry: newRegistryClient(nil, config), platform: flags.platform})
+	}
+	if len(inspector.sources) == 0 {
+		return fmt.Errorf("no image source, use --image-source or leave out --offline")
+	}
+	flags.inspector = inspector
+	return nil
+}
+
+func (inspector *imageInspector) inspect(ref ImageReference, digest string) *imageInspection {
+	key := ref.String() + "@" + digest
+	inspector.mutex.Lock()
+	entry, ok := inspector.inspections[key]
+	if !ok {
+		entry = &inspectionEntry{}
+		inspector.inspections[key] = entry
+	}
+	inspector.mutex.Unlock()
+
+	entry.once.Do(func() {
+		entry.inspection = inspector.inspectSources(ref, digest)
+	})
+	return entry.inspection
+}
+
+// inspectSources inspects the image from the first source which has it.
+func (inspector *imageInspector) inspectSources(ref ImageReference, digest string) *imageInspection {
+	inspection := &imageInspection{err: fmt.Errorf("image not found in any source")}
+	for _, source := range inspector.sources {
+		image, err := source.inspect(ref, digest)
+		if err == errImageNotFound {
+			continue
+		}
+		if err != nil {
+			inspection.err = fmt.Errorf("%s: %v", source, err)
+			continue
+		}
+		inspection = &imageInspection{image: image, source: source.String()}
+		if inspector.setuid {
+			inspection.setuid, inspection.err = setuidFiles(image)
+		}
+		break
+	}
+	return inspection
+}
+
+// imageUser parses the USER of an image config, which is a user and an optional group, each a name or an ID. An empty
+// user is root. It returns the UID, or false if the user is a name other than root.
+func imageUser(user string) (int64, bool) {
+	if i := strings.Index(user, ":"); i >= 0 {
+		user = user[:i]
+	}
+	if user == "" || user == "root" {
+		return 0, true
+	}
+	uid, err := strconv.ParseInt(user, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uid, true
+}
+
+// effectiveCommand returns the command a container runs, which is the entrypoint and cmd of its image unless the
+// container overrides them with its command and args.
+func effectiveCommand(container ContainerV1, config imageConfigFile) string {
+	command := config.Config.Entrypoint
+	args := config.Config.Cmd
+	if len(container.Command) > 0 {
+		command, args = container.Command, nil
+	}
+	if len(container.Args) > 0 {
+		args = container.Args
+	}
+	return strings.Join(append(append([]string{}, command...), args...), " ")
+}
+
+func checkImageConfig(podSpec PodSpecV1, container ContainerV1, runningDigest string, inspector *imageInspector, result *Result) {
+	ref, err := parseImageReference(container.Image)
+	if err != nil {
+		// Invalid image references are reported by the image audit
+		return
+	}
+	digest := ref.Digest
+	if digest == "" {
+		digest = runningDigest
+	}
+	inspection := inspector.inspect(ref, digest)
+	if inspection.image == nil || inspection.err != nil {
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorImageInspectionFailed,
+			kind:      Warn,
+			message:   "Unable to inspect the image: " + inspection.err.Error(),
+			metadata:  Metadata{"Image": container.Image},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+		if inspection.image == nil {
+			return
+		}
+	}
+
+	config := inspection.image.config
+	metadata := func() Metadata {
+		return Metadata{
+			"Image":     container.Image,
+			"Source":    inspection.source,
+			"ImageUser": config.Config.User,
+			"Command":   effectiveCommand(container, config),
+		}
+	}
+	securityContext := effectiveSecurityContext(podSpec, container)
+	runAsNonRoot := securityContext.RunAsNonRoot != nil && *securityContext.RunAsNonRoot
+	uid, numeric := imageUser(config.Config.User)
+	allowedRoot, _ := getContainerOverrideLabelReason(result, container, "allow-run-as-root")
+
+	// The image user only matters when the container doesn't run as a user of its own
+	switch {
+	case securityContext.RunAsUser != nil:
+	case !numeric:
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorImageUserNotNumeric,
+			kind:      Warn,
+			message:   fmt.Sprintf("Image runs as user %q and runAsUser isn't set, so it's unknown whether it runs as root", config.Config.User),
+			metadata:  metadata(),
+		}
+		if runAsNonRoot {
+			occ.message = fmt.Sprintf("Image runs as user %q which isn't numeric, the container will not start with runAsNonRoot", config.Config.User)
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	case uid == 0 && !allowedRoot:
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorImageRunsAsRoot,
+			kind:      Error,
+			message:   "Image runs as root and runAsUser isn't set, so the container runs as root. Please set runAsUser",
+			metadata:  metadata(),
+		}
+		if runAsNonRoot {
+			occ.message = "Image runs as root and runAsUser isn't set, the container will not start with runAsNonRoot. Please set runAsUser"
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	case uid != 0:
+		occ := Occurrence{
+			container: container.Name,
+			id:        InfoImageRunsAsNonRoot,
+			kind:      Info,
+			message:   fmt.Sprintf("Image runs as UID %d", uid),
+			metadata:  metadata(),
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+
+	// Setuid binaries can't raise the privileges of processes which aren't allowed to escalate them
+	allowPrivilegeEscalation := securityContext.AllowPrivilegeEscalation == nil || *securityContext.AllowPrivilegeEscalation
+	if len(inspection.setuid) > 0 && allowPrivilegeEscalation {
+		files := inspection.setuid
+		if len(files) > maxReportedSetuidFiles {
+			files = files[:maxReportedSetuidFiles]
+		}
+		metadata := metadata()
+		metadata["Files"] = strings.Join(files, ", ")
+		metadata["Count"] = strconv.Itoa(len(inspection.setuid))
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorImageSetuidFiles,
+			kind:      Warn,
+			message:   "Image has setuid or setgid binaries, please set allowPrivilegeEscalation to false",
+			metadata:  metadata,
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+}
+
+func auditImageConfig(flags inspectFlags, resource Resource) (results []Result) {
+	podSpec, ok := podSpecOf(resource)
+	if !ok || flags.inspector == nil {
+		return
+	}
+	runningDigests := runningImageDigests(resource)
+	for _, container := range getContainers(resource) {
+		result, err, warn := newResultFromResource(resource)
+		if warn != nil {
+			log.Warn(warn)
+			return
+		}
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		checkImageConfig(podSpec, container, runningDigests[container.Name], flags.inspector, result)
+		if len(result.Occurrences) > 0 {
+			results = append(results, *result)
+		}
+	}
+	return
+}
+
+var imageConfigCmd = &cobra.Command{
+	Use:   "imageconfig",
+	Short: "Audit the user containers run as according to their image config",
+	Long: `This command reads the config of the image of every container to find out
+whether containers which don't set runAsUser run as root. Images are read from
+the OCI layouts, OCI archives or docker save tarballs given with --image-source
+and then from their registry, unless --offline is given.
+
+An ERROR is generated when the image runs as root and neither the container nor
+the pod sets runAsUser, which also keeps containers with runAsNonRoot from
+starting. A WARN is generated when the image user is a name, since kubelet can't
+tell whether it's root. An INFO tells the UID of images running as another user.
+
+With --setuid the layers of the images are scanned for setuid and setgid
+binaries, which are reported for containers allowed to escalate privileges.
+
+Example usage:
+kubeaudit imageconfig
+kubeaudit imageconfig --offline --image-source ./images/app.tar --setuid`,
+	Run: runAudit(auditImageConfig),
+}
+
+func init() {
+	RootCmd.AddCommand(imageConfigCmd)
+	imageConfigCmd.Flags().StringSliceVar(&inspectConfig.sources, "image-source", nil, "OCI layout directory, OCI archive or docker save tarball to read images from, can be repeated")
+	imageConfigCmd.Flags().BoolVar(&inspectConfig.offline, "offline", false, "Only read images from the image sources, never from registries")
+	imageConfigCmd.Flags().BoolVar(&inspectConfig.setuid, "setuid", false, "Scan the layers of images for setuid and setgid binaries")
+	imageConfigCmd.Flags().StringVar(&inspectConfig.platform, "platform", "linux/amd64", "Platform of the image to inspect for multi-platform images")
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLayerFile struct {
+	name string
+	mode int64
+}
+
+func testLayer(t *testing.T, compress bool, files ...testLayerFile) []byte {
+	var buffer bytes.Buffer
+	var tw *tar.Writer
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buffer)
+		tw = tar.NewWriter(gz)
+	} else {
+		tw = tar.NewWriter(&buffer)
+	}
+	for _, file := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: file.name, Mode: file.mode, Typeflag: tar.TypeReg}))
+	}
+	require.NoError(t, tw.Close())
+	if gz != nil {
+		require.NoError(t, gz.Close())
+	}
+	return buffer.Bytes()
+}
+
+func testImageConfig(user string) []byte {
+	return []byte(fmt.Sprintf(`{"architecture":"amd64","os":"linux","config":{"User":%q,"Entrypoint":["/entrypoint.sh"],"Cmd":["serve"]}}`, user))
+}
+
+func sha256Digest(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+// writeTestOCILayout writes an OCI layout with an index of registry.local/root:1.0, an image running as root with
+// setuid binaries, one of which is deleted by its upper layer.
+func writeTestOCILayout(t *testing.T, dir string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755))
+	writeBlob := func(data []byte) string {
+		digest := sha256Digest(data)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "blobs", "sha256", strings.TrimPrefix(digest, "sha256:")), data, 0644))
+		return digest
+	}
+	config := writeBlob(testImageConfig(""))
+	lower := writeBlob(testLayer(t, true,
+		testLayerFile{name: "usr/bin/sudo", mode: 04755},
+		testLayerFile{name: "bin/ping", mode: 04755},
+		testLayerFile{name: "usr/bin/wall", mode: 02755},
+		testLayerFile{name: "usr/bin/env", mode: 0755}))
+	upper := writeBlob(testLayer(t, true, testLayerFile{name: "bin/.wh.ping", mode: 0644}))
+	manifest := writeBlob([]byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",
+"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":%q},
+"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":%q},{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":%q}]}`,
+		config, lower, upper)))
+	armManifest := writeBlob([]byte(`{"schemaVersion":2,"config":{"digest":"sha256:0000000000000000000000000000000000000000000000000000000000000000"},"layers":[]}`))
+	index := writeBlob([]byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[
+{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":%q,"platform":{"os":"linux","architecture":"arm64"}},
+{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":%q,"platform":{"os":"linux","architecture":"amd64"}}]}`,
+		armManifest, manifest)))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "index.json"), []byte(fmt.Sprintf(`{"schemaVersion":2,"manifests":[
+{"mediaType":"application/vnd.oci.image.index.v1+json","digest":%q,"annotations":{"org.opencontainers.image.ref.name":"registry.local/root:1.0"}}]}`,
+		index)), 0644))
+}
+
+// writeTestDockerArchive writes a docker save tarball of registry.local/non-root:2.0, an image running as UID 1000.
+func writeTestDockerArchive(t *testing.T, filename string) {
+	f, err := os.Create(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	add := func(name string, data []byte) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(data)
+		require.NoError(t, err)
+	}
+	add("config.json", testImageConfig("1000:1000"))
+	add("layer/layer.tar", testLayer(t, false, testLayerFile{name: "usr/bin/passwd", mode: 04755}))
+	add("manifest.json", []byte(`[{"Config":"config.json","RepoTags":["registry.local/non-root:2.0"],"Layers":["layer/layer.tar"]}]`))
+	require.NoError(t, tw.Close())
+}
+
+// newTestImageRegistry starts a registry serving team/named:1.0, an image running as a named user.
+func newTestImageRegistry(t *testing.T) *httptest.Server {
+	content := map[string][]byte{}
+	config := testImageConfig("app")
+	layer := testLayer(t, true, testLayerFile{name: "app", mode: 0755})
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"config":{"digest":%q},"layers":[{"digest":%q}]}`, sha256Digest(config), sha256Digest(layer)))
+	content["/v2/team/named/manifests/1.0"] = manifest
+	content["/v2/team/named/blobs/"+sha256Digest(config)] = config
+	content["/v2/team/named/blobs/"+sha256Digest(layer)] = layer
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := content[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}))
+}
+
+func TestImageConfigV1(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeaudit_image_config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	layout := filepath.Join(dir, "layout")
+	writeTestOCILayout(t, layout)
+	archive := filepath.Join(dir, "non-root.tar")
+	writeTestDockerArchive(t, archive)
+	server := newTestImageRegistry(t)
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "http://")
+
+	data, err := ioutil.ReadFile("../fixtures/image_config_v1.yml")
+	require.NoError(t, err)
+	manifestFile := filepath.Join(dir, "image_config_v1.yml")
+	require.NoError(t, ioutil.WriteFile(manifestFile, []byte(strings.Replace(string(data), "REGISTRY", registry, -1)), 0644))
+	resources, err := getKubeResourcesManifest(manifestFile)
+	require.NoError(t, err)
+
+	flags := inspectFlags{sources: []string{layout, archive}, setuid: true, platform: "linux/amd64"}
+	require.NoError(t, flags.loadSources())
+	occurrences := indexOccurrences(auditImageConfig(flags, resources[0]), byContainer)
+
+	root := occurrences["root"]
+	require.Len(t, root, 2)
+	assert.Equal(t, ErrorImageRunsAsRoot, root[0].id)
+	assert.Equal(t, layout, root[0].metadata["Source"])
+	assert.Equal(t, "/entrypoint.sh serve", root[0].metadata["Command"])
+	assert.Equal(t, ErrorImageSetuidFiles, root[1].id)
+	// ping is deleted by the upper layer
+	assert.Equal(t, "/usr/bin/sudo, /usr/bin/wall", root[1].metadata["Files"])
+	assert.NotContains(t, occurrences, "root-overridden")
+	require.Len(t, occurrences["root-non-root"], 1)
+	assert.Equal(t, ErrorImageRunsAsRoot, occurrences["root-non-root"][0].id)
+	assert.Contains(t, occurrences["root-non-root"][0].message, "will not start")
+
+	nonRoot := occurrences["non-root"]
+	require.Len(t, nonRoot, 2)
+	assert.Equal(t, InfoImageRunsAsNonRoot, nonRoot[0].id)
+	assert.Equal(t, "Image runs as UID 1000", nonRoot[0].message)
+	assert.Equal(t, "/usr/bin/passwd", nonRoot[1].metadata["Files"])
+
+	require.Len(t, occurrences["named"], 1)
+	assert.Equal(t, ErrorImageUserNotNumeric, occurrences["named"][0].id)
+	assert.Equal(t, "registry", occurrences["named"][0].metadata["Source"])
+	assert.Equal(t, "/app", occurrences["named"][0].metadata["Command"])
+	// The image ID the pod reports without a repository isn't the digest of a manifest, so the image is found by its tag
+	require.Len(t, occurrences["bare-id"], 1)
+	assert.Equal(t, ErrorImageUserNotNumeric, occurrences["bare-id"][0].id)
+
+	require.Len(t, occurrences["missing"], 1)
+	assert.Equal(t, ErrorImageInspectionFailed, occurrences["missing"][0].id)
+
+	// Offline, only the local images are inspected
+	flags = inspectFlags{sources: []string{layout}, offline: true, platform: "linux/amd64"}
+	require.NoError(t, flags.loadSources())
+	inspection := flags.inspector.inspect(ImageReference{Registry: registry, Repository: "team/named", Tag: "1.0"}, "")
+	assert.Error(t, inspection.err)
+	inspection = flags.inspector.inspect(ImageReference{Registry: "registry.local", Repository: "root", Tag: "1.0"}, "")
+	assert.NoError(t, inspection.err)
+	assert.Empty(t, inspection.setuid)
+}
+
+func TestImageConfigOCIArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeaudit_image_config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	layout := filepath.Join(dir, "layout")
+	writeTestOCILayout(t, layout)
+
+	// An OCI archive is a tarball of an OCI layout
+	archive := filepath.Join(dir, "root.tar")
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+	tw := tar.NewWriter(f)
+	require.NoError(t, filepath.Walk(layout, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(layout, name)
+		if err := tw.WriteHeader(&tar.Header{Name: filepath.ToSlash(rel), Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}))
+	require.NoError(t, tw.Close())
+	require.NoError(t, f.Close())
+
+	source, err := newLocalSource(archive)
+	require.NoError(t, err)
+	source.platform = "linux/amd64"
+	image, err := source.inspect(ImageReference{Registry: "registry.local", Repository: "root", Tag: "1.0"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "", image.config.Config.User)
+	files, err := setuidFiles(image)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/usr/bin/sudo", "/usr/bin/wall"}, files)
+
+	_, err = source.inspect(ImageReference{Registry: "registry.local", Repository: "root", Tag: "2.0"}, "")
+	assert.Equal(t, errImageNotFound, err)
+	source.platform = "linux/s390x"
+	_, err = source.inspect(ImageReference{Registry: "registry.local", Repository: "root", Tag: "1.0"}, "")
+	assert.Error(t, err)
+}
+
+func TestScanLayerWhiteouts(t *testing.T) {
+	files := map[string]bool{}
+	lower := testLayer(t, false, testLayerFile{name: "usr/bin/sudo", mode: 04755}, testLayerFile{name: "usr/sbin/mount", mode: 04755},
+		testLayerFile{name: "bin/ping", mode: 04755})
+	require.NoError(t, scanLayer(bytes.NewReader(lower), files))
+
+	// The whiteouts of a layer come after its own files here, they only hide the files of the layers below
+	upper := testLayer(t, true, testLayerFile{name: "usr/bin/newsu", mode: 04755}, testLayerFile{name: "usr/bin/.wh..wh..opq", mode: 0644},
+		testLayerFile{name: "bin/.wh.ping", mode: 0644})
+	require.NoError(t, scanLayer(bytes.NewReader(upper), files))
+	assert.Equal(t, map[string]bool{"/usr/bin/newsu": true, "/usr/sbin/mount": true}, files)
+}
+
+func TestImageUser(t *testing.T) {
+	for user, expected := range map[string]struct {
+		uid     int64
+		numeric bool
+	}{
+		"":          {0, true},
+		"root":      {0, true},
+		"0:0":       {0, true},
+		"root:root": {0, true},
+		"1000":      {1000, true},
+		"1000:1000": {1000, true},
+		"nginx":     {0, false},
+		"app:app":   {0, false},
+	} {
+		uid, numeric := imageUser(user)
+		assert.Equal(t, expected.uid, uid, user)
+		assert.Equal(t, expected.numeric, numeric, user)
+	}
+}
+
+func TestEffectiveCommand(t *testing.T) {
+	var config imageConfigFile
+	require.NoError(t, json.Unmarshal(testImageConfig(""), &config))
+	assert.Equal(t, "/entrypoint.sh serve", effectiveCommand(ContainerV1{}, config))
+	assert.Equal(t, "/entrypoint.sh debug", effectiveCommand(ContainerV1{Args: []string{"debug"}}, config))
+	assert.Equal(t, "/app", effectiveCommand(ContainerV1{Command: []string{"/app"}}, config))
+	assert.Equal(t, "/app run", effectiveCommand(ContainerV1{Command: []string{"/app"}, Args: []string{"run"}}, config))
+}
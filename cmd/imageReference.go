@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultRegistry is the registry of images whose name doesn't start with one.
+const DefaultRegistry = "docker.io"
+
+var (
+	// Grammar of image references, see https://github.com/distribution/distribution/blob/main/reference/reference.go.
+	// Upper case letters are accepted in repositories, leaving it to the container runtime to reject them.
+	registryRegexp         = regexp.MustCompile(`^(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?$|^\[[a-fA-F0-9:]+\](?::[0-9]+)?$`)
+	pathComponentRegexp    = regexp.MustCompile(`^[a-zA-Z0-9]+(?:(?:[._]|__|[-]*)[a-zA-Z0-9]+)*$`)
+	tagRegexp              = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestRegexp           = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+	sha256DigestRegexp     = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	maxRepositoryLength    = 255
+	errEmptyImageReference = fmt.Errorf("image reference is empty")
+)
+
+// ImageReference is a parsed container image reference of the form [registry/]repository[:tag][@digest].
+type ImageReference struct {
+	// Registry is the host, and optional port, of the registry. It is DefaultRegistry when the reference doesn't
+	// name one.
+	Registry string
+	// Repository is the path of the image in the registry. Official images of the default registry are in library/.
+	Repository string
+	// Tag is empty when the reference doesn't have one, in which case the runtime pulls latest unless it has a digest.
+	Tag string
+	// Digest is the content addressable digest of the image, e.g. sha256:..., or empty.
+	Digest string
+	// familiarName is the name as written in the reference, without the tag or digest.
+	familiarName string
+}
+
+// parseImageReference parses an image reference the way container runtimes do, so that a registry with a port isn't
+// mistaken for a tag and digests are taken into account.
+func parseImageReference(image string) (ImageReference, error) {
+	var ref ImageReference
+	if image == "" {
+		return ref, errEmptyImageReference
+	}
+
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+		if !digestRegexp.MatchString(ref.Digest) {
+			return ref, fmt.Errorf("invalid digest %q in image reference %q", ref.Digest, image)
+		}
+		if strings.HasPrefix(ref.Digest, "sha256:") && !sha256DigestRegexp.MatchString(ref.Digest) {
+			return ref, fmt.Errorf("invalid sha256 digest %q in image reference %q", ref.Digest, image)
+		}
+	}
+
+	// The tag follows the last colon unless it belongs to the registry's port, which is followed by a slash
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i+1:], "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+		if !tagRegexp.MatchString(ref.Tag) {
+			return ref, fmt.Errorf("invalid tag %q in image reference %q", ref.Tag, image)
+		}
+	}
+	if name == "" {
+		return ref, fmt.Errorf("image reference %q has no repository", image)
+	}
+	ref.familiarName = name
+
+	// The first component is a registry if it looks like a host name rather than part of the repository
+	repository := name
+	if i := strings.Index(name, "/"); i >= 0 {
+		first := name[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" || strings.HasPrefix(first, "[") {
+			if !registryRegexp.MatchString(first) {
+				return ref, fmt.Errorf("invalid registry %q in image reference %q", first, image)
+			}
+			ref.Registry, repository = first, name[i+1:]
+		}
+	}
+	for _, component := range strings.Split(repository, "/") {
+		if !pathComponentRegexp.MatchString(component) {
+			return ref, fmt.Errorf("invalid repository %q in image reference %q", repository, image)
+		}
+	}
+	if len(repository) > maxRepositoryLength {
+		return ref, fmt.Errorf("repository of image reference %q is longer than %d characters", image, maxRepositoryLength)
+	}
+
+	if ref.Registry == "" || ref.Registry == "index.docker.io" {
+		ref.Registry = DefaultRegistry
+	}
+	if ref.Registry == DefaultRegistry && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	ref.Repository = repository
+	return ref, nil
+}
+
+// Name returns the fully qualified name of the image, without the tag or digest.
+func (ref ImageReference) Name() string {
+	return ref.Registry + "/" + ref.Repository
+}
+
+// FamiliarName returns the name of the image as it was written, without the tag or digest.
+func (ref ImageReference) FamiliarName() string {
+	return ref.familiarName
+}
+
+// String returns the fully qualified reference.
+func (ref ImageReference) String() string {
+	s := ref.Name()
+	if ref.Tag != "" {
+		s += ":" + ref.Tag
+	}
+	if ref.Digest != "" {
+		s += "@" + ref.Digest
+	}
+	return s
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImageReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	cases := []struct {
+		image string
+		want  ImageReference
+	}{
+		{"nginx", ImageReference{Registry: "docker.io", Repository: "library/nginx"}},
+		{"nginx:1.17", ImageReference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.17"}},
+		{"bitnami/redis:5.0", ImageReference{Registry: "docker.io", Repository: "bitnami/redis", Tag: "5.0"}},
+		{"registry.local:5000/app", ImageReference{Registry: "registry.local:5000", Repository: "app"}},
+		{"registry.local:5000/team/app:v2", ImageReference{Registry: "registry.local:5000", Repository: "team/app", Tag: "v2"}},
+		{"localhost/app:dev", ImageReference{Registry: "localhost", Repository: "app", Tag: "dev"}},
+		{"gcr.io/project/app@" + digest, ImageReference{Registry: "gcr.io", Repository: "project/app", Digest: digest}},
+		{"gcr.io/project/app:1.0@" + digest, ImageReference{Registry: "gcr.io", Repository: "project/app", Tag: "1.0", Digest: digest}},
+		{"index.docker.io/library/nginx:1.17", ImageReference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.17"}},
+	}
+	for _, c := range cases {
+		ref, err := parseImageReference(c.image)
+		require.NoError(t, err, c.image)
+		ref.familiarName = ""
+		assert.Equal(t, c.want, ref, c.image)
+	}
+
+	ref, err := parseImageReference("registry.local:5000/app:1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "registry.local:5000/app", ref.FamiliarName())
+	assert.Equal(t, "registry.local:5000/app:1.0", ref.String())
+}
+
+func TestParseImageReferenceInvalid(t *testing.T) {
+	for _, image := range []string{
+		":1.0",
+		"nginx:",
+		"nginx:-1",
+		"nginx@sha256:abc",
+		"nginx@" + "sha256:" + strings.Repeat("g", 64),
+		"registry_local:5000/app",
+		"app//name",
+		"-app",
+	} {
+		_, err := parseImageReference(image)
+		assert.Error(t, err, image)
+	}
+	_, err := parseImageReference("")
+	assert.Equal(t, errEmptyImageReference, err)
+}
+
+func TestImageRepositoryAllowed(t *testing.T) {
+	policy := KubeauditConfigImages{
+		AllowedRegistries:   []string{"registry.local:5000"},
+		AllowedRepositories: []string{"nginx", "gcr.io/project/*"},
+	}
+	for image, allowed := range map[string]bool{
+		"nginx:1.17":                  true,
+		"docker.io/library/nginx:1.0": true,
+		"redis:5.0":                   false,
+		"registry.local:5000/app":     true,
+		"registry.local/app":          false,
+		"gcr.io/project/app":          true,
+		"gcr.io/project-other/app":    false,
+		"gcr.io/project":              false,
+	} {
+		ref, err := parseImageReference(image)
+		require.NoError(t, err)
+		assert.Equal(t, allowed, imageRepositoryAllowed(ref, policy), image)
+	}
+	assert.True(t, imageRepositoryAllowed(ImageReference{Registry: "any", Repository: "app"}, KubeauditConfigImages{}))
+}
@@ -0,0 +1,449 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// errImageNotFound is returned by an image source which doesn't have the image.
+var errImageNotFound = errors.New("image not found")
+
+// imageConfigFile is the part of the config of an image, see
+// https://github.com/opencontainers/image-spec/blob/main/config.md, which tells how its containers run.
+type imageConfigFile struct {
+	Config struct {
+		User       string   `json:"User"`
+		Entrypoint []string `json:"Entrypoint"`
+		Cmd        []string `json:"Cmd"`
+	} `json:"config"`
+}
+
+// ociDescriptor points to a manifest, config or layer blob.
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+	Platform    *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	} `json:"platform"`
+}
+
+// ociManifest is an image manifest or, when it has manifests, an image index or manifest list.
+type ociManifest struct {
+	Manifests []ociDescriptor `json:"manifests"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// inspectedImage is the config of an image along with a way to stream its layers, from the bottom one to the top one.
+type inspectedImage struct {
+	config imageConfigFile
+	layers []func() (io.ReadCloser, error)
+}
+
+// imageSource finds the config and layers of images. It returns errImageNotFound when it doesn't have the image.
+type imageSource interface {
+	inspect(ref ImageReference, digest string) (*inspectedImage, error)
+	String() string
+}
+
+// selectPlatform returns the manifest of the index for the platform, given as os/architecture[/variant].
+func selectPlatform(index ociManifest, platform string) (ociDescriptor, error) {
+	for _, descriptor := range index.Manifests {
+		if descriptor.Platform == nil {
+			continue
+		}
+		p := descriptor.Platform.OS + "/" + descriptor.Platform.Architecture
+		if p == platform || (descriptor.Platform.Variant != "" && p+"/"+descriptor.Platform.Variant == platform) {
+			return descriptor, nil
+		}
+	}
+	return ociDescriptor{}, fmt.Errorf("the image has no manifest for platform %s", platform)
+}
+
+// registrySource inspects images in their registry.
+type registrySource struct {
+	registry *registryClient
+	platform string
+}
+
+func (source registrySource) String() string {
+	return "registry"
+}
+
+func (source registrySource) inspect(ref ImageReference, digest string) (*inspectedImage, error) {
+	reference := ref.Tag
+	if digest != "" {
+		reference = digest
+	} else if reference == "" {
+		reference = "latest"
+	}
+	data, err := source.registry.fetchManifest(ref, reference)
+	if err == errRegistryNotFound {
+		return nil, errImageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing the manifest of %s: %v", ref.Name(), err)
+	}
+	if len(manifest.Manifests) > 0 {
+		descriptor, err := selectPlatform(manifest, source.platform)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = source.registry.fetchManifest(ref, descriptor.Digest); err != nil {
+			return nil, err
+		}
+		manifest = ociManifest{}
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("parsing the manifest of %s: %v", ref.Name(), err)
+		}
+	}
+
+	data, err = source.registry.fetchBlob(ref, manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	image := &inspectedImage{}
+	if err := json.Unmarshal(data, &image.config); err != nil {
+		return nil, fmt.Errorf("parsing the config of %s: %v", ref.Name(), err)
+	}
+	for _, layer := range manifest.Layers {
+		digest := layer.Digest
+		image.layers = append(image.layers, func() (io.ReadCloser, error) { return source.registry.openBlob(ref, digest) })
+	}
+	return image, nil
+}
+
+// fileSystem reads the files of an OCI layout or of a docker archive.
+type fileSystem interface {
+	open(name string) (io.ReadCloser, error)
+}
+
+// dirFileSystem reads files from a directory.
+type dirFileSystem string
+
+func (dir dirFileSystem) open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(dir), filepath.FromSlash(name)))
+}
+
+// tarFileSystem reads files from a tarball, such as the ones written by docker save or skopeo. The tarball is read again
+// for every file so that the layers don't need to fit in memory.
+type tarFileSystem string
+
+type tarFile struct {
+	io.Reader
+	file *os.File
+}
+
+func (f tarFile) Close() error {
+	return f.file.Close()
+}
+
+func (archive tarFileSystem) open(name string) (io.ReadCloser, error) {
+	f, err := os.Open(string(archive))
+	if err != nil {
+		return nil, err
+	}
+	reader := tar.NewReader(f)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			f.Close()
+			return nil, os.ErrNotExist
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if cleanArchivePath(header.Name) == cleanArchivePath(name) {
+			return tarFile{Reader: reader, file: f}, nil
+		}
+	}
+}
+
+// cleanArchivePath returns the absolute slash separated path of a file in an archive or a layer.
+func cleanArchivePath(name string) string {
+	return filepath.ToSlash(filepath.Clean("/" + name))
+}
+
+func readFile(fs fileSystem, name string) ([]byte, error) {
+	f, err := fs.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// localSource inspects images of an OCI layout, or of a docker archive written by docker save, in a directory or a
+// tarball.
+type localSource struct {
+	name     string
+	fs       fileSystem
+	platform string
+}
+
+func newLocalSource(name string) (localSource, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return localSource{}, err
+	}
+	if info.IsDir() {
+		return localSource{name: name, fs: dirFileSystem(name)}, nil
+	}
+	return localSource{name: name, fs: tarFileSystem(name)}, nil
+}
+
+func (source localSource) String() string {
+	return source.name
+}
+
+func (source localSource) inspect(ref ImageReference, digest string) (*inspectedImage, error) {
+	// Docker archives have a manifest.json listing the images with their tags, OCI layouts have an index.json
+	data, err := readFile(source.fs, "manifest.json")
+	if err == nil {
+		return source.inspectDockerArchive(data, ref)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	data, err = readFile(source.fs, "index.json")
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither an OCI layout nor a docker archive: %v", source.name, err)
+	}
+	return source.inspectOCILayout(data, ref, digest)
+}
+
+// ociRefMatches returns true if the descriptor of the index of an OCI layout is the image. The ref.name annotation
+// may be a full reference or only a tag.
+func ociRefMatches(descriptor ociDescriptor, ref ImageReference, digest string) bool {
+	if digest != "" && descriptor.Digest == digest {
+		return true
+	}
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	for _, annotation := range []string{"io.containerd.image.name", "org.opencontainers.image.ref.name"} {
+		name, ok := descriptor.Annotations[annotation]
+		if !ok {
+			continue
+		}
+		if !strings.ContainsAny(name, "/:@") {
+			if name == tag {
+				return true
+			}
+			continue
+		}
+		named, err := parseImageReference(name)
+		if err == nil && named.Name() == ref.Name() && named.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func ociBlobPath(digest string) (string, error) {
+	if !sha256DigestRegexp.MatchString(digest) {
+		return "", fmt.Errorf("unsupported blob digest %q", digest)
+	}
+	return "blobs/sha256/" + strings.TrimPrefix(digest, "sha256:"), nil
+}
+
+func (source localSource) readBlob(digest string) ([]byte, error) {
+	blobPath, err := ociBlobPath(digest)
+	if err != nil {
+		return nil, err
+	}
+	return readFile(source.fs, blobPath)
+}
+
+func (source localSource) inspectOCILayout(data []byte, ref ImageReference, digest string) (*inspectedImage, error) {
+	var index ociManifest
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("parsing the index of %s: %v", source.name, err)
+	}
+	var found *ociDescriptor
+	for i := range index.Manifests {
+		if ociRefMatches(index.Manifests[i], ref, digest) {
+			found = &index.Manifests[i]
+			break
+		}
+	}
+	if found == nil {
+		return nil, errImageNotFound
+	}
+
+	data, err := source.readBlob(found.Digest)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest %s of %s: %v", found.Digest, source.name, err)
+	}
+	if len(manifest.Manifests) > 0 {
+		descriptor, err := selectPlatform(manifest, source.platform)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = source.readBlob(descriptor.Digest); err != nil {
+			return nil, err
+		}
+		manifest = ociManifest{}
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("parsing manifest %s of %s: %v", descriptor.Digest, source.name, err)
+		}
+	}
+
+	data, err = source.readBlob(manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	image := &inspectedImage{}
+	if err := json.Unmarshal(data, &image.config); err != nil {
+		return nil, fmt.Errorf("parsing config %s of %s: %v", manifest.Config.Digest, source.name, err)
+	}
+	for _, layer := range manifest.Layers {
+		blobPath, err := ociBlobPath(layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		image.layers = append(image.layers, func() (io.ReadCloser, error) { return source.fs.open(blobPath) })
+	}
+	return image, nil
+}
+
+func (source localSource) inspectDockerArchive(data []byte, ref ImageReference) (*inspectedImage, error) {
+	var manifests []struct {
+		Config   string   `json:"Config"`
+		RepoTags []string `json:"RepoTags"`
+		Layers   []string `json:"Layers"`
+	}
+	if err := json.Unmarshal(data, &manifests); err != nil {
+		return nil, fmt.Errorf("parsing the manifest of %s: %v", source.name, err)
+	}
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	for _, manifest := range manifests {
+		for _, repoTag := range manifest.RepoTags {
+			named, err := parseImageReference(repoTag)
+			if err != nil || named.Name() != ref.Name() || named.Tag != tag {
+				continue
+			}
+			data, err := readFile(source.fs, manifest.Config)
+			if err != nil {
+				return nil, err
+			}
+			image := &inspectedImage{}
+			if err := json.Unmarshal(data, &image.config); err != nil {
+				return nil, fmt.Errorf("parsing config %s of %s: %v", manifest.Config, source.name, err)
+			}
+			for _, layer := range manifest.Layers {
+				layer := layer
+				image.layers = append(image.layers, func() (io.ReadCloser, error) { return source.fs.open(layer) })
+			}
+			return image, nil
+		}
+	}
+	return nil, errImageNotFound
+}
+
+// setuidFiles returns the regular files of the image with the setuid or setgid bit, taking into account the files
+// which upper layers delete or replace.
+func setuidFiles(image *inspectedImage) ([]string, error) {
+	files := map[string]bool{}
+	for _, open := range image.layers {
+		layer, err := open()
+		if err != nil {
+			return nil, err
+		}
+		err = scanLayer(layer, files)
+		if err == nil {
+			// Reading what is left after the end of the archive checks the digest of layers streamed from a registry
+			_, err = io.Copy(ioutil.Discard, layer)
+		}
+		layer.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	var found []string
+	for name := range files {
+		found = append(found, name)
+	}
+	sort.Strings(found)
+	return found, nil
+}
+
+// scanLayer applies a layer, which may be compressed with gzip, to the setuid files found in the layers below it.
+// Whiteouts only hide files of the layers below, so the setuid files of the layer itself are added once it was read.
+func scanLayer(layer io.Reader, files map[string]bool) error {
+	buffered := bufio.NewReader(layer)
+	var reader io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	added := map[string]bool{}
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading layer: %v", err)
+		}
+		name := cleanArchivePath(header.Name)
+		dir, base := name[:strings.LastIndex(name, "/")+1], name[strings.LastIndex(name, "/")+1:]
+		switch {
+		case base == ".wh..wh..opq":
+			// An opaque whiteout hides everything the layers below have in the directory
+			for file := range files {
+				if strings.HasPrefix(file, dir) {
+					delete(files, file)
+				}
+			}
+		case strings.HasPrefix(base, ".wh."):
+			deleted := dir + strings.TrimPrefix(base, ".wh.")
+			for file := range files {
+				if file == deleted || strings.HasPrefix(file, deleted+"/") {
+					delete(files, file)
+				}
+			}
+		case header.Typeflag == tar.TypeReg && header.Mode&(04000|02000) != 0:
+			added[name] = true
+		default:
+			delete(files, name)
+			delete(added, name)
+		}
+	}
+	for name := range added {
+		files[name] = true
+	}
+	return nil
+}
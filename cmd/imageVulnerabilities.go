@@ -0,0 +1,421 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Severities of vulnerabilities, from the least to the most severe. Scanners report them in different cases, so they
+// are compared in lower case.
+var vulnerabilitySeverities = []string{"unknown", "negligible", "low", "medium", "high", "critical"}
+
+// defaultVulnerabilityPolicy is used for the settings the config doesn't set: vulnerabilities of medium severity and
+// above are reported, and the ones of high severity and above are errors.
+var defaultVulnerabilityPolicy = KubeauditConfigVulnerabilities{MinSeverity: "medium", ErrorSeverity: "high"}
+
+type vulnFlags struct {
+	reports string
+	db      *vulnerabilityDatabase
+}
+
+var vulnConfig vulnFlags
+
+// vulnerability is a vulnerability found in a package of an image.
+type vulnerability struct {
+	id               string
+	severity         string
+	pkg              string
+	installedVersion string
+	fixedVersion     string
+}
+
+// imageScan is the scan report of one image.
+type imageScan struct {
+	image           string
+	report          string
+	vulnerabilities []vulnerability
+}
+
+// vulnerabilityDatabase indexes the scan reports by the digests of the scanned image and, for images which aren't
+// pinned, by their name and tag.
+type vulnerabilityDatabase struct {
+	byDigest map[string]*imageScan
+	byTag    map[string]*imageScan
+}
+
+func isVulnerabilitySeverity(severity string) bool {
+	for _, s := range vulnerabilitySeverities {
+		if strings.EqualFold(s, severity) {
+			return true
+		}
+	}
+	return false
+}
+
+func severityRank(severity string) int {
+	for i, s := range vulnerabilitySeverities {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return 0
+}
+
+// vulnerabilityPolicy returns the thresholds and ignore list from the config, with the defaults for the thresholds it
+// doesn't set.
+func vulnerabilityPolicy() KubeauditConfigVulnerabilities {
+	policy := defaultVulnerabilityPolicy
+	vulnerabilities := auditConfigSpec().Vulnerabilities
+	if vulnerabilities == nil {
+		return policy
+	}
+	configured := *vulnerabilities
+	if configured.MinSeverity == "" {
+		configured.MinSeverity = policy.MinSeverity
+	}
+	if configured.ErrorSeverity == "" {
+		configured.ErrorSeverity = policy.ErrorSeverity
+	}
+	return configured
+}
+
+// loadReports loads the scan reports from the directory given with --reports, or from the one in the config.
+func (vulns *vulnFlags) loadReports() error {
+	if vulns.reports == "" {
+		vulns.reports = vulnerabilityPolicy().Reports
+	}
+	if vulns.reports == "" {
+		return fmt.Errorf("no scan reports given, use --reports or set vulnerabilities.reports in the config")
+	}
+	db, err := loadVulnerabilityDatabase(vulns.reports)
+	if err != nil {
+		return err
+	}
+	vulns.db = db
+	return nil
+}
+
+// loadVulnerabilityDatabase reads every JSON report of the directory. Reports in a format other than the ones of
+// Trivy and Grype are skipped with a warning.
+func loadVulnerabilityDatabase(dir string) (*vulnerabilityDatabase, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	db := &vulnerabilityDatabase{byDigest: map[string]*imageScan{}, byTag: map[string]*imageScan{}}
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		scan, names, digests, err := parseScanReport(data)
+		if err != nil {
+			log.Warnf("Skipping scan report %s: %v", filename, err)
+			continue
+		}
+		scan.report = filepath.Base(filename)
+		db.add(scan, names, digests)
+	}
+	return db, nil
+}
+
+func (db *vulnerabilityDatabase) add(scan *imageScan, names, digests []string) {
+	for _, digest := range digests {
+		if i := strings.LastIndex(digest, "@"); i >= 0 {
+			digest = digest[i+1:]
+		}
+		if sha256DigestRegexp.MatchString(digest) {
+			db.byDigest[digest] = scan
+		}
+	}
+	for _, name := range names {
+		ref, err := parseImageReference(name)
+		if err != nil {
+			continue
+		}
+		if ref.Digest != "" {
+			db.byDigest[ref.Digest] = scan
+		}
+		if ref.Tag != "" {
+			db.byTag[ref.Name()+":"+ref.Tag] = scan
+		}
+	}
+}
+
+type trivyReport struct {
+	ArtifactName string `json:"ArtifactName"`
+	Metadata     struct {
+		ImageID     string   `json:"ImageID"`
+		RepoDigests []string `json:"RepoDigests"`
+		RepoTags    []string `json:"RepoTags"`
+	} `json:"Metadata"`
+	Results []struct {
+		Vulnerabilities []struct {
+			VulnerabilityID  string `json:"VulnerabilityID"`
+			PkgName          string `json:"PkgName"`
+			InstalledVersion string `json:"InstalledVersion"`
+			FixedVersion     string `json:"FixedVersion"`
+			Severity         string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+type grypeReport struct {
+	Matches []struct {
+		Vulnerability struct {
+			ID       string `json:"id"`
+			Severity string `json:"severity"`
+			Fix      struct {
+				Versions []string `json:"versions"`
+			} `json:"fix"`
+		} `json:"vulnerability"`
+		Artifact struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"artifact"`
+	} `json:"matches"`
+	Source *struct {
+		Target struct {
+			UserInput      string   `json:"userInput"`
+			ImageID        string   `json:"imageID"`
+			ManifestDigest string   `json:"manifestDigest"`
+			RepoDigests    []string `json:"repoDigests"`
+			Tags           []string `json:"tags"`
+		} `json:"target"`
+	} `json:"source"`
+}
+
+// parseScanReport parses a Trivy or Grype JSON report and returns the names and digests of the scanned image along
+// with its vulnerabilities.
+func parseScanReport(data []byte) (scan *imageScan, names []string, digests []string, err error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, nil, nil, err
+	}
+	scan = &imageScan{}
+
+	switch {
+	case keys["ArtifactName"] != nil:
+		var report trivyReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, nil, nil, err
+		}
+		scan.image = report.ArtifactName
+		names = append([]string{report.ArtifactName}, report.Metadata.RepoTags...)
+		digests = append([]string{report.Metadata.ImageID}, report.Metadata.RepoDigests...)
+		for _, result := range report.Results {
+			for _, v := range result.Vulnerabilities {
+				scan.vulnerabilities = append(scan.vulnerabilities, vulnerability{
+					id:               v.VulnerabilityID,
+					severity:         strings.ToLower(v.Severity),
+					pkg:              v.PkgName,
+					installedVersion: v.InstalledVersion,
+					fixedVersion:     v.FixedVersion,
+				})
+			}
+		}
+	case keys["matches"] != nil:
+		var report grypeReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, nil, nil, err
+		}
+		if report.Source == nil {
+			return nil, nil, nil, fmt.Errorf("the Grype report has no source image")
+		}
+		target := report.Source.Target
+		scan.image = target.UserInput
+		names = append([]string{target.UserInput}, target.Tags...)
+		digests = append([]string{target.ImageID, target.ManifestDigest}, target.RepoDigests...)
+		for _, match := range report.Matches {
+			scan.vulnerabilities = append(scan.vulnerabilities, vulnerability{
+				id:               match.Vulnerability.ID,
+				severity:         strings.ToLower(match.Vulnerability.Severity),
+				pkg:              match.Artifact.Name,
+				installedVersion: match.Artifact.Version,
+				fixedVersion:     strings.Join(match.Vulnerability.Fix.Versions, ", "),
+			})
+		}
+	default:
+		return nil, nil, nil, fmt.Errorf("not a Trivy or Grype JSON report")
+	}
+	return scan, names, digests, nil
+}
+
+// runningImageDigests returns the digests of the images the containers of a pod run, from the image IDs in its status
+// such as docker-pullable://nginx@sha256:.... Image IDs without a repository, such as sha256:..., are the digest of the
+// image config rather than of its manifest, so they are left out.
+func runningImageDigests(resource Resource) map[string]string {
+	digests := map[string]string{}
+	pod, ok := resource.(*PodV1)
+	if !ok {
+		return digests
+	}
+	for _, status := range append(append([]ContainerStatusV1{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		i := strings.LastIndex(status.ImageID, "@")
+		if i < 0 {
+			continue
+		}
+		if digest := status.ImageID[i+1:]; sha256DigestRegexp.MatchString(digest) {
+			digests[status.Name] = digest
+		}
+	}
+	return digests
+}
+
+// findScan returns the scan of the image of the container and how it was found. Scans are matched by digest, either
+// the one in the image reference or the one the container runs, and by tag as a last resort.
+func (db *vulnerabilityDatabase) findScan(container ContainerV1, runningDigest string) (*imageScan, string) {
+	ref, err := parseImageReference(container.Image)
+	if err == nil && ref.Digest != "" {
+		if scan, ok := db.byDigest[ref.Digest]; ok {
+			return scan, "digest"
+		}
+	}
+	if scan, ok := db.byDigest[runningDigest]; ok {
+		return scan, "digest"
+	}
+	if err == nil && ref.Digest == "" {
+		tag := ref.Tag
+		if tag == "" {
+			tag = "latest"
+		}
+		if scan, ok := db.byTag[ref.Name()+":"+tag]; ok {
+			return scan, "tag"
+		}
+	}
+	return nil, ""
+}
+
+// reportedVulnerabilities returns the vulnerabilities of the scan which reach the minimum severity and aren't ignored,
+// from the most to the least severe. Vulnerabilities found in several places of the image are only reported once.
+func reportedVulnerabilities(scan *imageScan, policy KubeauditConfigVulnerabilities) (reported []vulnerability) {
+	seen := map[string]bool{}
+	for _, v := range scan.vulnerabilities {
+		key := v.id + "/" + v.pkg + "/" + v.installedVersion
+		if seen[key] || containsString(policy.Ignore, v.id) || severityRank(v.severity) < severityRank(policy.MinSeverity) {
+			continue
+		}
+		if policy.IgnoreUnfixed && v.fixedVersion == "" {
+			continue
+		}
+		seen[key] = true
+		reported = append(reported, v)
+	}
+	sort.SliceStable(reported, func(i, j int) bool {
+		if severityRank(reported[i].severity) != severityRank(reported[j].severity) {
+			return severityRank(reported[i].severity) > severityRank(reported[j].severity)
+		}
+		return reported[i].id < reported[j].id
+	})
+	return reported
+}
+
+func checkImageVulnerabilities(container ContainerV1, runningDigest string, db *vulnerabilityDatabase, policy KubeauditConfigVulnerabilities, result *Result) {
+	scan, matchedBy := db.findScan(container, runningDigest)
+	if scan == nil {
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorImageNotScanned,
+			kind:      Warn,
+			message:   "No scan report was found for the image",
+			metadata:  Metadata{"Image": container.Image},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+		return
+	}
+
+	for _, v := range reportedVulnerabilities(scan, policy) {
+		kind := Warn
+		if severityRank(v.severity) >= severityRank(policy.ErrorSeverity) {
+			kind = Error
+		}
+		metadata := Metadata{
+			"Image":            container.Image,
+			"Vulnerability":    v.id,
+			"Severity":         v.severity,
+			"Package":          v.pkg,
+			"InstalledVersion": v.installedVersion,
+			"Report":           scan.report,
+			"MatchedBy":        matchedBy,
+		}
+		message := fmt.Sprintf("%s (%s) in %s %s", v.id, v.severity, v.pkg, v.installedVersion)
+		if v.fixedVersion != "" {
+			metadata["FixedVersion"] = v.fixedVersion
+			message += ", fixed in " + v.fixedVersion
+		}
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorImageVulnerable,
+			kind:      kind,
+			message:   message,
+			metadata:  metadata,
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+}
+
+func auditImageVulnerabilities(vulns vulnFlags, resource Resource) (results []Result) {
+	if vulns.db == nil {
+		return
+	}
+	podSpec, ok := podSpecOf(resource)
+	if !ok {
+		return
+	}
+	policy := vulnerabilityPolicy()
+	runningDigests := runningImageDigests(resource)
+	for _, container := range podContainers(podSpec) {
+		result, err, warn := newResultFromResource(resource)
+		if warn != nil {
+			log.Warn(warn)
+			return
+		}
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		checkImageVulnerabilities(container, runningDigests[container.Name], vulns.db, policy, result)
+		if len(result.Occurrences) > 0 {
+			results = append(results, *result)
+		}
+	}
+	return
+}
+
+var imagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "Audit container images against vulnerability scan reports",
+	Long: `This command matches the image of every container against a directory of
+scan reports, in the JSON format of Trivy or Grype, and reports the
+vulnerabilities of the image with their id and severity.
+
+Reports are matched by digest, either the one the image is pinned to or the one
+a running pod reports in its status, and by tag for images which aren't pinned.
+A WARN is generated for images without a report.
+
+Vulnerabilities of medium severity are WARNs and the ones of high severity and
+above are ERRORs. The thresholds, the directory of reports and a list of
+vulnerabilities to ignore can be set in the config file.
+
+Example usage:
+trivy image -f json -o reports/nginx.json nginx:1.17
+kubeaudit images --reports reports/`,
+	Run: runAudit(auditImageVulnerabilities),
+}
+
+func init() {
+	RootCmd.AddCommand(imagesCmd)
+	imagesCmd.Flags().StringVar(&vulnConfig.reports, "reports", "", "Directory of Trivy or Grype JSON scan reports")
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const vulnerabilityReports = "../fixtures/vulnerability_reports"
+
+func vulnerabilityIDs(occurrences []Occurrence) (ids []string) {
+	for _, occ := range occurrences {
+		ids = append(ids, occ.metadata["Vulnerability"])
+	}
+	return ids
+}
+
+func TestImageVulnerabilitiesV1(t *testing.T) {
+	results := runAuditTest(t, "image_vulnerabilities_v1.yml", auditImageVulnerabilities, []int{ErrorImageVulnerable, ErrorImageNotScanned},
+		vulnerabilityReports)
+	occurrences := indexOccurrences(results, byContainer)
+
+	nginx := occurrences["nginx"]
+	assert.Equal(t, []string{"CVE-2019-0001", "CVE-2019-0004", "CVE-2019-0002"}, vulnerabilityIDs(nginx))
+	assert.Equal(t, Error, nginx[0].kind)
+	assert.Equal(t, "critical", nginx[0].metadata["Severity"])
+	assert.Equal(t, "1.1.1d", nginx[0].metadata["FixedVersion"])
+	assert.Equal(t, "tag", nginx[0].metadata["MatchedBy"])
+	assert.Equal(t, "trivy-nginx.json", nginx[0].metadata["Report"])
+	assert.Equal(t, Error, nginx[1].kind)
+	assert.NotContains(t, nginx[1].metadata, "FixedVersion")
+	assert.Equal(t, Warn, nginx[2].kind)
+
+	assert.Equal(t, []string{"GHSA-0001-0001-0001"}, vulnerabilityIDs(occurrences["pinned"]))
+	assert.Equal(t, "digest", occurrences["pinned"][0].metadata["MatchedBy"])
+	// The image is matched by the digest the pod runs even though its tag wasn't scanned
+	assert.Equal(t, []string{"GHSA-0001-0001-0001"}, vulnerabilityIDs(occurrences["running"]))
+	assert.Equal(t, "digest", occurrences["running"][0].metadata["MatchedBy"])
+	// An image ID without a repository is the digest of the image config, not of the manifest the scan was made of
+	require.Len(t, occurrences["bare-id"], 1)
+	assert.Equal(t, ErrorImageNotScanned, occurrences["bare-id"][0].id)
+
+	// Init containers run their image as well
+	assert.Equal(t, vulnerabilityIDs(nginx), vulnerabilityIDs(occurrences["init"]))
+
+	require.Len(t, occurrences["unscanned"], 1)
+	assert.Equal(t, ErrorImageNotScanned, occurrences["unscanned"][0].id)
+}
+
+func TestImageVulnerabilitiesFromConfig(t *testing.T) {
+	rootConfig.auditConfig = "../configs/vulnerabilities_from_config.yml"
+	defer func() { rootConfig.auditConfig = "" }()
+
+	vulns := vulnFlags{}
+	require.NoError(t, vulns.loadReports())
+	assert.Equal(t, vulnerabilityReports, vulns.reports)
+
+	results := runAuditTest(t, "image_vulnerabilities_v1.yml", auditImageVulnerabilities, []int{ErrorImageVulnerable, ErrorImageNotScanned},
+		vulnerabilityReports)
+	occurrences := indexOccurrences(results, byContainer)
+	// Low vulnerabilities are reported, ignored and unfixed ones aren't, and only critical ones are errors
+	nginx := occurrences["nginx"]
+	assert.Equal(t, []string{"CVE-2019-0001", "CVE-2019-0003"}, vulnerabilityIDs(nginx))
+	assert.Equal(t, Error, nginx[0].kind)
+	assert.Equal(t, Warn, nginx[1].kind)
+	assert.Equal(t, Warn, occurrences["pinned"][0].kind)
+}
+
+func TestLoadReports(t *testing.T) {
+	vulns := vulnFlags{}
+	assert.Error(t, vulns.loadReports())
+	vulns = vulnFlags{reports: "../fixtures/missing_reports"}
+	assert.Error(t, vulns.loadReports())
+
+	db, err := loadVulnerabilityDatabase(vulnerabilityReports)
+	require.NoError(t, err)
+	assert.Contains(t, db.byTag, "docker.io/library/nginx:1.17")
+	assert.Contains(t, db.byTag, "registry.local/app:2.0")
+	assert.Len(t, db.byDigest, 4)
+}
+
+func TestParseScanReportInvalid(t *testing.T) {
+	_, _, _, err := parseScanReport([]byte(`{"kind": "SomethingElse"}`))
+	assert.Error(t, err)
+	_, _, _, err = parseScanReport([]byte(`{"matches": []}`))
+	assert.Error(t, err)
+	_, _, _, err = parseScanReport([]byte(`[`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// pinImage returns the image pinned to the digest its tag currently points to, keeping the name and tag as written so
+// that the manifest stays readable. Images which already have a digest are returned as is.
+func pinImage(image string, registry *registryClient) (string, error) {
+	ref, err := parseImageReference(image)
+	if err != nil {
+		return image, err
+	}
+	if ref.Digest != "" {
+		return image, nil
+	}
+	digest, err := registry.resolve(ref)
+	if err != nil {
+		return image, err
+	}
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	return ref.FamiliarName() + ":" + tag + "@" + digest, nil
+}
+
+// pinImageDigests pins the image of every container, init containers included, to a digest. Images which can't be
+// resolved are left alone.
+func pinImageDigests(resource Resource, registry *registryClient) Resource {
+	podSpec, ok := podSpecOf(resource)
+	if !ok {
+		return resource
+	}
+	pinContainers := func(containers []ContainerV1) (pinnedContainers []ContainerV1) {
+		for _, container := range containers {
+			pinned, err := pinImage(container.Image, registry)
+			if err != nil {
+				log.Warnf("Unable to pin the image of container %s to a digest: %v", container.Name, err)
+			}
+			container.Image = pinned
+			pinnedContainers = append(pinnedContainers, container)
+		}
+		return pinnedContainers
+	}
+	if len(podSpec.InitContainers) > 0 {
+		resource = setInitContainers(resource, pinContainers(podSpec.InitContainers))
+	}
+	return setContainers(resource, pinContainers(podSpec.Containers))
+}
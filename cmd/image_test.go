@@ -1,6 +1,10 @@
 package cmd
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func TestImageTagMissingV1(t *testing.T) {
 	runAuditTest(t, "image_tag_missing_v1.yml", auditImages, []int{ErrorImageTagMissing}, "fakeContainerImg:1.6")
@@ -13,3 +17,33 @@ func TestImageTagIncorrectV1(t *testing.T) {
 func TestImageTagCorrectV1(t *testing.T) {
 	runAuditTest(t, "image_tag_present_v1.yml", auditImages, []int{InfoImageCorrect}, "fakeContainerImg:1.5")
 }
+
+func TestImagePolicyV1(t *testing.T) {
+	results := runAuditTest(t, "image_policy_v1.yml", auditImages, []int{ErrorImageTagForbidden, ErrorImagePullPolicyInconsistent,
+		ErrorImageTagMissing, ErrorImageReferenceInvalid}, "registry.local:5000/app:1.0")
+	occurrences := occurrenceIDs(indexOccurrences(results, byContainer))
+	assert.ElementsMatch(t, []int{ErrorImageTagForbidden, ErrorImagePullPolicyInconsistent}, occurrences["latest"])
+	// The port of the registry isn't mistaken for a tag
+	assert.Equal(t, []int{ErrorImageTagMissing}, occurrences["registry-port"])
+	assert.NotContains(t, occurrences, "pinned")
+	assert.Equal(t, []int{ErrorImagePullPolicyInconsistent}, occurrences["never"])
+	assert.Equal(t, []int{ErrorImageReferenceInvalid}, occurrences["invalid"])
+}
+
+func TestImagePolicyFromConfig(t *testing.T) {
+	rootConfig.auditConfig = "../configs/image_policy_from_config.yml"
+	defer func() { rootConfig.auditConfig = "" }()
+	results := runAuditTest(t, "image_policy_v1.yml", auditImages, []int{ErrorImageTagForbidden, ErrorImagePullPolicyInconsistent,
+		ErrorImageTagMissing, ErrorImageReferenceInvalid, ErrorImageRegistryNotAllowed, ErrorImageDigestMissing, ErrorImageTagIncorrect},
+		"nginx:1.17")
+	occurrences := occurrenceIDs(indexOccurrences(results, byContainer))
+	assert.ElementsMatch(t, []int{ErrorImageRegistryNotAllowed, ErrorImageTagForbidden, ErrorImageDigestMissing, ErrorImageTagIncorrect,
+		ErrorImagePullPolicyInconsistent}, occurrences["latest"])
+	assert.NotContains(t, occurrences, "pinned")
+	assert.ElementsMatch(t, []int{ErrorImageDigestMissing, ErrorImagePullPolicyInconsistent}, occurrences["never"])
+}
+
+func TestImageDigestCorrectV1(t *testing.T) {
+	runAuditTest(t, "image_policy_v1.yml", auditImages, []int{InfoImageCorrect, ErrorImageTagForbidden, ErrorImagePullPolicyInconsistent,
+		ErrorImageTagMissing, ErrorImageReferenceInvalid}, "gcr.io/project/app@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+}
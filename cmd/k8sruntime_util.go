@@ -51,6 +51,90 @@ func setContainers(resource Resource, containers []ContainerV1) Resource {
 	return resource
 }
 
+func setPodSecurityContext(resource Resource, securityContext *PodSecurityContextV1) Resource {
+	switch t := resource.(type) {
+	case *CronJobV1Beta1:
+		t.Spec.JobTemplate.Spec.Template.Spec.SecurityContext = securityContext
+		return t.DeepCopyObject()
+	case *DaemonSetV1:
+		t.Spec.Template.Spec.SecurityContext = securityContext
+		return t.DeepCopyObject()
+	case *DaemonSetV1Beta1:
+		t.Spec.Template.Spec.SecurityContext = securityContext
+		return t.DeepCopyObject()
+	case *DaemonSetV1Beta2:
+		t.Spec.Template.Spec.SecurityContext = securityContext
+		return t.DeepCopyObject()
+	case *DeploymentExtensionsV1Beta1:
+		t.Spec.Template.Spec.SecurityContext = securityContext
+		return t.DeepCopyObject()
+	case *DeploymentV1:
+		t.Spec.Template.Spec.SecurityContext = securityContext
+		return t.DeepCopyObject()
+	case *DeploymentV1Beta1:
+		t.Spec.Template.Spec.SecurityContext = securityContext
+		return t.DeepCopyObject()
+	case *DeploymentV1Beta2:
+		t.Spec.Template.Spec.SecurityContext = securityContext
+		return t.DeepCopyObject()
+	case *PodV1:
+		t.Spec.SecurityContext = securityContext
+		return t.DeepCopyObject()
+	case *ReplicationControllerV1:
+		t.Spec.Template.Spec.SecurityContext = securityContext
+		return t.DeepCopyObject()
+	case *StatefulSetV1:
+		t.Spec.Template.Spec.SecurityContext = securityContext
+		return t.DeepCopyObject()
+	case *StatefulSetV1Beta1:
+		t.Spec.Template.Spec.SecurityContext = securityContext
+		return t.DeepCopyObject()
+	}
+	return resource
+}
+
+func setInitContainers(resource Resource, containers []ContainerV1) Resource {
+	switch t := resource.(type) {
+	case *CronJobV1Beta1:
+		t.Spec.JobTemplate.Spec.Template.Spec.InitContainers = containers
+		return t.DeepCopyObject()
+	case *DaemonSetV1:
+		t.Spec.Template.Spec.InitContainers = containers
+		return t.DeepCopyObject()
+	case *DaemonSetV1Beta1:
+		t.Spec.Template.Spec.InitContainers = containers
+		return t.DeepCopyObject()
+	case *DaemonSetV1Beta2:
+		t.Spec.Template.Spec.InitContainers = containers
+		return t.DeepCopyObject()
+	case *DeploymentExtensionsV1Beta1:
+		t.Spec.Template.Spec.InitContainers = containers
+		return t.DeepCopyObject()
+	case *DeploymentV1:
+		t.Spec.Template.Spec.InitContainers = containers
+		return t.DeepCopyObject()
+	case *DeploymentV1Beta1:
+		t.Spec.Template.Spec.InitContainers = containers
+		return t.DeepCopyObject()
+	case *DeploymentV1Beta2:
+		t.Spec.Template.Spec.InitContainers = containers
+		return t.DeepCopyObject()
+	case *PodV1:
+		t.Spec.InitContainers = containers
+		return t.DeepCopyObject()
+	case *ReplicationControllerV1:
+		t.Spec.Template.Spec.InitContainers = containers
+		return t.DeepCopyObject()
+	case *StatefulSetV1:
+		t.Spec.Template.Spec.InitContainers = containers
+		return t.DeepCopyObject()
+	case *StatefulSetV1Beta1:
+		t.Spec.Template.Spec.InitContainers = containers
+		return t.DeepCopyObject()
+	}
+	return resource
+}
+
 func setNetworkPolicyFields(nsName string, policyList []string) Resource {
 	var np NetworkPolicyV1
 	np.Kind = "NetworkPolicy"
@@ -237,14 +321,64 @@ func getContainers(resource Resource) (container []ContainerV1) {
 	return container
 }
 
-// Get PodSpec from the PodV1 resource type to check for PSC
-
-func getPodSpecs(resource Resource) (podSpec PodSpecV1) {
+// podSpecOf returns the pod spec of any workload kind, and false if the resource doesn't have one.
+func podSpecOf(resource Resource) (PodSpecV1, bool) {
 	switch kubeType := resource.(type) {
+	case *CronJobV1Beta1:
+		return kubeType.Spec.JobTemplate.Spec.Template.Spec, true
+	case *DaemonSetV1:
+		return kubeType.Spec.Template.Spec, true
+	case *DaemonSetV1Beta1:
+		return kubeType.Spec.Template.Spec, true
+	case *DaemonSetV1Beta2:
+		return kubeType.Spec.Template.Spec, true
+	case *DeploymentExtensionsV1Beta1:
+		return kubeType.Spec.Template.Spec, true
+	case *DeploymentV1:
+		return kubeType.Spec.Template.Spec, true
+	case *DeploymentV1Beta1:
+		return kubeType.Spec.Template.Spec, true
+	case *DeploymentV1Beta2:
+		return kubeType.Spec.Template.Spec, true
 	case *PodV1:
-		podSpec = kubeType.Spec
+		return kubeType.Spec, true
+	case *ReplicationControllerV1:
+		return kubeType.Spec.Template.Spec, true
+	case *StatefulSetV1:
+		return kubeType.Spec.Template.Spec, true
+	case *StatefulSetV1Beta1:
+		return kubeType.Spec.Template.Spec, true
+	}
+	return PodSpecV1{}, false
+}
+
+// effectiveSecurityContext returns the security context a container runs with: the fields of the pod security
+// context which also exist in the container security context apply unless the container sets them itself.
+func effectiveSecurityContext(podSpec PodSpecV1, container ContainerV1) SecurityContextV1 {
+	var securityContext SecurityContextV1
+	if container.SecurityContext != nil {
+		securityContext = *container.SecurityContext.DeepCopy()
+	}
+	pod := podSpec.SecurityContext
+	if pod == nil {
+		return securityContext
+	}
+	if securityContext.SELinuxOptions == nil && pod.SELinuxOptions != nil {
+		securityContext.SELinuxOptions = pod.SELinuxOptions.DeepCopy()
+	}
+	if securityContext.WindowsOptions == nil && pod.WindowsOptions != nil {
+		securityContext.WindowsOptions = pod.WindowsOptions.DeepCopy()
+	}
+	if securityContext.RunAsUser == nil && pod.RunAsUser != nil {
+		securityContext.RunAsUser = pod.RunAsUser
+	}
+	if securityContext.RunAsGroup == nil && pod.RunAsGroup != nil {
+		securityContext.RunAsGroup = pod.RunAsGroup
+	}
+	if securityContext.RunAsNonRoot == nil && pod.RunAsNonRoot != nil {
+		securityContext.RunAsNonRoot = pod.RunAsNonRoot
 	}
-	return podSpec
+	return securityContext
 }
 
 func getPodAnnotations(resource Resource) (annotations map[string]string) {
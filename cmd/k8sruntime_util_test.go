@@ -26,6 +26,22 @@ func TestGetContainersV1(t *testing.T) {
 	}
 }
 
+func TestEffectiveSecurityContext(t *testing.T) {
+	assert := assert.New(t)
+	uid, podUID := int64(1000), int64(2000)
+	podSpec := PodSpecV1{SecurityContext: &PodSecurityContextV1{RunAsNonRoot: newTrue(), RunAsUser: &podUID}}
+	container := ContainerV1{SecurityContext: &SecurityContextV1{RunAsUser: &uid, Privileged: newFalse()}}
+
+	securityContext := effectiveSecurityContext(podSpec, container)
+	assert.True(*securityContext.RunAsNonRoot)
+	assert.Equal(uid, *securityContext.RunAsUser)
+	assert.False(*securityContext.Privileged)
+	assert.Nil(container.SecurityContext.RunAsNonRoot)
+
+	assert.Equal(podUID, *effectiveSecurityContext(podSpec, ContainerV1{}).RunAsUser)
+	assert.Nil(effectiveSecurityContext(PodSpecV1{}, ContainerV1{}).RunAsUser)
+}
+
 func TestWriteToFileV1(t *testing.T) {
 	file := "../fixtures/read_only_root_filesystem_false_v1.yml"
 	fileout := "out_v1.yml"
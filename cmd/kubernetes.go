@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
 	log "github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/version"
@@ -32,122 +34,469 @@ func (kc K8sClient) InClusterConfig() (*rest.Config, error) {
 var ErrNoReadableKubeConfig = errors.New("unable to open kubeconfig file")
 
 func kubeClient() (*kubernetes.Clientset, error) {
-	return kubeClientType(K8sClient{})
+	return kubeClientForContext("")
 }
 
-func kubeClientType(kc Client) (*kubernetes.Clientset, error) {
-	config, err := kubeClientConfig(kc)
+// kubeClientForContext returns a client for the cluster of the given kubeconfig context. An empty context means the
+// current context, or the cluster kubeaudit is running in.
+func kubeClientForContext(kubeContext string) (*kubernetes.Clientset, error) {
+	return kubeClientType(K8sClient{}, kubeContext)
+}
+
+func kubeClientType(kc Client, kubeContext string) (*kubernetes.Clientset, error) {
+	config, err := kubeClientConfig(kc, kubeContext)
 	if err != nil {
 		return nil, err
 	}
+	if rootConfig.kubeQPS > 0 {
+		config.QPS = rootConfig.kubeQPS
+	}
+	if rootConfig.kubeBurst > 0 {
+		config.Burst = rootConfig.kubeBurst
+	}
+	if rootConfig.timeout > 0 {
+		config.Timeout = rootConfig.timeout
+	}
 	kube, err := kubernetes.NewForConfig(config)
 	return kube, err
 }
 
-func kubeClientConfig(kc Client) (*rest.Config, error) {
+// kubeConfigOverrides holds the kubectl flags (--server, --token, --as, ...) which take precedence over the
+// kubeconfig.
+var kubeConfigOverrides clientcmd.ConfigOverrides
+
+// kubeConfigOverrideFlags returns the names of the standard kubectl flags for authentication, impersonation and
+// server overrides. --context, --namespace and --request-timeout are left out since kubeaudit has its own.
+func kubeConfigOverrideFlags() clientcmd.ConfigOverrideFlags {
+	flags := clientcmd.RecommendedConfigOverrideFlags("")
+	flags.ContextOverrideFlags.Namespace = clientcmd.FlagInfo{}
+	flags.CurrentContext = clientcmd.FlagInfo{}
+	flags.Timeout = clientcmd.FlagInfo{}
+	return flags
+}
+
+func kubeClientConfig(kc Client, kubeContext string) (*rest.Config, error) {
 	if rootConfig.kubeConfig != "" {
-		return kubeClientConfigLocal()
+		return kubeClientConfigLocal(kubeContext)
+	}
+
+	if kubeContext == "" && kubeConfigOverrides.ClusterInfo.Server == "" {
+		if config, err := kc.InClusterConfig(); err == nil {
+			log.Info("Running inside cluster, using the cluster config")
+			applyInClusterOverrides(config)
+			return config, nil
+		}
+		log.Info("Not running inside cluster, using local config")
+	}
+
+	if err := setDefaultKubeConfig(); err != nil {
+		return nil, err
 	}
+	return kubeClientConfigLocal(kubeContext)
+}
 
-	if config, err := kc.InClusterConfig(); err == nil {
-		log.Info("Running inside cluster, using the cluster config")
-		return config, nil
+// applyInClusterOverrides applies the token and impersonation flags to the service account config used inside a
+// cluster, e.g. to audit with the permissions of another service account.
+func applyInClusterOverrides(config *rest.Config) {
+	authInfo := kubeConfigOverrides.AuthInfo
+	if authInfo.Token != "" {
+		config.BearerToken = authInfo.Token
+		config.BearerTokenFile = ""
+	}
+	if authInfo.TokenFile != "" {
+		config.BearerToken = ""
+		config.BearerTokenFile = authInfo.TokenFile
+	}
+	if authInfo.Impersonate != "" {
+		config.Impersonate = rest.ImpersonationConfig{UserName: authInfo.Impersonate, Groups: authInfo.ImpersonateGroups}
 	}
+}
 
-	log.Info("Not running inside cluster, using local config")
+// setDefaultKubeConfig points rootConfig.kubeConfig at $KUBECONFIG, or $HOME/.kube/config, unless a kubeconfig was
+// given.
+func setDefaultKubeConfig() error {
+	if rootConfig.kubeConfig != "" {
+		return nil
+	}
+	if kubeConfig := os.Getenv(clientcmd.RecommendedConfigPathEnvVar); kubeConfig != "" {
+		rootConfig.kubeConfig = kubeConfig
+		return nil
+	}
 	home, ok := os.LookupEnv("HOME")
 	if !ok || home == "" {
 		log.Error("Unable to load kubeconfig. No config file specified and $HOME not found.")
-		return nil, ErrNoReadableKubeConfig
+		return ErrNoReadableKubeConfig
 	}
-
 	rootConfig.kubeConfig = filepath.Join(home, ".kube", "config")
-	return kubeClientConfigLocal()
+	return nil
 }
 
-func kubeClientConfigLocal() (*rest.Config, error) {
-	if _, err := os.Stat(rootConfig.kubeConfig); err != nil {
+// kubeConfigLoadingRules loads rootConfig.kubeConfig, which like $KUBECONFIG may be a list of files to merge.
+func kubeConfigLoadingRules() *clientcmd.ClientConfigLoadingRules {
+	var paths []string
+	for _, path := range filepath.SplitList(rootConfig.kubeConfig) {
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 1 {
+		return &clientcmd.ClientConfigLoadingRules{ExplicitPath: paths[0]}
+	}
+	return &clientcmd.ClientConfigLoadingRules{Precedence: paths}
+}
+
+func kubeClientConfigLocal(kubeContext string) (*rest.Config, error) {
+	loadingRules := kubeConfigLoadingRules()
+	// A kubeconfig isn't needed when the server and credentials are all given as flags
+	if loadingRules.ExplicitPath == "" && len(loadingRules.Precedence) == 0 && kubeConfigOverrides.ClusterInfo.Server == "" {
 		log.Errorf("Unable to load kubeconfig. Could not open file %s.", rootConfig.kubeConfig)
 		return nil, ErrNoReadableKubeConfig
 	}
-	return clientcmd.BuildConfigFromFlags("", rootConfig.kubeConfig)
+	overrides := kubeConfigOverrides
+	overrides.CurrentContext = kubeContext
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &overrides).ClientConfig()
 }
 
-func getDeployments(clientset *kubernetes.Clientset) *DeploymentListV1 {
-	deploymentClient := clientset.AppsV1().Deployments(rootConfig.namespace)
-	deployments, err := deploymentClient.List(ListOptionsV1{})
+// kubeContexts returns the kubeconfig contexts to audit, sorted by name with --all-contexts. It returns nil when
+// neither --context nor --all-contexts is used, in which case only the current context is audited.
+func kubeContexts() ([]string, error) {
+	if !rootConfig.allContexts && len(rootConfig.kubeContexts) == 0 {
+		return nil, nil
+	}
+	// Resolve the kubeconfig once up front since the clusters are then audited concurrently
+	if err := setDefaultKubeConfig(); err != nil {
+		return nil, err
+	}
+	if !rootConfig.allContexts {
+		return rootConfig.kubeContexts, nil
+	}
+	config, err := kubeConfigLoadingRules().Load()
 	if err != nil {
-		log.Error(err)
+		return nil, err
 	}
-	return deployments
+	var contexts []string
+	for name := range config.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return contexts, nil
 }
 
-func getStatefulSets(clientset *kubernetes.Clientset) *StatefulSetListV1 {
-	statefulSetClient := clientset.AppsV1().StatefulSets(rootConfig.namespace)
-	statefulSets, err := statefulSetClient.List(ListOptionsV1{})
-	if err != nil {
-		log.Error(err)
+// listPageSize is the number of items requested per List call so that large clusters are fetched in chunks instead
+// of in one huge response.
+const listPageSize = 500
+
+// listPage fetches a single page of a List call and returns the continue token for the next page.
+type listPage func(options ListOptionsV1) (continueToken string, err error)
+
+// listAllPages calls list until the API server stops returning a continue token or the context is cancelled.
+func listAllPages(ctx context.Context, options ListOptionsV1, list listPage) error {
+	options.Limit = listPageSize
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		continueToken, err := list(options)
+		if err != nil {
+			return err
+		}
+		if continueToken == "" {
+			return nil
+		}
+		options.Continue = continueToken
 	}
-	return statefulSets
 }
 
-func getDaemonSets(clientset *kubernetes.Clientset) *DaemonSetListV1 {
-	daemonSetClient := clientset.AppsV1().DaemonSets(rootConfig.namespace)
-	daemonSets, err := daemonSetClient.List(ListOptionsV1{})
-	if err != nil {
-		log.Error(err)
-	}
-	return daemonSets
+func getDeployments(ctx context.Context, clientset kubernetes.Interface, namespace string) (*DeploymentListV1, error) {
+	deploymentClient := clientset.AppsV1().Deployments(namespace)
+	deployments := &DeploymentListV1{}
+	err := listAllPages(ctx, workloadListOptions(), func(options ListOptionsV1) (string, error) {
+		page, err := deploymentClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		deployments.Items = append(deployments.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return deployments, err
 }
 
-func getPods(clientset *kubernetes.Clientset) *PodListV1 {
-	podClient := clientset.CoreV1().Pods(rootConfig.namespace)
-	pods, err := podClient.List(ListOptionsV1{})
-	if err != nil {
-		log.Error(err)
-	}
-	return pods
+func getStatefulSets(ctx context.Context, clientset kubernetes.Interface, namespace string) (*StatefulSetListV1, error) {
+	statefulSetClient := clientset.AppsV1().StatefulSets(namespace)
+	statefulSets := &StatefulSetListV1{}
+	err := listAllPages(ctx, workloadListOptions(), func(options ListOptionsV1) (string, error) {
+		page, err := statefulSetClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		statefulSets.Items = append(statefulSets.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return statefulSets, err
 }
 
-func getReplicationControllers(clientset *kubernetes.Clientset) *ReplicationControllerListV1 {
-	replicationControllerClient := clientset.CoreV1().ReplicationControllers(rootConfig.namespace)
-	replicationControllers, err := replicationControllerClient.List(ListOptionsV1{})
-	if err != nil {
-		log.Error(err)
-	}
-	return replicationControllers
+func getDaemonSets(ctx context.Context, clientset kubernetes.Interface, namespace string) (*DaemonSetListV1, error) {
+	daemonSetClient := clientset.AppsV1().DaemonSets(namespace)
+	daemonSets := &DaemonSetListV1{}
+	err := listAllPages(ctx, workloadListOptions(), func(options ListOptionsV1) (string, error) {
+		page, err := daemonSetClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		daemonSets.Items = append(daemonSets.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return daemonSets, err
 }
 
-func getNetworkPolicies(clientset *kubernetes.Clientset) *NetworkPolicyListV1 {
-	netPolClient := clientset.NetworkingV1().NetworkPolicies(rootConfig.namespace)
-	netPols, err := netPolClient.List(ListOptionsV1{})
-	if err != nil {
-		log.Error(err)
-	}
-	return netPols
+func getPods(ctx context.Context, clientset kubernetes.Interface, namespace string) (*PodListV1, error) {
+	podClient := clientset.CoreV1().Pods(namespace)
+	pods := &PodListV1{}
+	err := listAllPages(ctx, workloadListOptions(), func(options ListOptionsV1) (string, error) {
+		page, err := podClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		pods.Items = append(pods.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return pods, err
+}
+
+func getReplicationControllers(ctx context.Context, clientset kubernetes.Interface, namespace string) (*ReplicationControllerListV1, error) {
+	replicationControllerClient := clientset.CoreV1().ReplicationControllers(namespace)
+	replicationControllers := &ReplicationControllerListV1{}
+	err := listAllPages(ctx, workloadListOptions(), func(options ListOptionsV1) (string, error) {
+		page, err := replicationControllerClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		replicationControllers.Items = append(replicationControllers.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return replicationControllers, err
+}
+
+func getCronJobs(ctx context.Context, clientset kubernetes.Interface, namespace string) (*CronJobListV1Beta1, error) {
+	cronJobClient := clientset.BatchV1beta1().CronJobs(namespace)
+	cronJobs := &CronJobListV1Beta1{}
+	err := listAllPages(ctx, workloadListOptions(), func(options ListOptionsV1) (string, error) {
+		page, err := cronJobClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		cronJobs.Items = append(cronJobs.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return cronJobs, err
+}
+
+func getReplicaSets(ctx context.Context, clientset kubernetes.Interface, namespace string) (*ReplicaSetListV1, error) {
+	replicaSetClient := clientset.AppsV1().ReplicaSets(namespace)
+	replicaSets := &ReplicaSetListV1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := replicaSetClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		replicaSets.Items = append(replicaSets.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return replicaSets, err
+}
+
+func getJobs(ctx context.Context, clientset kubernetes.Interface, namespace string) (*JobListV1, error) {
+	jobClient := clientset.BatchV1().Jobs(namespace)
+	jobs := &JobListV1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := jobClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		jobs.Items = append(jobs.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return jobs, err
 }
 
-func getNamespaces(clientset *kubernetes.Clientset) *NamespaceListV1 {
+func getNetworkPolicies(ctx context.Context, clientset kubernetes.Interface, namespace string) (*NetworkPolicyListV1, error) {
+	netPolClient := clientset.NetworkingV1().NetworkPolicies(namespace)
+	netPols := &NetworkPolicyListV1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := netPolClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		netPols.Items = append(netPols.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return netPols, err
+}
+
+func getConfigMaps(ctx context.Context, clientset kubernetes.Interface, namespace string) (*ConfigMapListV1, error) {
+	configMapClient := clientset.CoreV1().ConfigMaps(namespace)
+	configMaps := &ConfigMapListV1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := configMapClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		configMaps.Items = append(configMaps.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return configMaps, err
+}
+
+func getServices(ctx context.Context, clientset kubernetes.Interface, namespace string) (*ServiceListV1, error) {
+	serviceClient := clientset.CoreV1().Services(namespace)
+	services := &ServiceListV1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := serviceClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		services.Items = append(services.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return services, err
+}
+
+func getIngresses(ctx context.Context, clientset kubernetes.Interface, namespace string) (*IngressListV1Beta1, error) {
+	ingressClient := clientset.NetworkingV1beta1().Ingresses(namespace)
+	ingresses := &IngressListV1Beta1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := ingressClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		ingresses.Items = append(ingresses.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return ingresses, err
+}
+
+func getLimitRanges(ctx context.Context, clientset kubernetes.Interface, namespace string) (*LimitRangeListV1, error) {
+	limitRangeClient := clientset.CoreV1().LimitRanges(namespace)
+	limitRanges := &LimitRangeListV1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := limitRangeClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		limitRanges.Items = append(limitRanges.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return limitRanges, err
+}
+
+func getResourceQuotas(ctx context.Context, clientset kubernetes.Interface, namespace string) (*ResourceQuotaListV1, error) {
+	resourceQuotaClient := clientset.CoreV1().ResourceQuotas(namespace)
+	resourceQuotas := &ResourceQuotaListV1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := resourceQuotaClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		resourceQuotas.Items = append(resourceQuotas.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return resourceQuotas, err
+}
+
+func getNamespaces(ctx context.Context, clientset kubernetes.Interface) (*NamespaceListV1, error) {
 	namespaceClient := clientset.CoreV1().Namespaces()
 	listOptions := ListOptionsV1{}
 
-	if rootConfig.namespace != "" {
+	if len(rootConfig.namespaces) == 1 {
 		// Select only the specified namespace
 		listOptions = ListOptionsV1{
-			FieldSelector: fmt.Sprintf("metadata.name=%s", rootConfig.namespace),
+			FieldSelector: fmt.Sprintf("metadata.name=%s", rootConfig.namespaces[0]),
 		}
 	}
 
-	namespaces, err := namespaceClient.List(listOptions)
-
-	if err != nil {
-		log.Error(err)
-	}
-
-	return namespaces
+	namespaces := &NamespaceListV1{}
+	err := listAllPages(ctx, listOptions, func(options ListOptionsV1) (string, error) {
+		page, err := namespaceClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		namespaces.Items = append(namespaces.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return namespaces, err
 }
 
 func getKubernetesVersion(clientset kubernetes.Interface) (*version.Info, error) {
 	discoveryClient := clientset.Discovery()
 	return discoveryClient.ServerVersion()
 }
+
+func getServiceAccounts(ctx context.Context, clientset kubernetes.Interface, namespace string) (*ServiceAccountListV1, error) {
+	serviceAccountClient := clientset.CoreV1().ServiceAccounts(namespace)
+	serviceAccounts := &ServiceAccountListV1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := serviceAccountClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		serviceAccounts.Items = append(serviceAccounts.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return serviceAccounts, err
+}
+
+func getRoles(ctx context.Context, clientset kubernetes.Interface, namespace string) (*RoleListV1, error) {
+	roleClient := clientset.RbacV1().Roles(namespace)
+	roles := &RoleListV1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := roleClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		roles.Items = append(roles.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return roles, err
+}
+
+func getRoleBindings(ctx context.Context, clientset kubernetes.Interface, namespace string) (*RoleBindingListV1, error) {
+	roleBindingClient := clientset.RbacV1().RoleBindings(namespace)
+	roleBindings := &RoleBindingListV1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := roleBindingClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		roleBindings.Items = append(roleBindings.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return roleBindings, err
+}
+
+func getClusterRoles(ctx context.Context, clientset kubernetes.Interface) (*ClusterRoleListV1, error) {
+	clusterRoleClient := clientset.RbacV1().ClusterRoles()
+	clusterRoles := &ClusterRoleListV1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := clusterRoleClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		clusterRoles.Items = append(clusterRoles.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return clusterRoles, err
+}
+
+func getClusterRoleBindings(ctx context.Context, clientset kubernetes.Interface) (*ClusterRoleBindingListV1, error) {
+	clusterRoleBindingClient := clientset.RbacV1().ClusterRoleBindings()
+	clusterRoleBindings := &ClusterRoleBindingListV1{}
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		page, err := clusterRoleBindingClient.List(options)
+		if err != nil {
+			return "", err
+		}
+		clusterRoleBindings.Items = append(clusterRoleBindings.Items, page.Items...)
+		return page.Continue, nil
+	})
+	return clusterRoleBindings, err
+}
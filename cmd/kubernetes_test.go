@@ -2,18 +2,25 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	"k8s.io/apimachinery/pkg/version"
 	fakediscovery "k8s.io/client-go/discovery/fake"
 	fakeclientset "k8s.io/client-go/kubernetes/fake"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 type TestK8sClientInCluster struct{}
@@ -75,7 +82,7 @@ func TestKubeClientConfig(t *testing.T) {
 
 		rootConfig = tt.cfg
 		buf.Reset()
-		kubeClientConfig(tt.client)
+		kubeClientConfig(tt.client, "")
 		logs := buf.String()
 		assert.Contains(t, logs, tt.log, tt.msg)
 
@@ -110,11 +117,85 @@ func TestKubeClientConfigLocal(t *testing.T) {
 		kubeConfig: "/notarealfile",
 	}
 
-	_, err := kubeClientConfigLocal()
+	_, err := kubeClientConfigLocal("")
 	assert.Equal(t, ErrNoReadableKubeConfig, err,
 		"kubeClientConfigLocal did not return expected error when kubeconfig file doesn't exist")
 }
 
+const testExecKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: eks
+  cluster:
+    server: https://eks.example.com
+contexts:
+- name: eks
+  context:
+    cluster: eks
+    user: iam
+current-context: eks
+users:
+- name: iam
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws-iam-authenticator
+      args: ["token", "-i", "cluster"]
+`
+
+func TestKubeClientConfigOverrides(t *testing.T) {
+	kubeConfig := writeTestKubeConfig(t)
+	defer os.Remove(kubeConfig)
+	execKubeConfig, err := ioutil.TempFile("", "kubeaudit_kubeconfig")
+	assert.Nil(t, err)
+	defer os.Remove(execKubeConfig.Name())
+	execKubeConfig.WriteString(testExecKubeConfig)
+	execKubeConfig.Close()
+	defer func() {
+		rootConfig = rootFlags{}
+		kubeConfigOverrides = clientcmd.ConfigOverrides{}
+	}()
+
+	// Files listed like in $KUBECONFIG are merged, the first one sets the current context
+	rootConfig = rootFlags{kubeConfig: strings.Join([]string{kubeConfig, execKubeConfig.Name()}, string(filepath.ListSeparator))}
+	merged, err := kubeConfigLoadingRules().Load()
+	assert.Nil(t, err)
+	assert.Len(t, merged.Contexts, 3)
+	config, err := kubeClientConfig(TestK8sClientInCluster{}, "eks")
+	assert.Nil(t, err)
+	assert.Equal(t, "aws-iam-authenticator", config.ExecProvider.Command)
+
+	rootConfig = rootFlags{kubeConfig: kubeConfig}
+	kubeConfigOverrides.AuthInfo.Token = "override-token"
+	kubeConfigOverrides.AuthInfo.Impersonate = "system:serviceaccount:shop:web"
+	kubeConfigOverrides.AuthInfo.ImpersonateGroups = []string{"system:serviceaccounts"}
+	kubeConfigOverrides.ClusterInfo.Server = "https://override.example.com"
+	config, err = kubeClientConfig(TestK8sClientInCluster{}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://override.example.com", config.Host)
+	assert.Equal(t, "override-token", config.BearerToken)
+	assert.Equal(t, "system:serviceaccount:shop:web", config.Impersonate.UserName)
+	assert.Equal(t, []string{"system:serviceaccounts"}, config.Impersonate.Groups)
+
+	// No kubeconfig is needed when the server is given as a flag
+	rootConfig = rootFlags{kubeConfig: "/notarealfile"}
+	config, err = kubeClientConfig(TestK8sClientInCluster{}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://override.example.com", config.Host)
+}
+
+func TestInClusterConfigOverrides(t *testing.T) {
+	defer func() { kubeConfigOverrides = clientcmd.ConfigOverrides{} }()
+	rootConfig = rootFlags{}
+	kubeConfigOverrides.AuthInfo.TokenFile = "/var/run/secrets/other/token"
+	kubeConfigOverrides.AuthInfo.Impersonate = "auditor"
+
+	config, err := kubeClientConfig(TestK8sClientInCluster{}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "/var/run/secrets/other/token", config.BearerTokenFile)
+	assert.Equal(t, "auditor", config.Impersonate.UserName)
+}
+
 func TestGetKubernetesVersion(t *testing.T) {
 	client := fakeclientset.NewSimpleClientset()
 	fakeDiscovery, ok := client.Discovery().(*fakediscovery.FakeDiscovery)
@@ -133,3 +214,68 @@ func TestGetKubernetesVersion(t *testing.T) {
 	assert.Nil(t, err)
 	assert.EqualValues(t, *fakeDiscovery.FakedServerVersion, *r)
 }
+
+func TestListAllPagesFollowsContinue(t *testing.T) {
+	var calls []ListOptionsV1
+	tokens := []string{"page2", "page3", ""}
+	err := listAllPages(context.Background(), ListOptionsV1{FieldSelector: "a=b"}, func(options ListOptionsV1) (string, error) {
+		calls = append(calls, options)
+		return tokens[len(calls)-1], nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, calls, 3)
+	assert.Equal(t, "", calls[0].Continue)
+	assert.Equal(t, "page2", calls[1].Continue)
+	assert.Equal(t, "page3", calls[2].Continue)
+	for _, call := range calls {
+		assert.EqualValues(t, listPageSize, call.Limit)
+		assert.Equal(t, "a=b", call.FieldSelector)
+	}
+}
+
+func TestListAllPagesStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := listAllPages(ctx, ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		calls++
+		cancel()
+		return "more", nil
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestListAllPagesReturnsError(t *testing.T) {
+	listErr := errors.New("forbidden")
+	err := listAllPages(context.Background(), ListOptionsV1{}, func(options ListOptionsV1) (string, error) {
+		return "", listErr
+	})
+	assert.Equal(t, listErr, err)
+}
+
+func TestGetKubeResources(t *testing.T) {
+	rootConfig.namespaces = nil
+	client := fakeclientset.NewSimpleClientset(
+		&NamespaceV1{ObjectMeta: ObjectMetaV1{Name: "ns1"}},
+		&PodV1{ObjectMeta: ObjectMetaV1{Name: "pod1", Namespace: "ns1"}},
+		&PodV1{ObjectMeta: ObjectMetaV1{Name: "pod2", Namespace: "ns1"}},
+		&DeploymentV1{ObjectMeta: ObjectMetaV1{Name: "deployment1", Namespace: "ns1"}},
+		&StatefulSetV1{ObjectMeta: ObjectMetaV1{Name: "statefulset1", Namespace: "ns1"}},
+	)
+	allowAccessReviews(client, func(attributes *authorizationv1.ResourceAttributes) bool { return true })
+	scanCoverage.reset()
+	resources, err := getKubeResources(context.Background(), client, "")
+	assert.Nil(t, err)
+	assert.Len(t, resources, 5)
+	assert.True(t, scanCoverage.Complete())
+}
+
+func TestGetKubeResourcesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := fakeclientset.NewSimpleClientset()
+	allowAccessReviews(client, func(attributes *authorizationv1.ResourceAttributes) bool { return true })
+	resources, err := getKubeResources(ctx, client, "")
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, resources)
+}
@@ -1,58 +1,154 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
+	apiv1 "k8s.io/api/core/v1"
 	k8sResource "k8s.io/apimachinery/pkg/api/resource"
 )
 
+// limitedResources are the resources containers request and are limited to, in the order they are checked.
+var limitedResources = []ResourceNameV1{apiv1.ResourceCPU, apiv1.ResourceMemory, apiv1.ResourceEphemeralStorage}
+
 type limitFlags struct {
-	cpuArg    string
-	cpu       k8sResource.Quantity
-	memoryArg string
-	memory    k8sResource.Quantity
+	cpuArg                  string
+	cpu                     k8sResource.Quantity
+	memoryArg               string
+	memory                  k8sResource.Quantity
+	ephemeralStorageArg     string
+	ephemeralStorage        k8sResource.Quantity
+	requireEphemeralStorage bool
+	ratios                  map[ResourceNameV1]float64
 }
 
 var limitConfig limitFlags
 
-func (limit *limitFlags) parseLimitFlags() {
-	if len(limit.cpuArg) != 0 {
-		quantity, err := k8sResource.ParseQuantity(limit.cpuArg)
+// limitsConfig returns the thresholds of the limits audit from the config.
+func limitsConfig() KubeauditConfigLimits {
+	if configured := auditConfigSpec().Limits; configured != nil {
+		return *configured
+	}
+	return KubeauditConfigLimits{}
+}
+
+// parseQuantities parses the quantities of a map of the config keyed by resource name.
+func parseQuantities(field string, values map[string]string) ResourceListV1 {
+	quantities := ResourceListV1{}
+	for name, value := range values {
+		quantity, err := k8sResource.ParseQuantity(value)
 		if err != nil {
-			log.Error("Wrong cpu argument: " + err.Error())
-			return
+			log.Errorf("Wrong %s %s in the config: %v", field, name, err)
+			continue
 		}
-		limit.cpu = quantity
+		quantities[ResourceNameV1(name)] = quantity
 	}
+	return quantities
+}
 
-	if len(limit.memoryArg) != 0 {
-		quantity, err := k8sResource.ParseQuantity(limit.memoryArg)
+// parseLimitFlags parses the maximum limits given as flags, which take precedence over the ones of the config, and
+// reads the other thresholds from the config.
+func (limit *limitFlags) parseLimitFlags() {
+	config := limitsConfig()
+	max := parseQuantities("max", config.Max)
+	limit.cpu, limit.memory, limit.ephemeralStorage = max[apiv1.ResourceCPU], max[apiv1.ResourceMemory], max[apiv1.ResourceEphemeralStorage]
+	limit.requireEphemeralStorage = config.RequireEphemeralStorage
+	limit.ratios = map[ResourceNameV1]float64{}
+	for name, ratio := range config.MaxLimitRequestRatio {
+		limit.ratios[ResourceNameV1(name)] = ratio
+	}
+
+	flags := []struct {
+		name     string
+		arg      string
+		quantity *k8sResource.Quantity
+	}{
+		{"cpu", limit.cpuArg, &limit.cpu},
+		{"memory", limit.memoryArg, &limit.memory},
+		{"ephemeral-storage", limit.ephemeralStorageArg, &limit.ephemeralStorage},
+	}
+	for _, flag := range flags {
+		if len(flag.arg) == 0 {
+			continue
+		}
+		quantity, err := k8sResource.ParseQuantity(flag.arg)
 		if err != nil {
-			log.Error("Wrong cpu argument: " + err.Error())
-			return
+			log.Errorf("Wrong %s argument: %v", flag.name, err)
+			continue
 		}
-		limit.memory = quantity
+		*flag.quantity = quantity
+	}
+}
+
+// effectiveRequests returns the requests of the container, along with its limits for the resources it doesn't
+// request since the API server sets the requests to the limits when only the limits are set.
+func effectiveRequests(container ContainerV1) ResourceListV1 {
+	requests := ResourceListV1{}
+	for name, limit := range container.Resources.Limits {
+		requests[name] = limit
+	}
+	for name, request := range container.Resources.Requests {
+		requests[name] = request
 	}
+	return requests
 }
 
 func checkLimits(container ContainerV1, limits limitFlags, result *Result) {
+	checkRequests(container, result)
 	if container.Resources.Limits == nil {
-		occ := Occurrence{id: ErrorResourcesLimitsNil, kind: Warn, message: "Resource limit not set, please set it!"}
+		occ := Occurrence{container: container.Name, id: ErrorResourcesLimitsNil, kind: Warn, message: "Resource limit not set, please set it!"}
 		result.Occurrences = append(result.Occurrences, occ)
 		return
 	}
 
 	checkCPULimit(container, limits, result)
 	checkMemoryLimit(container, limits, result)
+	checkEphemeralStorageLimit(container, limits, result)
+	checkLimitRequestRatios(container, limits, result)
+}
+
+func checkRequests(container ContainerV1, result *Result) {
+	requests := effectiveRequests(container)
+	if len(requests) == 0 {
+		occ := Occurrence{container: container.Name, id: ErrorResourcesRequestsNil, kind: Warn, message: "Resource requests not set, please set them!"}
+		result.Occurrences = append(result.Occurrences, occ)
+		return
+	}
+
+	if cpu := requests.Cpu(); cpu.IsZero() {
+		occ := Occurrence{container: container.Name, id: ErrorResourcesRequestsCPUNil, kind: Warn, message: "CPU request not set, please set it!"}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+	if memory := requests.Memory(); memory.IsZero() {
+		occ := Occurrence{container: container.Name, id: ErrorResourcesRequestsMemoryNil, kind: Warn, message: "Memory request not set, please set it!"}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+
+	for _, name := range limitedResources {
+		request, requested := container.Resources.Requests[name]
+		limit, limited := container.Resources.Limits[name]
+		if !requested || !limited || request.Cmp(limit) <= 0 {
+			continue
+		}
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorResourcesRequestExceedsLimit,
+			kind:      Error,
+			message:   fmt.Sprintf("%s request %s is higher than its limit %s, the container will be rejected. Please adjust it!", name, request.String(), limit.String()),
+			metadata:  Metadata{"Resource": string(name), "Request": request.String(), "Limit": limit.String()},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
 }
 
 func checkCPULimit(container ContainerV1, limits limitFlags, result *Result) {
 	cpu := container.Resources.Limits.Cpu()
 	if cpu == nil || cpu.IsZero() {
-		occ := Occurrence{id: ErrorResourcesLimitsCPUNil, kind: Warn, message: "CPU limit not set, please set it!"}
+		occ := Occurrence{container: container.Name, id: ErrorResourcesLimitsCPUNil, kind: Warn, message: "CPU limit not set, please set it!"}
 		result.Occurrences = append(result.Occurrences, occ)
 		return
 	}
@@ -61,7 +157,7 @@ func checkCPULimit(container ContainerV1, limits limitFlags, result *Result) {
 		result.CPULimitActual = cpu.String()
 		result.CPULimitMax = limits.cpu.String()
 		message := fmt.Sprintf("CPU limit exceeded, it is set to %s but it must not exceed %s. Please adjust it!", cpu.String(), limits.cpu.String())
-		occ := Occurrence{id: ErrorResourcesLimitsCPUExceeded, kind: Warn, message: message}
+		occ := Occurrence{container: container.Name, id: ErrorResourcesLimitsCPUExceeded, kind: Warn, message: message}
 		result.Occurrences = append(result.Occurrences, occ)
 	}
 }
@@ -69,7 +165,7 @@ func checkCPULimit(container ContainerV1, limits limitFlags, result *Result) {
 func checkMemoryLimit(container ContainerV1, limits limitFlags, result *Result) {
 	memory := container.Resources.Limits.Memory()
 	if memory == nil || memory.IsZero() {
-		occ := Occurrence{id: ErrorResourcesLimitsMemoryNil, kind: Warn, message: "Memory limit not set, please set it!"}
+		occ := Occurrence{container: container.Name, id: ErrorResourcesLimitsMemoryNil, kind: Warn, message: "Memory limit not set, please set it!"}
 		result.Occurrences = append(result.Occurrences, occ)
 		return
 	}
@@ -78,12 +174,166 @@ func checkMemoryLimit(container ContainerV1, limits limitFlags, result *Result)
 		result.MEMLimitActual = memory.String()
 		result.MEMLimitMax = limits.memory.String()
 		message := fmt.Sprintf("Memory limit exceeded, it is set to %s but it must not exceed %s. Please adjust it!", memory.String(), limits.memory.String())
-		occ := Occurrence{id: ErrorResourcesLimitsMemoryExceeded, kind: Warn, message: message}
+		occ := Occurrence{container: container.Name, id: ErrorResourcesLimitsMemoryExceeded, kind: Warn, message: message}
 		result.Occurrences = append(result.Occurrences, occ)
 	}
 }
 
+// checkEphemeralStorageLimit checks the limit of the local storage the container may use. It's only required when
+// the config asks for it since few clusters enforce it.
+func checkEphemeralStorageLimit(container ContainerV1, limits limitFlags, result *Result) {
+	storage := container.Resources.Limits.StorageEphemeral()
+	if storage == nil || storage.IsZero() {
+		if limits.requireEphemeralStorage {
+			occ := Occurrence{container: container.Name, id: ErrorResourcesLimitsEphemeralStorageNil, kind: Warn, message: "Ephemeral storage limit not set, please set it!"}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+		return
+	}
+
+	if limits.ephemeralStorage.Value() > 0 && storage.Value() > limits.ephemeralStorage.Value() {
+		message := fmt.Sprintf("Ephemeral storage limit exceeded, it is set to %s but it must not exceed %s. Please adjust it!", storage.String(), limits.ephemeralStorage.String())
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorResourcesLimitsEphemeralStorageExceeded,
+			kind:      Warn,
+			message:   message,
+			metadata:  Metadata{"Limit": storage.String(), "Max": limits.ephemeralStorage.String()},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+}
+
+// checkLimitRequestRatios checks that limits aren't too many times the requests, which lets a node be overcommitted
+// by containers using much more than they requested.
+func checkLimitRequestRatios(container ContainerV1, limits limitFlags, result *Result) {
+	requests := effectiveRequests(container)
+	for _, name := range limitedResources {
+		maxRatio, ok := limits.ratios[name]
+		if !ok || maxRatio <= 0 {
+			continue
+		}
+		limit, limited := container.Resources.Limits[name]
+		request := requests[name]
+		if !limited || request.IsZero() {
+			continue
+		}
+		ratio := float64(limit.MilliValue()) / float64(request.MilliValue())
+		if ratio <= maxRatio {
+			continue
+		}
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorResourcesLimitRequestRatioExceeded,
+			kind:      Warn,
+			message:   fmt.Sprintf("%s limit is %.3g times its request but it must not exceed %.3g times. Please adjust it!", name, ratio, maxRatio),
+			metadata: Metadata{
+				"Resource": string(name),
+				"Request":  request.String(),
+				"Limit":    limit.String(),
+				"Ratio":    strconv.FormatFloat(ratio, 'g', 3, 64),
+				"MaxRatio": strconv.FormatFloat(maxRatio, 'g', -1, 64),
+			},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+}
+
+// getNamespaceLimitsResources returns the LimitRanges and ResourceQuotas of a namespace, read the same way as its
+// network policies.
+func getNamespaceLimitsResources(cluster, namespace string) (limitRanges []LimitRangeV1, quotas []ResourceQuotaV1, err error) {
+	var resources []Resource
+	switch {
+	case rootConfig.snapshot != "":
+		resources, err = loadSnapshot(rootConfig.snapshot)
+	case rootConfig.manifest != "":
+		resources, err = getKubeResourcesManifest(rootConfig.manifest)
+	default:
+		kube, err := kubeClientForContext(cluster)
+		if err != nil {
+			return nil, nil, err
+		}
+		limitRangeList, err := getLimitRanges(context.Background(), kube, namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		quotaList, err := getResourceQuotas(context.Background(), kube, namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		return limitRangeList.Items, quotaList.Items, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, resource := range resources {
+		if clusterNameOf(resource) != cluster {
+			continue
+		}
+		switch kubeType := resource.(type) {
+		case *LimitRangeV1:
+			if kubeType.Namespace == namespace {
+				limitRanges = append(limitRanges, *kubeType)
+			}
+		case *ResourceQuotaV1:
+			if kubeType.Namespace == namespace {
+				quotas = append(quotas, *kubeType)
+			}
+		}
+	}
+	return limitRanges, quotas, nil
+}
+
+func checkNamespaceLimits(limitRanges []LimitRangeV1, quotas []ResourceQuotaV1, result *Result, nsName string) {
+	if len(limitRanges) == 0 {
+		occ := Occurrence{
+			id:       ErrorNamespaceLimitRangeMissing,
+			kind:     Warn,
+			message:  "Namespace has no LimitRange, containers without requests or limits get none. Please add one!",
+			metadata: Metadata{"Namespace": nsName},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+	if len(quotas) == 0 {
+		occ := Occurrence{
+			id:       ErrorNamespaceResourceQuotaMissing,
+			kind:     Warn,
+			message:  "Namespace has no ResourceQuota, its workloads may use the resources of the whole cluster. Please add one!",
+			metadata: Metadata{"Namespace": nsName},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+}
+
+func auditNamespaceLimits(resource Resource, nsName string) (results []Result) {
+	result, err, warn := newResultFromResource(resource)
+	if warn != nil {
+		log.Warn(warn)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	limitRanges, quotas, err := getNamespaceLimitsResources(result.Cluster, nsName)
+	if err != nil {
+		log.Error(err)
+		scanCoverage.addGap(result.Cluster, "LimitRange", nsName, err.Error())
+		return
+	}
+
+	checkNamespaceLimits(limitRanges, quotas, result, nsName)
+	if len(result.Occurrences) > 0 {
+		results = append(results, *result)
+	}
+	return
+}
+
 func auditLimits(limits limitFlags, resource Resource) (results []Result) {
+	if nsName := getNamespaceName(resource); nsName != "" {
+		return auditNamespaceLimits(resource, nsName)
+	}
 	limits.parseLimitFlags()
 
 	for _, container := range getContainers(resource) {
@@ -110,12 +360,19 @@ var limitsCmd = &cobra.Command{
 	Short: "Audit containers running with limits",
 	Long: `This command determines which containers in a kubernetes cluster have and do not exceed specified cpu and memory limits.
 
-A PASS is given when a container has cpu and memory limits
-A FAIL is given when a container does not have cpu and memory limits
+A PASS is given when a container has cpu and memory requests and limits
+A FAIL is given when a container does not have cpu and memory limits or
+requests, when a request is higher than its limit, or when a limit exceeds
+the maximum or is too many times its request
+
+Every namespace must also have a LimitRange and a ResourceQuota.
+
+The maximum limits, limit to request ratios and whether ephemeral-storage limits
+are required can be set in the config file; the flags take precedence.
 
 Example usage:
 kubeaudit limits
-kubeaudit limits --cpu 500m --memory 256Mi`,
+kubeaudit limits --cpu 500m --memory 256Mi --ephemeral-storage 1Gi`,
 	Run: runAudit(auditLimits),
 }
 
@@ -123,4 +380,5 @@ func init() {
 	RootCmd.AddCommand(limitsCmd)
 	limitsCmd.Flags().StringVar(&limitConfig.cpuArg, "cpu", "", "max cpu limit")
 	limitsCmd.Flags().StringVar(&limitConfig.memoryArg, "memory", "", "max memory limit")
+	limitsCmd.Flags().StringVar(&limitConfig.ephemeralStorageArg, "ephemeral-storage", "", "max ephemeral-storage limit")
 }
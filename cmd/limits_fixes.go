@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// resourceDefaults returns the default limits and requests of containers in a namespace. Those of its LimitRange come
+// first since the cluster applies them to containers which don't set theirs, then those of the config.
+func resourceDefaults(cluster, namespace string) (limits, requests ResourceListV1) {
+	limits, requests = ResourceListV1{}, ResourceListV1{}
+	limitRanges, _, err := getNamespaceLimitsResources(cluster, namespace)
+	if err != nil {
+		log.Warnf("Unable to read the LimitRanges of namespace %s: %v", namespace, err)
+	}
+	for _, limitRange := range limitRanges {
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type != apiv1.LimitTypeContainer {
+				continue
+			}
+			for name, quantity := range item.Default {
+				if _, ok := limits[name]; !ok {
+					limits[name] = quantity
+				}
+			}
+			for name, quantity := range item.DefaultRequest {
+				if _, ok := requests[name]; !ok {
+					requests[name] = quantity
+				}
+			}
+		}
+	}
+
+	config := limitsConfig()
+	for name, quantity := range parseQuantities("defaultLimits", config.DefaultLimits) {
+		if _, ok := limits[name]; !ok {
+			limits[name] = quantity
+		}
+	}
+	for name, quantity := range parseQuantities("defaultRequests", config.DefaultRequests) {
+		if _, ok := requests[name]; !ok {
+			requests[name] = quantity
+		}
+	}
+	return limits, requests
+}
+
+// fillResources fills in the limits and requests the container doesn't set from the defaults. A request is only
+// filled in when the container sets no limit for the resource either, since it otherwise gets the limit as request,
+// and it's kept under the limit.
+func fillResources(container ContainerV1, defaultLimits, defaultRequests ResourceListV1) ContainerV1 {
+	resources := container.Resources.DeepCopy()
+	for _, name := range limitedResources {
+		_, limited := resources.Limits[name]
+		request, requested := resources.Requests[name]
+		if !limited && !requested {
+			if defaultRequest, ok := defaultRequests[name]; ok {
+				request, requested = defaultRequest, true
+				if resources.Requests == nil {
+					resources.Requests = ResourceListV1{}
+				}
+				resources.Requests[name] = request
+			}
+		}
+		if defaultLimit, ok := defaultLimits[name]; ok && !limited {
+			if requested && request.Cmp(defaultLimit) > 0 {
+				defaultLimit = request
+			}
+			if resources.Limits == nil {
+				resources.Limits = ResourceListV1{}
+			}
+			resources.Limits[name] = defaultLimit
+		}
+	}
+	container.Resources = *resources
+	return container
+}
+
+// fixResourceLimits fills in the requests and limits containers don't set, from the defaults of the LimitRange of
+// their namespace or of the config.
+func fixResourceLimits(resource Resource) Resource {
+	if _, ok := podSpecOf(resource); !ok {
+		return resource
+	}
+	meta, ok := resource.(metav1.Object)
+	if !ok {
+		return resource
+	}
+	defaultLimits, defaultRequests := resourceDefaults(meta.GetClusterName(), meta.GetNamespace())
+	if len(defaultLimits) == 0 && len(defaultRequests) == 0 {
+		return resource
+	}
+	var containers []ContainerV1
+	for _, container := range getContainers(resource) {
+		containers = append(containers, fillResources(container, defaultLimits, defaultRequests))
+	}
+	return setContainers(resource, containers)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sResource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+// containerResources returns the requests and limits of each container as strings.
+func containerResources(resource Resource) map[string]map[string]string {
+	resources := map[string]map[string]string{}
+	for _, container := range getContainers(resource) {
+		quantities := map[string]string{}
+		for name, quantity := range container.Resources.Requests {
+			quantities["requests."+string(name)] = quantity.String()
+		}
+		for name, quantity := range container.Resources.Limits {
+			quantities["limits."+string(name)] = quantity.String()
+		}
+		resources[container.Name] = quantities
+	}
+	return resources
+}
+
+func TestFixResourceLimitsFromLimitRange(t *testing.T) {
+	rootConfig.manifest = filepath.Join(path, "namespace_limits_v1.yml")
+	resources, err := getKubeResourcesManifest(rootConfig.manifest)
+	require.NoError(t, err)
+	fixed := containerResources(fixResourceLimits(resources[len(resources)-1]))
+	assert.Equal(t, map[string]string{"requests.cpu": "50m", "limits.cpu": "250m", "limits.memory": "512Mi"}, fixed["unset"])
+	assert.Equal(t, map[string]string{"requests.cpu": "1", "limits.cpu": "1", "limits.memory": "512Mi"}, fixed["request-only"])
+	assert.Equal(t, map[string]string{"limits.cpu": "100m", "limits.memory": "512Mi"}, fixed["limit-only"])
+}
+
+func TestFixResourceLimitsFromConfig(t *testing.T) {
+	rootConfig.auditConfig = "../configs/limits_from_config.yml"
+	defer func() { rootConfig.auditConfig = "" }()
+	rootConfig.manifest = filepath.Join(path, "namespace_limits_v1.yml")
+	resources, err := getKubeResourcesManifest(rootConfig.manifest)
+	require.NoError(t, err)
+	fixed := containerResources(fixResourceLimits(resources[len(resources)-1]))
+	// The LimitRange of the namespace comes first
+	assert.Equal(t, map[string]string{"requests.cpu": "50m", "requests.memory": "128Mi", "limits.cpu": "250m",
+		"limits.memory": "512Mi", "limits.ephemeral-storage": "512Mi"}, fixed["unset"])
+	assert.Equal(t, map[string]string{"requests.memory": "128Mi", "limits.cpu": "100m", "limits.memory": "512Mi",
+		"limits.ephemeral-storage": "512Mi"}, fixed["limit-only"])
+
+	rootConfig.manifest = filepath.Join(path, "resources_requests_v1.yml")
+	resources, err = getKubeResourcesManifest(rootConfig.manifest)
+	require.NoError(t, err)
+	fixed = containerResources(fixResourceLimits(resources[0]))
+	assert.Equal(t, map[string]string{"requests.cpu": "100m", "requests.memory": "128Mi", "limits.cpu": "500m",
+		"limits.memory": "256Mi", "limits.ephemeral-storage": "512Mi"}, fixed["requests-only"])
+	assert.Equal(t, map[string]string{"requests.cpu": "1", "requests.memory": "128Mi", "limits.cpu": "500m",
+		"limits.memory": "256Mi", "limits.ephemeral-storage": "512Mi"}, fixed["request-exceeds-limit"])
+}
+
+func TestFillResourcesKeepsRequestsUnderLimits(t *testing.T) {
+	container := ContainerV1{Name: "app"}
+	container.Resources.Requests = ResourceListV1{"memory": k8sResource.MustParse("1Gi")}
+	defaults := ResourceListV1{"memory": k8sResource.MustParse("256Mi")}
+	filled := fillResources(container, defaults, defaults)
+	assert.Equal(t, "1Gi", filled.Resources.Limits.Memory().String())
+	assert.Equal(t, "1Gi", filled.Resources.Requests.Memory().String())
+	// The container is left alone
+	assert.Nil(t, container.Resources.Limits)
+}
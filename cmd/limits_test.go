@@ -1,17 +1,29 @@
 package cmd
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// byContainerOrNamespace indexes the occurrences by container, the namespace ones by namespace.
+func byContainerOrNamespace(result Result, occ Occurrence) string {
+	if occ.container == "" {
+		return occ.metadata["Namespace"]
+	}
+	return occ.container
+}
 
 func TestResourcesLimitsNilV1Beta1(t *testing.T) {
-	runAuditTest(t, "resources_limit_nil_v1beta1.yml", auditLimits, []int{ErrorResourcesLimitsNil})
+	runAuditTest(t, "resources_limit_nil_v1beta1.yml", auditLimits, []int{ErrorResourcesLimitsNil, ErrorResourcesRequestsNil})
 }
 
 func TestResourcesNoCPULimitV1Beta1(t *testing.T) {
-	runAuditTest(t, "resources_limit_no_cpu_v1beta1.yml", auditLimits, []int{ErrorResourcesLimitsCPUNil})
+	runAuditTest(t, "resources_limit_no_cpu_v1beta1.yml", auditLimits, []int{ErrorResourcesLimitsCPUNil, ErrorResourcesRequestsCPUNil})
 }
 
 func TestResourcesNoMemoryLimitV1Beta1(t *testing.T) {
-	runAuditTest(t, "resources_limit_no_memory_v1beta1.yml", auditLimits, []int{ErrorResourcesLimitsMemoryNil})
+	runAuditTest(t, "resources_limit_no_memory_v1beta1.yml", auditLimits, []int{ErrorResourcesLimitsMemoryNil, ErrorResourcesRequestsMemoryNil})
 }
 func TestResourcesCPULimitExceededV1Beta1(t *testing.T) {
 	runAuditTest(t, "resources_limit_v1beta1.yml", auditLimits, []int{ErrorResourcesLimitsCPUExceeded}, "600m", "")
@@ -20,3 +32,47 @@ func TestResourcesCPULimitExceededV1Beta1(t *testing.T) {
 func TestResourcesMemoryLimitExceededV1Beta1(t *testing.T) {
 	runAuditTest(t, "resources_limit_v1beta1.yml", auditLimits, []int{ErrorResourcesLimitsMemoryExceeded}, "", "384")
 }
+
+func TestResourcesRequestsV1(t *testing.T) {
+	results := runAuditTest(t, "resources_requests_v1.yml", auditLimits, []int{ErrorResourcesRequestExceedsLimit, ErrorResourcesLimitsNil})
+	occurrences := occurrenceIDs(indexOccurrences(results, byContainerOrNamespace))
+	assert.Equal(t, []int{ErrorResourcesRequestExceedsLimit}, occurrences["request-exceeds-limit"])
+	assert.Equal(t, []int{ErrorResourcesLimitsNil}, occurrences["requests-only"])
+	assert.NotContains(t, occurrences, "limits-only")
+	assert.NotContains(t, occurrences, "ratio-exceeded")
+}
+
+func TestResourcesLimitsFromConfig(t *testing.T) {
+	rootConfig.auditConfig = "../configs/limits_from_config.yml"
+	defer func() { rootConfig.auditConfig = "" }()
+	results := runAuditTest(t, "resources_requests_v1.yml", auditLimits, []int{ErrorResourcesRequestExceedsLimit, ErrorResourcesLimitsNil,
+		ErrorResourcesLimitRequestRatioExceeded, ErrorResourcesLimitsEphemeralStorageExceeded, ErrorResourcesLimitsEphemeralStorageNil})
+	occurrences := occurrenceIDs(indexOccurrences(results, byContainerOrNamespace))
+	assert.Equal(t, []int{ErrorResourcesRequestExceedsLimit}, occurrences["request-exceeds-limit"])
+	assert.Equal(t, []int{ErrorResourcesLimitsEphemeralStorageExceeded, ErrorResourcesLimitRequestRatioExceeded}, occurrences["ratio-exceeded"])
+	assert.Equal(t, []int{ErrorResourcesLimitsEphemeralStorageNil}, occurrences["no-ephemeral-storage"])
+	assert.NotContains(t, occurrences, "limits-only")
+
+	for _, result := range results {
+		for _, occ := range result.Occurrences {
+			if occ.id == ErrorResourcesLimitRequestRatioExceeded {
+				assert.Equal(t, "cpu", occ.metadata["Resource"])
+				assert.Equal(t, "10", occ.metadata["Ratio"])
+				assert.Equal(t, "4", occ.metadata["MaxRatio"])
+			}
+		}
+	}
+
+	// The flags take precedence over the config
+	runAuditTest(t, "resources_limit_v1beta1.yml", auditLimits, []int{ErrorResourcesLimitsEphemeralStorageNil})
+	runAuditTest(t, "resources_limit_v1beta1.yml", auditLimits, []int{ErrorResourcesLimitsEphemeralStorageNil, ErrorResourcesLimitsCPUExceeded}, "600m", "")
+}
+
+func TestNamespaceLimitsV1(t *testing.T) {
+	results := runAuditTest(t, "namespace_limits_v1.yml", auditLimits, []int{ErrorNamespaceLimitRangeMissing, ErrorNamespaceResourceQuotaMissing,
+		ErrorResourcesRequestsNil, ErrorResourcesLimitsNil, ErrorResourcesRequestsMemoryNil, ErrorResourcesLimitsMemoryNil})
+	occurrences := occurrenceIDs(indexOccurrences(results, byContainerOrNamespace))
+	assert.NotContains(t, occurrences, "limited")
+	assert.Equal(t, []int{ErrorNamespaceResourceQuotaMissing}, occurrences["no-quota"])
+	assert.Equal(t, []int{ErrorNamespaceLimitRangeMissing, ErrorNamespaceResourceQuotaMissing}, occurrences["unlimited"])
+}
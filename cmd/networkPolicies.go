@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	networking "k8s.io/api/networking/v1"
@@ -180,9 +182,21 @@ func checkNamespaceNetworkPolicies(netPols *NetworkPolicyListV1, result *Result,
 	return
 }
 
-func getNetworkPoliciesResources(namespace string) (netPolList *NetworkPolicyListV1, err error) {
+func getNetworkPoliciesResources(cluster, namespace string) (netPolList *NetworkPolicyListV1, err error) {
 	// Prevent the return of a nil value
 	netPolList = &NetworkPolicyListV1{}
+	if rootConfig.snapshot != "" {
+		resources, err := loadSnapshot(rootConfig.snapshot)
+		if err != nil {
+			return netPolList, err
+		}
+		for _, resource := range resources {
+			if netPol, ok := resource.(*NetworkPolicyV1); ok && netPol.ClusterName == cluster && netPol.Namespace == namespace {
+				netPolList.Items = append(netPolList.Items, *netPol)
+			}
+		}
+		return netPolList, nil
+	}
 	if rootConfig.manifest != "" {
 		resources, err := getKubeResourcesManifest(rootConfig.manifest)
 		if err != nil {
@@ -201,20 +215,16 @@ func getNetworkPoliciesResources(namespace string) (netPolList *NetworkPolicyLis
 		return netPolList, nil
 	}
 
-	currentRootNamespace := rootConfig.namespace
-	kube, err := kubeClient()
+	kube, err := kubeClientForContext(cluster)
 	if err != nil {
 		return netPolList, err
 	}
 
-	if namespace != "" {
-		rootConfig.namespace = namespace
+	if namespace == "" && len(rootConfig.namespaces) == 1 {
+		namespace = rootConfig.namespaces[0]
 	}
 
-	netPolList = getNetworkPolicies(kube)
-
-	rootConfig.namespace = currentRootNamespace
-	return netPolList, nil
+	return getNetworkPolicies(context.Background(), kube, namespace)
 }
 
 func getNamespaceName(resource Resource) string {
@@ -246,13 +256,15 @@ func auditNetworkPolicies(resource Resource) (results []Result) {
 	}
 
 	// Fetch NetworkPolicies for the current namespace
-	netPols, err := getNetworkPoliciesResources(nsName)
+	netPols, err := getNetworkPoliciesResources(result.Cluster, nsName)
 	if err != nil {
 		log.Error(err)
+		scanCoverage.addGap(result.Cluster, "NetworkPolicy", nsName, err.Error())
 		return
 	}
 
 	checkNamespaceNetworkPolicies(netPols, result, nsName)
+	checkNetworkPolicyCoverage(netPols, scanWorkloads.inNamespace(result.Cluster, nsName), result)
 	if len(result.Occurrences) > 0 {
 		results = append(results, *result)
 	}
@@ -270,6 +282,12 @@ An INFO log is given when a namespace has a default deny NetworkPolicy
 An WARN log is given whan a namespace contains a default allow NetworkPolicy
 An ERROR log is given when a namespace does not have a default deny NetworkPolicy
 
+The podSelector of every NetworkPolicy is matched with the pod labels of the
+workloads of its namespace. A WARN log is given when a workload isn't selected
+by any ingress or egress NetworkPolicy, when a NetworkPolicy selects no pod,
+when a rule allows traffic with every namespace or every address and when a
+NetworkPolicy allows all traffic of a workload despite a default deny.
+
 Example usage:
 kubeaudit np`,
 	Run: runAudit(auditNetworkPolicies),
@@ -1,6 +1,11 @@
 package cmd
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
 
 func TestNamespaceMissingDefaulDenyNetPol(t *testing.T) {
 	runAuditTest(t, "namespace_missing_default_deny_netpol.yml", auditNetworkPolicies, []int{ErrorMissingDefaultDenyIngressAndEgressNetworkPolicy})
@@ -56,3 +61,44 @@ func TestAllowedNamespaceMissingDefaultDenyIngressNetPolFromConfig(t *testing.T)
 	runAuditTest(t, "namespace_missing_default_deny_ingress_netpol.yml", auditNetworkPolicies, []int{ErrorMissingDefaultDenyIngressNetworkPolicyAllowed})
 	rootConfig.auditConfig = ""
 }
+
+func TestNetworkPolicyCoverage(t *testing.T) {
+	results := runAuditTest(t, "netpol_coverage_v1.yml", auditNetworkPolicies, []int{ErrorMissingDefaultDenyEgressNetworkPolicy,
+		WarningAllowAllIngressNetworkPolicyExists, ErrorNetworkPolicyEgressUnselected, ErrorNetworkPolicySelectsNothing,
+		ErrorNetworkPolicyAllNamespaces, ErrorNetworkPolicyAllAddresses, ErrorNetworkPolicyAllowAllOverridesDefaultDeny})
+	occurrences := map[int][]Metadata{}
+	for _, result := range results {
+		for _, occ := range result.Occurrences {
+			occurrences[occ.id] = append(occurrences[occ.id], occ.metadata)
+		}
+	}
+	assert.Equal(t, []Metadata{{"Workload": "statefulSet/database"}}, occurrences[ErrorNetworkPolicyEgressUnselected])
+	assert.Equal(t, []Metadata{{"PolicyName": "stale"}}, occurrences[ErrorNetworkPolicySelectsNothing])
+	assert.Equal(t, []Metadata{{"PolicyName": "web", "Direction": "egress"}}, occurrences[ErrorNetworkPolicyAllNamespaces])
+	assert.Equal(t, []Metadata{{"PolicyName": "web", "Direction": "egress", "CIDR": "0.0.0.0/0", "Except": "169.254.169.254/32"}},
+		occurrences[ErrorNetworkPolicyAllAddresses])
+	// The default deny doesn't protect the frontend, whose ingress is opened by another policy
+	assert.Equal(t, []Metadata{{"Workload": "deployment/frontend", "PolicyName": "web", "Direction": "ingress"}},
+		occurrences[ErrorNetworkPolicyAllowAllOverridesDefaultDeny])
+}
+
+func TestNetworkPolicyCoverageWithoutWorkloads(t *testing.T) {
+	runAuditTest(t, "namespace_has_default_deny_and_allow_all_netpol.yml", auditNetworkPolicies, []int{InfoDefaultDenyNetworkPolicyExists,
+		WarningAllowAllIngressNetworkPolicyExists, WarningAllowAllEgressNetworkPolicyExists})
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	podLabels := map[string]string{"app": "web", "tier": "frontend"}
+	assert.True(t, labelSelectorMatches(&metav1.LabelSelector{}, podLabels))
+	assert.False(t, labelSelectorMatches(nil, podLabels))
+	assert.True(t, labelSelectorMatches(&metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}, podLabels))
+	assert.False(t, labelSelectorMatches(&metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}, podLabels))
+	assert.True(t, labelSelectorMatches(&metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "tier", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"backend"}},
+		{Key: "debug", Operator: metav1.LabelSelectorOpDoesNotExist},
+	}}, podLabels))
+	assert.False(t, labelSelectorMatches(&metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "tier", Operator: metav1.LabelSelectorOpExists},
+		{Key: "app", Operator: metav1.LabelSelectorOpIn, Values: []string{"api"}},
+	}}, podLabels))
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// policyTypes returns whether a policy applies to the ingress and to the egress traffic of the pods it selects.
+// Policies without policyTypes apply to ingress, and to egress when they have egress rules.
+func policyTypes(netPol networking.NetworkPolicy) (ingress, egress bool) {
+	if len(netPol.Spec.PolicyTypes) == 0 {
+		return true, len(netPol.Spec.Egress) > 0
+	}
+	return isNetworkPolicyType(netPol, "Ingress"), isNetworkPolicyType(netPol, "Egress")
+}
+
+// labelSelectorMatches returns true if the selector selects the labels. A nil selector selects nothing and an empty
+// one everything.
+func labelSelectorMatches(selector *metav1.LabelSelector, objectLabels map[string]string) bool {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return s.Matches(labels.Set(objectLabels))
+}
+
+// isEmptySelector returns true if the selector is set but selects everything.
+func isEmptySelector(selector *metav1.LabelSelector) bool {
+	return selector != nil && len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0
+}
+
+// isAllAddresses returns true if the CIDR is every IPv4 or IPv6 address.
+func isAllAddresses(cidr string) bool {
+	return cidr == "0.0.0.0/0" || cidr == "::/0"
+}
+
+// policyRule is an ingress or egress rule of a policy, with the peers it allows traffic from or to and on which ports.
+type policyRule struct {
+	direction string
+	peers     []networking.NetworkPolicyPeer
+	ports     []networking.NetworkPolicyPort
+}
+
+// policyRules returns the ingress and egress rules of a policy.
+func policyRules(netPol networking.NetworkPolicy) (rules []policyRule) {
+	for _, rule := range netPol.Spec.Ingress {
+		rules = append(rules, policyRule{direction: "ingress", peers: rule.From, ports: rule.Ports})
+	}
+	for _, rule := range netPol.Spec.Egress {
+		rules = append(rules, policyRule{direction: "egress", peers: rule.To, ports: rule.Ports})
+	}
+	return rules
+}
+
+// preposition returns how peers relate to the traffic of a direction.
+func preposition(direction string) string {
+	if direction == "ingress" {
+		return "from"
+	}
+	return "to"
+}
+
+// checkPolicyPeers reports the rules of a policy which allow traffic with the pods of every namespace or with every
+// address, once per policy and direction.
+func checkPolicyPeers(netPol networking.NetworkPolicy, result *Result) {
+	reported := map[string]bool{}
+	for _, rule := range policyRules(netPol) {
+		for _, peer := range rule.peers {
+			if isEmptySelector(peer.NamespaceSelector) && !reported["namespaces/"+rule.direction] {
+				reported["namespaces/"+rule.direction] = true
+				occ := Occurrence{
+					id:   ErrorNetworkPolicyAllNamespaces,
+					kind: Warn,
+					message: fmt.Sprintf("NetworkPolicy allows %s traffic %s the pods of every namespace with an empty namespaceSelector. Please select the namespaces",
+						rule.direction, preposition(rule.direction)),
+					metadata: Metadata{"PolicyName": netPol.Name, "Direction": rule.direction},
+				}
+				result.Occurrences = append(result.Occurrences, occ)
+			}
+			if peer.IPBlock != nil && isAllAddresses(peer.IPBlock.CIDR) && !reported["addresses/"+rule.direction] {
+				reported["addresses/"+rule.direction] = true
+				occ := Occurrence{
+					id:   ErrorNetworkPolicyAllAddresses,
+					kind: Warn,
+					message: fmt.Sprintf("NetworkPolicy allows %s traffic %s any address with ipBlock %s. Please restrict the CIDR",
+						rule.direction, preposition(rule.direction), peer.IPBlock.CIDR),
+					metadata: Metadata{"PolicyName": netPol.Name, "Direction": rule.direction, "CIDR": peer.IPBlock.CIDR},
+				}
+				if len(peer.IPBlock.Except) > 0 {
+					occ.metadata["Except"] = strings.Join(peer.IPBlock.Except, ", ")
+				}
+				result.Occurrences = append(result.Occurrences, occ)
+			}
+		}
+	}
+}
+
+// allowsAll returns true if a rule allows traffic with everything, since it has no peers or one of them is every
+// address.
+func (rule policyRule) allowsAll() bool {
+	if len(rule.peers) == 0 {
+		return true
+	}
+	for _, peer := range rule.peers {
+		if peer.IPBlock != nil && isAllAddresses(peer.IPBlock.CIDR) && len(peer.IPBlock.Except) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNetworkPolicyCoverage matches the pod selectors of the policies of a namespace with the pods of its workloads.
+// It reports the workloads not selected by any ingress or egress policy, the workloads a policy opens to everything
+// despite a default deny and the policies selecting no pod, along with the rules allowing traffic with every namespace
+// or every address.
+func checkNetworkPolicyCoverage(netPols *NetworkPolicyListV1, workloads []indexedWorkload, result *Result) {
+	if len(netPols.Items) == 0 {
+		return
+	}
+	hasDenyAllIngress, hasDenyAllEgress := false, false
+	for _, netPol := range netPols.Items {
+		denyAllIngress, denyAllEgress := checkIfDefaultDenyPolicy(netPol)
+		hasDenyAllIngress = hasDenyAllIngress || denyAllIngress
+		hasDenyAllEgress = hasDenyAllEgress || denyAllEgress
+	}
+
+	for _, netPol := range netPols.Items {
+		checkPolicyPeers(netPol, result)
+
+		// A namespace without workloads, or whose workloads couldn't be read, says nothing about the selectors
+		if len(workloads) == 0 {
+			continue
+		}
+		selectsAny := false
+		for _, workload := range workloads {
+			if labelSelectorMatches(&netPol.Spec.PodSelector, workload.labels) {
+				selectsAny = true
+				break
+			}
+		}
+		if !selectsAny {
+			occ := Occurrence{
+				id:       ErrorNetworkPolicySelectsNothing,
+				kind:     Warn,
+				message:  "NetworkPolicy selects no pod of the namespace, please check its podSelector",
+				metadata: Metadata{"PolicyName": netPol.Name},
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+	}
+
+	for _, workload := range workloads {
+		ingressSelected, egressSelected := false, false
+		for _, netPol := range netPols.Items {
+			if !labelSelectorMatches(&netPol.Spec.PodSelector, workload.labels) {
+				continue
+			}
+			ingress, egress := policyTypes(netPol)
+			ingressSelected = ingressSelected || ingress
+			egressSelected = egressSelected || egress
+
+			// Policies add up, so a rule allowing everything undoes the default deny for the pods it selects
+			reported := map[string]bool{}
+			for _, rule := range policyRules(netPol) {
+				applies := rule.direction == "ingress" && ingress && hasDenyAllIngress || rule.direction == "egress" && egress && hasDenyAllEgress
+				if !applies || !rule.allowsAll() || reported[rule.direction] {
+					continue
+				}
+				reported[rule.direction] = true
+				occ := Occurrence{
+					id:   ErrorNetworkPolicyAllowAllOverridesDefaultDeny,
+					kind: Warn,
+					message: fmt.Sprintf("NetworkPolicy allows all %s traffic of the workload, which overrides the default deny of the namespace",
+						rule.direction),
+					metadata: Metadata{"Workload": workload.String(), "PolicyName": netPol.Name, "Direction": rule.direction},
+				}
+				result.Occurrences = append(result.Occurrences, occ)
+			}
+		}
+		if !ingressSelected {
+			occ := Occurrence{
+				id:       ErrorNetworkPolicyIngressUnselected,
+				kind:     Warn,
+				message:  "Workload isn't selected by any ingress NetworkPolicy, it accepts traffic from everywhere",
+				metadata: Metadata{"Workload": workload.String()},
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+		if !egressSelected {
+			occ := Occurrence{
+				id:       ErrorNetworkPolicyEgressUnselected,
+				kind:     Warn,
+				message:  "Workload isn't selected by any egress NetworkPolicy, it can connect anywhere",
+				metadata: Metadata{"Workload": workload.String()},
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+	}
+}
@@ -0,0 +1,577 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ExitCodeUnreachable is the exit status of kubeaudit netpol can-reach when the NetworkPolicies deny the connection.
+const ExitCodeUnreachable = 3
+
+// namespaceNameLabel is the label Kubernetes sets on every namespace to its name.
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+type netpolFlags struct {
+	from      string
+	to        string
+	port      string
+	protocol  string
+	addresses []string
+	format    string
+}
+
+var netpolConfig netpolFlags
+
+// netpolEndpoint is one end of a connection: the pods of a workload, or an address outside of the cluster.
+type netpolEndpoint struct {
+	cluster   string
+	namespace string
+	workload  *indexedWorkload
+	address   net.IP
+}
+
+func (e netpolEndpoint) String() string {
+	if e.workload == nil {
+		return e.address.String()
+	}
+	return e.namespace + "/" + e.workload.String()
+}
+
+// netpolPort is the port a connection is opened to.
+type netpolPort struct {
+	number   int32
+	protocol apiv1.Protocol
+}
+
+func (p netpolPort) String() string {
+	return fmt.Sprintf("%d/%s", p.number, p.protocol)
+}
+
+// netpolDecision is how the NetworkPolicies of one end of a connection treat it: the policies isolating its pods in
+// the direction of the connection and, among them, the ones with a rule allowing it.
+type netpolDecision struct {
+	endpoint  netpolEndpoint
+	direction string
+	isolating []string
+	allowing  []string
+}
+
+// allowed returns true if the pods aren't isolated in the direction of the connection or a rule allows it. Addresses
+// outside of the cluster have no NetworkPolicies so they allow everything.
+func (d netpolDecision) allowed() bool {
+	return len(d.isolating) == 0 || len(d.allowing) > 0
+}
+
+func (d netpolDecision) String() string {
+	switch {
+	case d.endpoint.workload == nil:
+		return fmt.Sprintf("%s of %s: allowed, it is outside of the cluster", d.direction, d.endpoint)
+	case len(d.isolating) == 0:
+		return fmt.Sprintf("%s of %s: allowed, no NetworkPolicy selects it for %s", d.direction, d.endpoint, d.direction)
+	case len(d.allowing) > 0:
+		return fmt.Sprintf("%s of %s: allowed by %s", d.direction, d.endpoint, strings.Join(d.allowing, ", "))
+	}
+	return fmt.Sprintf("%s of %s: denied, isolated by %s and no rule allows it", d.direction, d.endpoint,
+		strings.Join(d.isolating, ", "))
+}
+
+// netpolModel holds what decides whether a connection is allowed: the NetworkPolicies and the labels of the
+// namespaces and of the pods of every workload.
+type netpolModel struct {
+	policies   map[workloadScope][]networking.NetworkPolicy
+	namespaces map[workloadScope]map[string]string
+	workloads  []netpolEndpoint
+}
+
+// defaultNamespace returns the namespace of resources from manifests which don't set one.
+func defaultNamespace(namespace string) string {
+	if namespace == "" {
+		return apiv1.NamespaceDefault
+	}
+	return namespace
+}
+
+func newNetpolModel(resources []Resource) *netpolModel {
+	m := &netpolModel{policies: map[workloadScope][]networking.NetworkPolicy{}, namespaces: map[workloadScope]map[string]string{}}
+	for _, resource := range resources {
+		switch kubeType := resource.(type) {
+		case *NetworkPolicyV1:
+			scope := workloadScope{cluster: kubeType.ClusterName, namespace: defaultNamespace(kubeType.Namespace)}
+			m.policies[scope] = append(m.policies[scope], *kubeType)
+		case *NamespaceV1:
+			m.namespaces[workloadScope{cluster: kubeType.ClusterName, namespace: kubeType.Name}] = kubeType.Labels
+		}
+	}
+
+	var index WorkloadIndex
+	index.index(resources)
+	for _, scope := range index.scopes() {
+		for _, workload := range index.inNamespace(scope.cluster, scope.namespace) {
+			workload := workload
+			m.workloads = append(m.workloads, netpolEndpoint{cluster: scope.cluster, namespace: defaultNamespace(scope.namespace),
+				workload: &workload})
+		}
+	}
+	sort.SliceStable(m.workloads, func(i, j int) bool {
+		if m.workloads[i].cluster != m.workloads[j].cluster {
+			return m.workloads[i].cluster < m.workloads[j].cluster
+		}
+		return m.workloads[i].String() < m.workloads[j].String()
+	})
+	return m
+}
+
+// namespaceLabels returns the labels of a namespace, along with the one Kubernetes sets to its name.
+func (m *netpolModel) namespaceLabels(cluster, namespace string) map[string]string {
+	nsLabels := map[string]string{namespaceNameLabel: namespace}
+	for key, value := range m.namespaces[workloadScope{cluster: cluster, namespace: namespace}] {
+		nsLabels[key] = value
+	}
+	return nsLabels
+}
+
+// endpoint finds the workload referenced as namespace/name or namespace/kind/name, or parses an address.
+func (m *netpolModel) endpoint(ref string) (netpolEndpoint, error) {
+	if ip := net.ParseIP(ref); ip != nil {
+		return netpolEndpoint{address: ip}, nil
+	}
+	parts := strings.Split(ref, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return netpolEndpoint{}, fmt.Errorf("invalid endpoint %q, use namespace/workload, namespace/kind/workload or an IP address", ref)
+	}
+	namespace, kind, name := parts[0], "", parts[len(parts)-1]
+	if len(parts) == 3 {
+		kind = parts[1]
+	}
+
+	var found []netpolEndpoint
+	for _, endpoint := range m.workloads {
+		if endpoint.namespace == namespace && endpoint.workload.name == name && (kind == "" || strings.EqualFold(endpoint.workload.kubeType, kind)) {
+			found = append(found, endpoint)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return netpolEndpoint{}, fmt.Errorf("no workload %s", ref)
+	case 1:
+		return found[0], nil
+	}
+	var candidates []string
+	for _, endpoint := range found {
+		candidate := endpoint.String()
+		if endpoint.cluster != "" {
+			candidate += " in " + endpoint.cluster
+		}
+		candidates = append(candidates, candidate)
+	}
+	return netpolEndpoint{}, fmt.Errorf("%s is ambiguous, it could be %s. Please give the kind of the workload, or the cluster with --context",
+		ref, strings.Join(candidates, ", "))
+}
+
+// containerPort returns the number of the port the containers of the pods expose with the name and protocol.
+func containerPort(endpoint netpolEndpoint, name string, protocol apiv1.Protocol) (int32, bool) {
+	if endpoint.workload == nil {
+		return 0, false
+	}
+	for _, container := range endpoint.workload.podSpec.Containers {
+		for _, port := range container.Ports {
+			portProtocol := port.Protocol
+			if portProtocol == "" {
+				portProtocol = apiv1.ProtocolTCP
+			}
+			if port.Name == name && portProtocol == protocol {
+				return port.ContainerPort, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// resolvePort returns the port a connection to the destination is opened to, looking named ports up in its
+// containers.
+func resolvePort(destination netpolEndpoint, port, protocol string) (netpolPort, error) {
+	resolved := netpolPort{protocol: apiv1.Protocol(strings.ToUpper(protocol))}
+	if resolved.protocol == "" {
+		resolved.protocol = apiv1.ProtocolTCP
+	}
+	if number, err := strconv.ParseInt(port, 10, 32); err == nil {
+		resolved.number = int32(number)
+		return resolved, nil
+	}
+	number, ok := containerPort(destination, port, resolved.protocol)
+	if !ok {
+		return resolved, fmt.Errorf("%s doesn't expose a %s port named %q", destination, resolved.protocol, port)
+	}
+	resolved.number = number
+	return resolved, nil
+}
+
+// ipBlockContains returns true if the address is in the CIDR of the block but not in one of its exceptions.
+func ipBlockContains(block *networking.IPBlock, address net.IP) bool {
+	_, cidr, err := net.ParseCIDR(block.CIDR)
+	if err != nil || !cidr.Contains(address) {
+		return false
+	}
+	for _, except := range block.Except {
+		if _, exceptCIDR, err := net.ParseCIDR(except); err == nil && exceptCIDR.Contains(address) {
+			return false
+		}
+	}
+	return true
+}
+
+// peerMatches returns true if the peer of a policy of the namespace selects the endpoint. Pod IPs aren't known from
+// manifests so ipBlocks only select addresses, and selectors only pods.
+func (m *netpolModel) peerMatches(namespace string, peer networking.NetworkPolicyPeer, endpoint netpolEndpoint) bool {
+	if peer.IPBlock != nil {
+		return endpoint.workload == nil && ipBlockContains(peer.IPBlock, endpoint.address)
+	}
+	if endpoint.workload == nil {
+		return false
+	}
+	if peer.NamespaceSelector != nil {
+		if !labelSelectorMatches(peer.NamespaceSelector, m.namespaceLabels(endpoint.cluster, endpoint.namespace)) {
+			return false
+		}
+	} else if endpoint.namespace != namespace {
+		return false
+	}
+	return peer.PodSelector == nil || labelSelectorMatches(peer.PodSelector, endpoint.workload.labels)
+}
+
+// portMatches returns true if the port of a rule is the one of the connection. Named ports are looked up in the
+// containers of the destination.
+func portMatches(rulePort networking.NetworkPolicyPort, port netpolPort, destination netpolEndpoint) bool {
+	protocol := apiv1.ProtocolTCP
+	if rulePort.Protocol != nil {
+		protocol = *rulePort.Protocol
+	}
+	if protocol != port.protocol {
+		return false
+	}
+	if rulePort.Port == nil {
+		return true
+	}
+	if rulePort.Port.Type == intstr.Int {
+		return rulePort.Port.IntVal == port.number
+	}
+	number, ok := containerPort(destination, rulePort.Port.StrVal, protocol)
+	return ok && number == port.number
+}
+
+// ruleMatches returns true if a rule of a policy of the namespace allows the connection with the peer.
+func (m *netpolModel) ruleMatches(namespace string, rule policyRule, peer netpolEndpoint, port netpolPort, destination netpolEndpoint) bool {
+	peerMatched := len(rule.peers) == 0
+	for _, rulePeer := range rule.peers {
+		if m.peerMatches(namespace, rulePeer, peer) {
+			peerMatched = true
+			break
+		}
+	}
+	if !peerMatched {
+		return false
+	}
+	if len(rule.ports) == 0 {
+		return true
+	}
+	for _, rulePort := range rule.ports {
+		if portMatches(rulePort, port, destination) {
+			return true
+		}
+	}
+	return false
+}
+
+// decide evaluates the policies of the endpoint for a connection with the peer in the direction.
+func (m *netpolModel) decide(endpoint, peer netpolEndpoint, direction string, port netpolPort, destination netpolEndpoint) netpolDecision {
+	decision := netpolDecision{endpoint: endpoint, direction: direction}
+	if endpoint.workload == nil {
+		return decision
+	}
+	for _, netPol := range m.policies[workloadScope{cluster: endpoint.cluster, namespace: endpoint.namespace}] {
+		if !labelSelectorMatches(&netPol.Spec.PodSelector, endpoint.workload.labels) {
+			continue
+		}
+		ingress, egress := policyTypes(netPol)
+		if direction == "ingress" && !ingress || direction == "egress" && !egress {
+			continue
+		}
+		decision.isolating = append(decision.isolating, netPol.Name)
+		for _, rule := range policyRules(netPol) {
+			if rule.direction == direction && m.ruleMatches(endpoint.namespace, rule, peer, port, destination) {
+				decision.allowing = append(decision.allowing, netPol.Name)
+				break
+			}
+		}
+	}
+	return decision
+}
+
+// canReach evaluates the egress policies of the source and the ingress policies of the destination, which must both
+// allow the connection.
+func (m *netpolModel) canReach(from, to netpolEndpoint, port netpolPort) (egress, ingress netpolDecision) {
+	return m.decide(from, to, "egress", port, to), m.decide(to, from, "ingress", port, to)
+}
+
+// netpolResources reads the resources of the manifest or of the snapshot, keeping the clusters given with --context.
+func netpolResources() ([]Resource, error) {
+	var resources []Resource
+	var err error
+	switch {
+	case rootConfig.snapshot != "":
+		resources, err = loadSnapshot(rootConfig.snapshot)
+	case rootConfig.manifest != "":
+		resources, err = getKubeResourcesManifest(rootConfig.manifest)
+	default:
+		return nil, errors.New("kubeaudit netpol needs a manifest file, use -f, or a snapshot, use --snapshot")
+	}
+	if err != nil || len(rootConfig.kubeContexts) == 0 {
+		return resources, err
+	}
+	clusters := map[string]bool{}
+	for _, kubeContext := range rootConfig.kubeContexts {
+		clusters[kubeContext] = true
+	}
+	var kept []Resource
+	for _, resource := range resources {
+		if object, ok := resource.(interface{ GetClusterName() string }); ok && clusters[object.GetClusterName()] {
+			kept = append(kept, resource)
+		}
+	}
+	return kept, nil
+}
+
+func runCanReach(*cobra.Command, []string) {
+	setFormatter()
+	resources, err := netpolResources()
+	if err != nil {
+		log.Fatal(err)
+	}
+	model := newNetpolModel(resources)
+	from, err := model.endpoint(netpolConfig.from)
+	if err != nil {
+		log.Fatal(err)
+	}
+	to, err := model.endpoint(netpolConfig.to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if from.workload == nil && to.workload == nil {
+		log.Fatal("At least one end of the connection must be a workload")
+	}
+	if from.workload != nil && to.workload != nil && from.cluster != to.cluster {
+		log.Fatalf("%s and %s are in different clusters", from, to)
+	}
+	port, err := resolvePort(to, netpolConfig.port, netpolConfig.protocol)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	egress, ingress := model.canReach(from, to, port)
+	verdict := "allowed"
+	if !egress.allowed() || !ingress.allowed() {
+		verdict = "denied"
+	}
+	fmt.Printf("%s -> %s on %s: %s\n  %s\n  %s\n", from, to, port, verdict, egress, ingress)
+	if verdict != "allowed" {
+		os.Exit(ExitCodeUnreachable)
+	}
+}
+
+// reachability is a cell of the reachability matrix.
+type reachability struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Port     int32  `json:"port"`
+	Protocol string `json:"protocol"`
+	Allowed  bool   `json:"allowed"`
+}
+
+// matrixEndpoints returns the workloads of the namespaces given with --namespace, or of every namespace, grouped by
+// cluster along with the addresses given with --address.
+func (m *netpolModel) matrixEndpoints(addresses []net.IP) (clusters []string, endpoints map[string][]netpolEndpoint) {
+	endpoints = map[string][]netpolEndpoint{}
+	for _, endpoint := range m.workloads {
+		if len(rootConfig.namespaces) > 0 && !containsString(rootConfig.namespaces, endpoint.namespace) {
+			continue
+		}
+		if _, ok := endpoints[endpoint.cluster]; !ok {
+			clusters = append(clusters, endpoint.cluster)
+		}
+		endpoints[endpoint.cluster] = append(endpoints[endpoint.cluster], endpoint)
+	}
+	for _, cluster := range clusters {
+		for _, address := range addresses {
+			endpoints[cluster] = append(endpoints[cluster], netpolEndpoint{cluster: cluster, address: address})
+		}
+	}
+	return clusters, endpoints
+}
+
+// matrix computes whether every endpoint of a cluster can reach every other one. Connections between addresses and
+// to workloads without the named port are left out.
+func (m *netpolModel) matrix(endpoints []netpolEndpoint, port, protocol string) (cells []reachability) {
+	for _, from := range endpoints {
+		for _, to := range endpoints {
+			if from.workload == nil && to.workload == nil {
+				continue
+			}
+			resolved, err := resolvePort(to, port, protocol)
+			if err != nil {
+				continue
+			}
+			egress, ingress := m.canReach(from, to, resolved)
+			cells = append(cells, reachability{From: from.String(), To: to.String(), Port: resolved.number,
+				Protocol: string(resolved.protocol), Allowed: egress.allowed() && ingress.allowed()})
+		}
+	}
+	return cells
+}
+
+// writeMatrix writes a table with a row per source and a column per destination.
+func writeMatrix(w io.Writer, endpoints []netpolEndpoint, cells []reachability) error {
+	allowed := map[[2]string]string{}
+	for _, cell := range cells {
+		allowed[[2]string{cell.From, cell.To}] = "deny"
+		if cell.Allowed {
+			allowed[[2]string{cell.From, cell.To}] = "allow"
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "FROM \\ TO")
+	for _, to := range endpoints {
+		fmt.Fprintf(tw, "\t%s", to)
+	}
+	fmt.Fprintln(tw)
+	for _, from := range endpoints {
+		fmt.Fprint(tw, from)
+		for _, to := range endpoints {
+			cell, ok := allowed[[2]string{from.String(), to.String()}]
+			if !ok {
+				cell = "-"
+			}
+			fmt.Fprintf(tw, "\t%s", cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func runMatrix(*cobra.Command, []string) {
+	setFormatter()
+	if netpolConfig.format != "text" && netpolConfig.format != "json" {
+		log.Fatalf("Unsupported matrix format %q, use text or json", netpolConfig.format)
+	}
+	var addresses []net.IP
+	for _, address := range netpolConfig.addresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			log.Fatalf("Invalid address %q", address)
+		}
+		addresses = append(addresses, ip)
+	}
+	resources, err := netpolResources()
+	if err != nil {
+		log.Fatal(err)
+	}
+	model := newNetpolModel(resources)
+
+	clusters, endpoints := model.matrixEndpoints(addresses)
+	cells := []reachability{}
+	for _, cluster := range clusters {
+		clusterCells := model.matrix(endpoints[cluster], netpolConfig.port, netpolConfig.protocol)
+		if netpolConfig.format == "json" {
+			cells = append(cells, clusterCells...)
+			continue
+		}
+		if cluster != "" {
+			fmt.Printf("Cluster %s\n", cluster)
+		}
+		if err := writeMatrix(os.Stdout, endpoints[cluster], clusterCells); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if netpolConfig.format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(cells); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+var netpolCmd = &cobra.Command{
+	Use:   "netpol",
+	Short: "Simulate NetworkPolicies to find which workloads can reach each other",
+	Long: `These commands evaluate the NetworkPolicies of a manifest file or a snapshot
+the way Kubernetes does, without a cluster or a CNI, so policies can be tested
+in CI.
+
+A connection is allowed when the egress policies of its source and the ingress
+policies of its destination both allow it. Pods which no policy selects in a
+direction aren't isolated in that direction. Rules match on podSelector,
+namespaceSelector, ipBlock with its exceptions and on ports, named ports being
+looked up in the containers of the destination. Namespaces also match on the
+kubernetes.io/metadata.name label. Pod IPs aren't known offline, so ipBlocks
+only match the IP addresses given as endpoints.`,
+}
+
+var canReachCmd = &cobra.Command{
+	Use:   "can-reach",
+	Short: "Check whether a workload or an address can connect to another",
+	Long: `This command checks whether the NetworkPolicies allow a connection and
+explains which policies allow or deny it. Endpoints are given as
+namespace/workload, namespace/kind/workload or an IP address outside of the
+cluster. The port is a number or the name of a container port of the
+destination.
+
+The exit status is 0 when the connection is allowed and 3 when it is denied.
+
+Example usage:
+kubeaudit netpol can-reach -f /path/to/yaml --from shop/frontend --to shop/backend --port 443
+kubeaudit netpol can-reach --snapshot cluster.tar.gz --from shop/deployment/backend --to 10.0.0.1 --port 5432`,
+	Run: runCanReach,
+}
+
+var matrixCmd = &cobra.Command{
+	Use:   "matrix",
+	Short: "Print whether every workload can connect to every other one",
+	Long: `This command prints a table with a row per source and a column per
+destination saying whether the NetworkPolicies allow connections to the port.
+Connections to workloads without the named port are shown with a dash. The
+--namespace flag restricts the workloads and --address adds IP addresses
+outside of the cluster.
+
+Example usage:
+kubeaudit netpol matrix -f /path/to/yaml --port 443
+kubeaudit netpol matrix --snapshot cluster.tar.gz --port http --address 203.0.113.10 --format json`,
+	Run: runMatrix,
+}
+
+func init() {
+	RootCmd.AddCommand(netpolCmd)
+	netpolCmd.AddCommand(canReachCmd, matrixCmd)
+	netpolCmd.PersistentFlags().StringVar(&netpolConfig.port, "port", "", "Port number, or name of a container port of the destination")
+	netpolCmd.PersistentFlags().StringVar(&netpolConfig.protocol, "protocol", "TCP", "Protocol of the connection, TCP, UDP or SCTP")
+	canReachCmd.Flags().StringVar(&netpolConfig.from, "from", "", "Source of the connection, namespace/workload or an IP address")
+	canReachCmd.Flags().StringVar(&netpolConfig.to, "to", "", "Destination of the connection, namespace/workload or an IP address")
+	matrixCmd.Flags().StringSliceVar(&netpolConfig.addresses, "address", nil, "IP address outside of the cluster to add to the matrix, can be repeated")
+	matrixCmd.Flags().StringVar(&netpolConfig.format, "format", "text", "Matrix format, text or json")
+	netpolCmd.MarkPersistentFlagRequired("port")
+	canReachCmd.MarkFlagRequired("from")
+	canReachCmd.MarkFlagRequired("to")
+}
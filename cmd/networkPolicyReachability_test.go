@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reachabilityModel(t *testing.T) *netpolModel {
+	resources, err := getKubeResourcesManifest(filepath.Join(path, "netpol_reachability_v1.yml"))
+	require.Nil(t, err)
+	return newNetpolModel(resources)
+}
+
+func TestCanReach(t *testing.T) {
+	model := reachabilityModel(t)
+	for _, test := range []struct {
+		from, to, port  string
+		egress, ingress bool
+	}{
+		// Named ports are looked up in the containers of the destination
+		{"shop/frontend", "shop/backend", "https", true, true},
+		{"shop/frontend", "shop/backend", "8443", true, true},
+		{"shop/frontend", "shop/backend", "80", true, false},
+		{"shop/frontend", "shop/database", "5432", false, false},
+		{"shop/backend", "shop/database", "postgres", true, true},
+		// Pods selected by both a namespaceSelector and a podSelector, and namespaces selected by name
+		{"monitoring/prometheus", "shop/backend", "metrics", true, true},
+		{"monitoring/prometheus", "shop/database", "5432", true, true},
+		{"other/deployment/scraper", "shop/backend", "metrics", true, false},
+		// ipBlocks with exceptions only select addresses
+		{"shop/backend", "10.0.1.1", "443", true, true},
+		{"shop/backend", "10.0.5.7", "443", false, true},
+		{"shop/backend", "10.0.1.1", "80", false, true},
+		{"203.0.113.5", "shop/frontend", "http", true, true},
+		{"203.0.113.5", "shop/backend", "https", true, false},
+		// Pods which no policy isolates allow everything
+		{"monitoring/prometheus", "other/daemonSet/scraper", "9090", true, true},
+	} {
+		from, err := model.endpoint(test.from)
+		require.Nil(t, err, test.from)
+		to, err := model.endpoint(test.to)
+		require.Nil(t, err, test.to)
+		port, err := resolvePort(to, test.port, "tcp")
+		require.Nil(t, err, test.port)
+		egress, ingress := model.canReach(from, to, port)
+		assert.Equal(t, test.egress, egress.allowed(), "%s -> %s on %s: %s", test.from, test.to, test.port, egress)
+		assert.Equal(t, test.ingress, ingress.allowed(), "%s -> %s on %s: %s", test.from, test.to, test.port, ingress)
+	}
+}
+
+func TestCanReachExplanation(t *testing.T) {
+	model := reachabilityModel(t)
+	from, _ := model.endpoint("shop/frontend")
+	to, _ := model.endpoint("shop/backend")
+	egress, ingress := model.canReach(from, to, netpolPort{number: 80, protocol: "TCP"})
+	assert.Equal(t, "egress of shop/deployment/frontend: allowed by frontend", egress.String())
+	assert.Equal(t, "ingress of shop/deployment/backend: denied, isolated by default-deny, backend, metrics and no rule allows it",
+		ingress.String())
+}
+
+func TestNetpolEndpoint(t *testing.T) {
+	model := reachabilityModel(t)
+	endpoint, err := model.endpoint("other/daemonset/scraper")
+	assert.Nil(t, err)
+	assert.Equal(t, "other/daemonSet/scraper", endpoint.String())
+
+	_, err = model.endpoint("other/scraper")
+	assert.EqualError(t, err, "other/scraper is ambiguous, it could be other/daemonSet/scraper, other/deployment/scraper. "+
+		"Please give the kind of the workload, or the cluster with --context")
+	_, err = model.endpoint("shop/cache")
+	assert.EqualError(t, err, "no workload shop/cache")
+	_, err = model.endpoint("frontend")
+	assert.NotNil(t, err)
+
+	backend, _ := model.endpoint("shop/backend")
+	_, err = resolvePort(backend, "http", "TCP")
+	assert.EqualError(t, err, `shop/deployment/backend doesn't expose a TCP port named "http"`)
+	_, err = resolvePort(backend, "https", "UDP")
+	assert.NotNil(t, err)
+}
+
+func TestReachabilityMatrix(t *testing.T) {
+	model := reachabilityModel(t)
+	var endpoints []netpolEndpoint
+	for _, ref := range []string{"shop/frontend", "shop/backend", "203.0.113.5"} {
+		endpoint, err := model.endpoint(ref)
+		require.Nil(t, err)
+		endpoints = append(endpoints, endpoint)
+	}
+	cells := model.matrix(endpoints, "https", "TCP")
+	// Only the backend exposes https
+	assert.Equal(t, []reachability{
+		{From: "shop/deployment/frontend", To: "shop/deployment/backend", Port: 8443, Protocol: "TCP", Allowed: true},
+		{From: "shop/deployment/backend", To: "shop/deployment/backend", Port: 8443, Protocol: "TCP", Allowed: false},
+		{From: "203.0.113.5", To: "shop/deployment/backend", Port: 8443, Protocol: "TCP", Allowed: false},
+	}, cells)
+
+	var out bytes.Buffer
+	assert.Nil(t, writeMatrix(&out, endpoints, cells))
+	assert.Equal(t, "FROM \\ TO                 shop/deployment/frontend  shop/deployment/backend  203.0.113.5\n"+
+		"shop/deployment/frontend  -                         allow                    -\n"+
+		"shop/deployment/backend   -                         deny                     -\n"+
+		"203.0.113.5               -                         deny                     -\n", out.String())
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Shopify/kubeaudit/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ownerKey identifies an object by cluster, namespace, kind and name, which is all an ownerReference of a namespaced
+// object carries besides the uid. The uid isn't used because manifests usually don't have one.
+type ownerKey struct {
+	cluster   string
+	namespace string
+	kind      string
+	name      string
+}
+
+func (key ownerKey) String() string {
+	return key.kind + "/" + key.name
+}
+
+// resultKey identifies the Result of an audited resource.
+type resultKey struct {
+	cluster   string
+	namespace string
+	kubeType  string
+	name      string
+}
+
+// Ownership rolls Pods up to the top-level controller which created them (Pod -> ReplicaSet -> Deployment,
+// Pod -> Job -> CronJob, ...) so that a finding on a Deployment isn't repeated for each of its replicas. It remembers
+// which Pods were rolled up into which controller and the nodes they run on, and which Pods are owned by a controller
+// kubeaudit doesn't audit. It is safe for concurrent use.
+type Ownership struct {
+	mu        sync.Mutex
+	instances map[resultKey][]string
+	nodes     map[resultKey][]string
+	owners    map[resultKey]string
+}
+
+var scanOwnership Ownership
+
+func (o *Ownership) reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.instances = nil
+	o.nodes = nil
+	o.owners = nil
+}
+
+// kindOf returns the kind of the resource. Objects returned by List calls don't have their TypeMeta set so the kind is
+// looked up in the scheme instead.
+func kindOf(resource Resource) string {
+	if kind := resource.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return kind
+	}
+	if kinds, _, err := scheme.Scheme.ObjectKinds(resource); err == nil && len(kinds) > 0 {
+		return kinds[0].Kind
+	}
+	return ""
+}
+
+func keyOf(resource Resource) (ownerKey, metav1.Object, bool) {
+	meta, ok := resource.(metav1.Object)
+	if !ok {
+		return ownerKey{}, nil, false
+	}
+	return ownerKey{cluster: meta.GetClusterName(), namespace: meta.GetNamespace(), kind: kindOf(resource), name: meta.GetName()}, meta, true
+}
+
+// rollUp removes every Pod whose top-level controller is among the resources and returns the remaining resources.
+// owners holds objects which are only needed to follow ownerReferences, such as ReplicaSets and Jobs.
+func (o *Ownership) rollUp(resources []Resource, owners []Resource) []Resource {
+	controllerOf := map[ownerKey]ownerKey{}
+	for _, resource := range append(append([]Resource{}, owners...), resources...) {
+		key, meta, ok := keyOf(resource)
+		if !ok {
+			continue
+		}
+		if ref := metav1.GetControllerOf(meta); ref != nil {
+			controllerOf[key] = ownerKey{cluster: key.cluster, namespace: key.namespace, kind: ref.Kind, name: ref.Name}
+		}
+	}
+
+	audited := map[ownerKey]resultKey{}
+	for _, resource := range resources {
+		if _, isPod := resource.(*PodV1); isPod || !IsSupportedResourceType(resource) {
+			continue
+		}
+		key, _, ok := keyOf(resource)
+		if !ok {
+			continue
+		}
+		if result, err, warn := newResultFromResource(resource); err == nil && warn == nil {
+			audited[key] = resultKey{cluster: result.Cluster, namespace: result.Namespace, kubeType: result.KubeType, name: result.Name}
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.instances == nil {
+		o.instances = map[resultKey][]string{}
+		o.nodes = map[resultKey][]string{}
+		o.owners = map[resultKey]string{}
+	}
+
+	var remaining []Resource
+	for _, resource := range resources {
+		pod, isPod := resource.(*PodV1)
+		if !isPod {
+			remaining = append(remaining, resource)
+			continue
+		}
+		podKey, _, _ := keyOf(pod)
+		top, owned := controllerOf[podKey]
+		if !owned {
+			// A bare pod
+			remaining = append(remaining, resource)
+			continue
+		}
+		// Follow the chain of controllers, guarding against cycles in hand written manifests
+		for seen := map[ownerKey]bool{podKey: true}; !seen[top]; {
+			seen[top] = true
+			parent, ok := controllerOf[top]
+			if !ok {
+				break
+			}
+			top = parent
+		}
+		if controller, ok := audited[top]; ok {
+			o.instances[controller] = append(o.instances[controller], pod.Name)
+			if pod.Spec.NodeName != "" && !containsString(o.nodes[controller], pod.Spec.NodeName) {
+				o.nodes[controller] = append(o.nodes[controller], pod.Spec.NodeName)
+			}
+			continue
+		}
+		o.owners[resultKey{cluster: pod.ClusterName, namespace: pod.Namespace, kubeType: "pod", name: pod.Name}] = top.String()
+		remaining = append(remaining, resource)
+	}
+	return remaining
+}
+
+// nodeNames returns the nodes running the Pods which were rolled up into the controller.
+func (o *Ownership) nodeNames(resource Resource) []string {
+	result, err, warn := newResultFromResource(resource)
+	if err != nil || warn != nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	nodes := append([]string{}, o.nodes[resultKey{cluster: result.Cluster, namespace: result.Namespace, kubeType: result.KubeType, name: result.Name}]...)
+	sort.Strings(nodes)
+	return nodes
+}
+
+// annotate sets the owner of Pods which couldn't be rolled up, and with --show-pods the Pods which were rolled up
+// into each controller.
+func (o *Ownership) annotate(results []Result) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := range results {
+		key := resultKey{cluster: results[i].Cluster, namespace: results[i].Namespace, kubeType: results[i].KubeType, name: results[i].Name}
+		results[i].Owner = o.owners[key]
+		if rootConfig.showPods {
+			pods := append([]string{}, o.instances[key]...)
+			sort.Strings(pods)
+			results[i].Pods = pods
+		}
+	}
+}
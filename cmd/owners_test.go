@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rollUpFixture(t *testing.T) []Resource {
+	resources, err := getKubeResourcesManifest("../fixtures/owner_references_v1.yml")
+	assert.Nil(t, err)
+	scanOwnership.reset()
+	return scanOwnership.rollUp(resources, resources)
+}
+
+func TestRollUpPodsToControllers(t *testing.T) {
+	var pods []string
+	for _, resource := range rollUpFixture(t) {
+		if pod, ok := resource.(*PodV1); ok {
+			pods = append(pods, pod.Name)
+		}
+	}
+	// Pods of the Deployment and the CronJob are rolled up, the bare pod and the pod of the bare ReplicaSet are not
+	assert.Equal(t, []string{"orphan-7c9d2-m4n5b", "debug"}, pods)
+}
+
+func TestAnnotateOwnership(t *testing.T) {
+	defer func(showPods bool) { rootConfig.showPods = showPods }(rootConfig.showPods)
+	rootConfig.showPods = true
+
+	results := getResults(rollUpFixture(t), auditPrivileged)
+	scanOwnership.annotate(results)
+
+	byName := map[string]Result{}
+	for _, result := range results {
+		byName[result.Name] = result
+	}
+	assert.Equal(t, []string{"web-5d4f8-a7b3c", "web-5d4f8-x2k9p"}, byName["web"].Pods)
+	assert.Equal(t, []string{"backup-1561035600-q8w2e"}, byName["backup"].Pods)
+	assert.Equal(t, "ReplicaSet/orphan-7c9d2", byName["orphan-7c9d2-m4n5b"].Owner)
+	assert.Equal(t, "", byName["debug"].Owner)
+	assert.Empty(t, byName["debug"].Pods)
+
+	fields := createFields(byName["web"], byName["web"].Occurrences[0])
+	assert.Equal(t, "web-5d4f8-a7b3c,web-5d4f8-x2k9p", fields["Pods"])
+}
+
+func TestKindOf(t *testing.T) {
+	assert.Equal(t, "Deployment", kindOf(&DeploymentV1{}))
+	assert.Equal(t, "ReplicaSet", kindOf(&ReplicaSetV1{}))
+	assert.Equal(t, "Pod", kindOf(NewPod()))
+}
@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// rbacKey identifies a service account, role or binding subject by cluster, kind, namespace and name. Cluster scoped
+// objects, users and groups have an empty namespace.
+type rbacKey struct {
+	cluster   string
+	kind      string
+	namespace string
+	name      string
+}
+
+// rbacGrant is a role granted to a subject by a binding. A RoleBinding only grants the role in its own namespace while
+// a ClusterRoleBinding grants it in every namespace.
+type rbacGrant struct {
+	binding   string
+	roleRef   RoleRefV1
+	namespace string
+	cluster   bool
+}
+
+// ServiceAccountPrivileges resolves the permissions granted to service accounts by RoleBindings and
+// ClusterRoleBindings. It indexes every RBAC resource which could be read regardless of the filters, so a workload's
+// permissions aren't missed because the role granting them isn't audited. It is safe for concurrent use.
+type ServiceAccountPrivileges struct {
+	mu              sync.Mutex
+	serviceAccounts map[rbacKey]*ServiceAccountV1
+	roles           map[rbacKey][]PolicyRuleV1
+	grants          map[rbacKey][]rbacGrant
+}
+
+var scanPrivileges ServiceAccountPrivileges
+
+// clusterAdminRules are the rules of the cluster-admin ClusterRole, which is used when the role itself isn't part of
+// the audited manifests.
+var clusterAdminRules = []PolicyRuleV1{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}}
+
+func (p *ServiceAccountPrivileges) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.serviceAccounts = nil
+	p.roles = nil
+	p.grants = nil
+}
+
+func (p *ServiceAccountPrivileges) index(resources []Resource) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.grants == nil {
+		p.serviceAccounts = map[rbacKey]*ServiceAccountV1{}
+		p.roles = map[rbacKey][]PolicyRuleV1{}
+		p.grants = map[rbacKey][]rbacGrant{}
+	}
+	for _, resource := range resources {
+		switch kubeType := resource.(type) {
+		case *ServiceAccountV1:
+			key := rbacKey{cluster: kubeType.GetClusterName(), kind: "ServiceAccount", namespace: kubeType.Namespace, name: kubeType.Name}
+			p.serviceAccounts[key] = kubeType
+		case *RoleV1:
+			key := rbacKey{cluster: kubeType.GetClusterName(), kind: "Role", namespace: kubeType.Namespace, name: kubeType.Name}
+			p.roles[key] = kubeType.Rules
+		case *ClusterRoleV1:
+			key := rbacKey{cluster: kubeType.GetClusterName(), kind: "ClusterRole", name: kubeType.Name}
+			p.roles[key] = kubeType.Rules
+		case *RoleBindingV1:
+			grant := rbacGrant{binding: "RoleBinding/" + kubeType.Name, roleRef: kubeType.RoleRef, namespace: kubeType.Namespace}
+			p.addGrant(kubeType.GetClusterName(), kubeType.Subjects, grant)
+		case *ClusterRoleBindingV1:
+			grant := rbacGrant{binding: "ClusterRoleBinding/" + kubeType.Name, roleRef: kubeType.RoleRef, cluster: true}
+			p.addGrant(kubeType.GetClusterName(), kubeType.Subjects, grant)
+		}
+	}
+}
+
+func (p *ServiceAccountPrivileges) addGrant(cluster string, subjects []SubjectV1, grant rbacGrant) {
+	for _, subject := range subjects {
+		key := rbacKey{cluster: cluster, kind: subject.Kind, name: subject.Name}
+		if subject.Kind == "ServiceAccount" {
+			key.namespace = subject.Namespace
+			if key.namespace == "" {
+				key.namespace = grant.namespace
+			}
+		}
+		p.grants[key] = append(p.grants[key], grant)
+	}
+}
+
+// mountsToken returns true if a pod running as the service account gets its token mounted. The pod's own
+// automountServiceAccountToken takes precedence over the service account's, and tokens are mounted by default.
+func (p *ServiceAccountPrivileges) mountsToken(cluster, namespace, serviceAccount string, podToken *bool) bool {
+	if podToken != nil {
+		return *podToken
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := rbacKey{cluster: cluster, kind: "ServiceAccount", namespace: namespace, name: serviceAccount}
+	if sa := p.serviceAccounts[key]; sa != nil && sa.AutomountServiceAccountToken != nil {
+		return *sa.AutomountServiceAccountToken
+	}
+	return true
+}
+
+// privilegeFinding lists the dangerous permissions a single binding grants to a service account.
+type privilegeFinding struct {
+	grant       rbacGrant
+	permissions []string
+	// secretsReadOnly is set when the only dangerous permission is reading the secrets of a namespace
+	secretsReadOnly bool
+}
+
+// dangerousGrants returns the bindings granting the service account dangerous permissions, either directly or through
+// the groups every service account token belongs to.
+func (p *ServiceAccountPrivileges) dangerousGrants(cluster, namespace, serviceAccount string) (findings []privilegeFinding) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	subjects := []rbacKey{
+		{cluster: cluster, kind: "ServiceAccount", namespace: namespace, name: serviceAccount},
+		{cluster: cluster, kind: "Group", name: "system:serviceaccounts"},
+		{cluster: cluster, kind: "Group", name: "system:serviceaccounts:" + namespace},
+		{cluster: cluster, kind: "Group", name: "system:authenticated"},
+	}
+	for _, subject := range subjects {
+		for _, grant := range p.grants[subject] {
+			checks := matchingRuleChecks(p.rulesOf(cluster, grant))
+			if len(checks) == 0 {
+				continue
+			}
+			finding := privilegeFinding{grant: grant}
+			for _, check := range checks {
+				finding.permissions = append(finding.permissions, check.permission)
+			}
+			finding.secretsReadOnly = !grant.cluster && len(checks) == 1 && checks[0].id == ErrorRBACSecretsRead
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// rules returns the rules of the role granted by the binding, or nil if the role is unknown.
+func (p *ServiceAccountPrivileges) rules(cluster string, grant rbacGrant) []PolicyRuleV1 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rulesOf(cluster, grant)
+}
+
+func (p *ServiceAccountPrivileges) rulesOf(cluster string, grant rbacGrant) []PolicyRuleV1 {
+	key := rbacKey{cluster: cluster, kind: grant.roleRef.Kind, name: grant.roleRef.Name}
+	if grant.roleRef.Kind == "Role" {
+		key.namespace = grant.namespace
+	}
+	rules, ok := p.roles[key]
+	if !ok && grant.roleRef.Kind == "ClusterRole" && grant.roleRef.Name == "cluster-admin" {
+		return clusterAdminRules
+	}
+	if !ok {
+		log.Debugf("Unable to resolve %s/%s bound by %s", grant.roleRef.Kind, grant.roleRef.Name, grant.binding)
+	}
+	return rules
+}
+
+func serviceAccountName(result *Result) string {
+	if result.SA != "" {
+		return result.SA
+	}
+	if result.DSA != "" {
+		return result.DSA
+	}
+	return "default"
+}
+
+func grantMetadata(serviceAccount string, finding privilegeFinding) Metadata {
+	scope := "namespace"
+	if finding.grant.cluster {
+		scope = "cluster"
+	}
+	return Metadata{
+		"ServiceAccount": serviceAccount,
+		"Binding":        finding.grant.binding,
+		"RoleRef":        finding.grant.roleRef.Kind + "/" + finding.grant.roleRef.Name,
+		"Scope":          scope,
+		"Permissions":    strings.Join(finding.permissions, "; "),
+	}
+}
+
+func checkServiceAccountPrivileges(result *Result) {
+	serviceAccount := serviceAccountName(result)
+	var findings []privilegeFinding
+	if scanPrivileges.mountsToken(result.Cluster, result.Namespace, serviceAccount, result.Token) {
+		findings = scanPrivileges.dangerousGrants(result.Cluster, result.Namespace, serviceAccount)
+	}
+
+	if labelExists, reason := getPodOverrideLabelReason(result, "allow-privileged-service-account-token"); labelExists {
+		if len(findings) == 0 {
+			occ := Occurrence{
+				id:       ErrorMisconfiguredKubeauditAllow,
+				kind:     Warn,
+				message:  "Allowed mounting a privileged service account token, but the mounted token has no dangerous permissions",
+				metadata: Metadata{"ServiceAccount": serviceAccount, "Reason": prettifyReason(reason)},
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+		for _, finding := range findings {
+			metadata := grantMetadata(serviceAccount, finding)
+			metadata["Reason"] = prettifyReason(reason)
+			occ := Occurrence{
+				id:       ErrorServiceAccountTokenPrivilegedAllowed,
+				kind:     Warn,
+				message:  "Allowed mounting a service account token with dangerous permissions",
+				metadata: metadata,
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+		return
+	}
+
+	for _, finding := range findings {
+		kind := Error
+		if finding.secretsReadOnly {
+			kind = Warn
+		}
+		occ := Occurrence{
+			id:       ErrorServiceAccountTokenPrivileged,
+			kind:     kind,
+			message:  "Service account token mounted in the pod grants dangerous permissions, set automountServiceAccountToken to false or reduce the permissions",
+			metadata: grantMetadata(serviceAccount, finding),
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+}
+
+func auditServiceAccountPrivileges(resource Resource) (results []Result) {
+	if !IsSupportedResourceType(resource) || IsNamespaceType(resource) {
+		return
+	}
+	result, err, warn := newResultFromResourceWithServiceAccountInfo(resource)
+	if warn != nil {
+		log.Warn(warn)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	checkServiceAccountPrivileges(result)
+	if len(result.Occurrences) > 0 {
+		results = append(results, *result)
+	}
+	return
+}
+
+var saPrivilegesCmd = &cobra.Command{
+	Use:   "sa-privileges",
+	Short: "Audit pods which mount the token of a service account with dangerous permissions",
+	Long: `This command resolves the service account of every pod, follows the
+RoleBindings and ClusterRoleBindings granting it roles, and reports the pods
+which mount a token with dangerous permissions. Bindings to the
+system:serviceaccounts and system:authenticated groups are taken into account.
+
+A permission is dangerous when it grants:
+- every verb or every resource with a wildcard
+- the escalate, bind or impersonate verbs
+- read access to secrets (a warning when limited to a namespace)
+- executing commands in pods
+- creating pods, directly or through a workload controller
+
+A token is mounted unless automountServiceAccountToken is false in the pod
+spec or, when the pod doesn't set it, in the service account.
+
+Example usage:
+kubeaudit sa-privileges`,
+	PreRun: func(*cobra.Command, []string) { auditsRBAC = true },
+	Run:    runAudit(auditServiceAccountPrivileges),
+}
+
+func init() {
+	RootCmd.AddCommand(saPrivilegesCmd)
+}
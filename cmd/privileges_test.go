@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceAccountPrivilegesV1(t *testing.T) {
+	results := runAuditTest(t, "sa_privileges_v1.yml", auditServiceAccountPrivileges, []int{ErrorServiceAccountTokenPrivileged})
+	// The reporter's service account doesn't automount its token and the web Deployment's default service account
+	// isn't bound to anything
+	assert.Len(t, results, 1)
+	assert.Equal(t, "builder", results[0].Name)
+	metadata := results[0].Occurrences[0].metadata
+	assert.Equal(t, "RoleBinding/builder-pod-creator", metadata["Binding"])
+	assert.Equal(t, "create pods", metadata["Permissions"])
+	assert.Equal(t, "namespace", metadata["Scope"])
+}
+
+func TestServiceAccountPrivilegesClusterAdminV1(t *testing.T) {
+	results := runAuditTest(t, "sa_privileges_admin_v1.yml", auditServiceAccountPrivileges, []int{ErrorServiceAccountTokenPrivileged})
+	occ := results[0].Occurrences[0]
+	assert.Equal(t, Error, occ.kind)
+	assert.Equal(t, "cluster", occ.metadata["Scope"])
+	assert.Equal(t, "ClusterRole/cluster-admin", occ.metadata["RoleRef"])
+	assert.Contains(t, occ.metadata["Permissions"], "read secrets")
+}
+
+func TestServiceAccountPrivilegesGroupSecretsV1(t *testing.T) {
+	results := runAuditTest(t, "sa_privileges_secrets_v1.yml", auditServiceAccountPrivileges, []int{ErrorServiceAccountTokenPrivileged})
+	assert.Equal(t, Warn, results[0].Occurrences[0].kind)
+	assert.Equal(t, "default", results[0].Occurrences[0].metadata["ServiceAccount"])
+}
+
+func TestServiceAccountPrivilegesAllowedV1(t *testing.T) {
+	runAuditTest(t, "sa_privileges_allowed_v1.yml", auditServiceAccountPrivileges, []int{ErrorServiceAccountTokenPrivilegedAllowed, ErrorMisconfiguredKubeauditAllow})
+}
+
+func TestServiceAccountPrivilegesTokenMounted(t *testing.T) {
+	scanPrivileges.reset()
+	defer scanPrivileges.reset()
+	scanPrivileges.index([]Resource{
+		&ServiceAccountV1{ObjectMeta: ObjectMetaV1{Name: "sa", Namespace: "ns"}, AutomountServiceAccountToken: newFalse()},
+	})
+	assert.False(t, scanPrivileges.mountsToken("", "ns", "sa", nil))
+	assert.True(t, scanPrivileges.mountsToken("", "ns", "sa", newTrue()))
+	assert.True(t, scanPrivileges.mountsToken("", "ns", "other", nil))
+	assert.True(t, scanPrivileges.mountsToken("", "other", "sa", nil))
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Defaults the API server sets for probe fields left out of a manifest.
+const (
+	defaultProbePeriodSeconds    = 10
+	defaultProbeFailureThreshold = 3
+)
+
+// probeShells are the shells exec probes shell out to.
+var probeShells = map[string]bool{"sh": true, "bash": true, "ash": true, "dash": true, "zsh": true, "ksh": true}
+
+// isLongRunning returns true for the workloads which keep their pods running, as opposed to jobs and bare pods.
+func isLongRunning(resource Resource) bool {
+	switch resource.(type) {
+	case *DeploymentExtensionsV1Beta1, *DeploymentV1, *DeploymentV1Beta1, *DeploymentV1Beta2,
+		*StatefulSetV1, *StatefulSetV1Beta1,
+		*DaemonSetV1, *DaemonSetV1Beta1, *DaemonSetV1Beta2:
+		return true
+	}
+	return false
+}
+
+// probeEndpoint returns what the probe checks, such as http://:8080/healthz, with named ports resolved so that probes
+// of the same endpoint compare equal.
+func probeEndpoint(probe *ProbeV1, container ContainerV1) string {
+	resolvePort := func(port string) string {
+		for _, containerPort := range container.Ports {
+			if containerPort.Name == port {
+				return strconv.Itoa(int(containerPort.ContainerPort))
+			}
+		}
+		return port
+	}
+	switch {
+	case probe.HTTPGet != nil:
+		scheme := strings.ToLower(string(probe.HTTPGet.Scheme))
+		if scheme == "" {
+			scheme = "http"
+		}
+		path := probe.HTTPGet.Path
+		if path == "" {
+			path = "/"
+		}
+		return fmt.Sprintf("%s://%s:%s%s", scheme, probe.HTTPGet.Host, resolvePort(probe.HTTPGet.Port.String()), path)
+	case probe.TCPSocket != nil:
+		return fmt.Sprintf("tcp://%s:%s", probe.TCPSocket.Host, resolvePort(probe.TCPSocket.Port.String()))
+	case probe.Exec != nil:
+		return "exec:" + strings.Join(probe.Exec.Command, " ")
+	}
+	return ""
+}
+
+// probeFailureWindow returns how many seconds of failures it takes for the probe to fail.
+func probeFailureWindow(probe *ProbeV1) int32 {
+	period, threshold := probe.PeriodSeconds, probe.FailureThreshold
+	if period == 0 {
+		period = defaultProbePeriodSeconds
+	}
+	if threshold == 0 {
+		threshold = defaultProbeFailureThreshold
+	}
+	return period * threshold
+}
+
+// probeShellsOut returns true if the probe runs its command through a shell, such as sh -c "curl localhost".
+func probeShellsOut(probe *ProbeV1) bool {
+	if probe == nil || probe.Exec == nil || len(probe.Exec.Command) == 0 {
+		return false
+	}
+	command := probe.Exec.Command
+	if filepath.Base(command[0]) == "env" || filepath.Base(command[0]) == "busybox" {
+		command = command[1:]
+	}
+	return len(command) > 0 && probeShells[filepath.Base(command[0])]
+}
+
+func containerProbeOccurrences(container ContainerV1) (occurrences []Occurrence) {
+	if container.ReadinessProbe == nil {
+		occurrences = append(occurrences, Occurrence{
+			id:      ErrorProbeReadinessNil,
+			kind:    Warn,
+			message: "Readiness probe not set, the container gets traffic before it's ready. Please set it!",
+		})
+	}
+	if container.LivenessProbe == nil {
+		occurrences = append(occurrences, Occurrence{
+			id:      ErrorProbeLivenessNil,
+			kind:    Warn,
+			message: "Liveness probe not set, the container isn't restarted when it hangs. Please set it!",
+		})
+	}
+
+	// A liveness probe of the readiness endpoint which fails as fast restarts containers which are only busy
+	if container.ReadinessProbe != nil && container.LivenessProbe != nil {
+		endpoint := probeEndpoint(container.LivenessProbe, container)
+		livenessWindow := probeFailureWindow(container.LivenessProbe)
+		readinessWindow := probeFailureWindow(container.ReadinessProbe)
+		if endpoint != "" && endpoint == probeEndpoint(container.ReadinessProbe, container) && livenessWindow <= readinessWindow {
+			occurrences = append(occurrences, Occurrence{
+				id:      ErrorProbeSameEndpoint,
+				kind:    Warn,
+				message: "Liveness probe hits the same endpoint as the readiness probe and fails as fast, a busy container gets restarted instead of taken out of rotation. Please make the liveness probe more tolerant",
+				metadata: Metadata{
+					"Endpoint":        endpoint,
+					"LivenessWindow":  fmt.Sprintf("%ds", livenessWindow),
+					"ReadinessWindow": fmt.Sprintf("%ds", readinessWindow),
+				},
+			})
+		}
+	}
+
+	for _, probe := range []struct {
+		name  string
+		probe *ProbeV1
+	}{{"readiness", container.ReadinessProbe}, {"liveness", container.LivenessProbe}} {
+		if probeShellsOut(probe.probe) {
+			occurrences = append(occurrences, Occurrence{
+				id:       ErrorProbeExecShell,
+				kind:     Warn,
+				message:  fmt.Sprintf("The %s probe shells out, which forks a shell every period and fails in images without one. Please run the command directly or use an httpGet or tcpSocket probe", probe.name),
+				metadata: Metadata{"Probe": probe.name, "Command": strings.Join(probe.probe.Exec.Command, " ")},
+			})
+		}
+	}
+	return occurrences
+}
+
+// allowProbes turns the occurrences into allowed warnings if the override label is set.
+func allowProbes(occurrences []Occurrence, labelExists bool, reason string) []Occurrence {
+	if !labelExists {
+		return occurrences
+	}
+	if len(occurrences) == 0 {
+		return []Occurrence{{
+			id:       ErrorMisconfiguredKubeauditAllow,
+			kind:     Warn,
+			message:  "Allowed probes which don't follow the policy, but they all follow it",
+			metadata: Metadata{"Reason": prettifyReason(reason)},
+		}}
+	}
+	var allowed []Occurrence
+	for _, occ := range occurrences {
+		if occ.metadata == nil {
+			occ.metadata = Metadata{}
+		}
+		occ.metadata["Reason"] = prettifyReason(reason)
+		allowed = append(allowed, Occurrence{
+			id:       ErrorProbesAllowed,
+			kind:     Warn,
+			message:  "Allowed probes which don't follow the policy: " + occ.message,
+			metadata: occ.metadata,
+		})
+	}
+	return allowed
+}
+
+func checkProbes(container ContainerV1, result *Result) {
+	labelExists, reason := getContainerOverrideLabelReason(result, container, "allow-probes")
+	for _, occ := range allowProbes(containerProbeOccurrences(container), labelExists, reason) {
+		occ.container = container.Name
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+}
+
+func auditProbes(resource Resource) (results []Result) {
+	if !isLongRunning(resource) {
+		return
+	}
+	for _, container := range getContainers(resource) {
+		result, err, warn := newResultFromResource(resource)
+		if warn != nil {
+			log.Warn(warn)
+			return
+		}
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		checkProbes(container, result)
+		if len(result.Occurrences) > 0 {
+			results = append(results, *result)
+		}
+	}
+	return
+}
+
+var probesCmd = &cobra.Command{
+	Use:   "probes",
+	Short: "Audit the liveness and readiness probes of long-running workloads",
+	Long: `This command checks the probes of the containers of Deployments,
+StatefulSets and DaemonSets.
+
+A WARN is generated when a container has no readiness or no liveness probe,
+when its liveness probe hits the same endpoint as its readiness probe and fails
+as fast, which restarts containers which are only busy, and when an exec probe
+shells out.
+
+Example usage:
+kubeaudit probes`,
+	Run: runAudit(auditProbes),
+}
+
+func init() {
+	RootCmd.AddCommand(probesCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbesV1(t *testing.T) {
+	results := runAuditTest(t, "probes_v1.yml", auditProbes, []int{ErrorProbeReadinessNil, ErrorProbeLivenessNil, ErrorProbeSameEndpoint,
+		ErrorProbeExecShell, ErrorProbesAllowed, ErrorMisconfiguredKubeauditAllow})
+	occurrences := occurrenceIDs(indexOccurrences(results, byContainer))
+	assert.Equal(t, []int{ErrorProbeReadinessNil, ErrorProbeLivenessNil}, occurrences["no-probes"])
+	assert.Equal(t, []int{ErrorProbeLivenessNil}, occurrences["no-liveness"])
+	// The named port of the liveness probe is the port of the readiness probe
+	assert.Equal(t, []int{ErrorProbeSameEndpoint}, occurrences["same-endpoint"])
+	assert.NotContains(t, occurrences, "tolerant")
+	assert.Equal(t, []int{ErrorProbeExecShell}, occurrences["shell"])
+	assert.Equal(t, []int{ErrorProbesAllowed, ErrorProbesAllowed}, occurrences["batch"])
+	assert.Equal(t, []int{ErrorMisconfiguredKubeauditAllow}, occurrences["probed"])
+	assert.NotContains(t, occurrences, "job")
+
+	for _, result := range results {
+		for _, occ := range result.Occurrences {
+			switch occ.id {
+			case ErrorProbeSameEndpoint:
+				assert.Equal(t, "http://:8080/healthz", occ.metadata["Endpoint"])
+				assert.Equal(t, "30s", occ.metadata["LivenessWindow"])
+				assert.Equal(t, "30s", occ.metadata["ReadinessWindow"])
+			case ErrorProbesAllowed:
+				assert.Equal(t, "Consumes a queue, nothing to probe", occ.metadata["Reason"])
+			}
+		}
+	}
+}
+
+func TestProbeShellsOut(t *testing.T) {
+	for command, expected := range map[string]bool{
+		"sh -c true":                 true,
+		"/bin/bash -c curl":          true,
+		"/usr/bin/env sh -c true":    true,
+		"busybox sh -c wget":         true,
+		"/app/healthcheck --timeout": false,
+		"grpc_health_probe -addr=:1": false,
+		"busybox wget localhost":     false,
+	} {
+		probe := &ProbeV1{}
+		probe.Exec = &ExecActionV1{Command: strings.Fields(command)}
+		assert.Equal(t, expected, probeShellsOut(probe), command)
+	}
+	assert.False(t, probeShellsOut(nil))
+	assert.False(t, probeShellsOut(&ProbeV1{}))
+}
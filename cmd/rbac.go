@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// auditsRBAC is set by the commands which audit RBAC so that Roles, ClusterRoles and their bindings are only read
+// from the cluster when needed. The other audits then don't require permission to list them.
+var auditsRBAC bool
+
+// podCreatingResources are the resources which create pods, with the API groups they're served from.
+var podCreatingResources = map[string][]string{
+	"pods":                   {""},
+	"replicationcontrollers": {""},
+	"deployments":            {"apps", "extensions"},
+	"daemonsets":             {"apps", "extensions"},
+	"replicasets":            {"apps", "extensions"},
+	"statefulsets":           {"apps"},
+	"jobs":                   {"batch"},
+	"cronjobs":               {"batch"},
+}
+
+// rbacRuleCheck is a check on the rules of a Role or ClusterRole. match returns the first rule which fails the check.
+type rbacRuleCheck struct {
+	overrideLabel string
+	id            int
+	allowedID     int
+	message       string
+	allowed       string
+	// permission describes what the rule grants, for reporting the permissions of service accounts
+	permission string
+	match      func(rule PolicyRuleV1) bool
+}
+
+var rbacRuleChecks = []rbacRuleCheck{
+	{
+		overrideLabel: "allow-wildcard-verbs",
+		id:            ErrorRBACWildcardVerbs,
+		allowedID:     ErrorRBACWildcardVerbsAllowed,
+		message:       "Role grants every verb with a wildcard, list the verbs it needs instead",
+		allowed:       "Allowed granting every verb with a wildcard",
+		permission:    "every verb",
+		match:         func(rule PolicyRuleV1) bool { return containsString(rule.Verbs, "*") },
+	},
+	{
+		overrideLabel: "allow-wildcard-resources",
+		id:            ErrorRBACWildcardResources,
+		allowedID:     ErrorRBACWildcardResourcesAllowed,
+		message:       "Role grants access to every resource with a wildcard, list the resources it needs instead",
+		allowed:       "Allowed granting access to every resource with a wildcard",
+		permission:    "every resource",
+		match:         func(rule PolicyRuleV1) bool { return containsString(rule.Resources, "*") },
+	},
+	{
+		overrideLabel: "allow-escalation-verbs",
+		id:            ErrorRBACEscalationVerbs,
+		allowedID:     ErrorRBACEscalationVerbsAllowed,
+		message:       "Role grants the escalate, bind or impersonate verbs which allow privilege escalation",
+		allowed:       "Allowed granting the escalate, bind or impersonate verbs",
+		permission:    "escalate, bind or impersonate",
+		match: func(rule PolicyRuleV1) bool {
+			return containsString(rule.Verbs, "escalate") || containsString(rule.Verbs, "bind") ||
+				containsString(rule.Verbs, "impersonate")
+		},
+	},
+	{
+		overrideLabel: "allow-secrets-read",
+		id:            ErrorRBACSecretsRead,
+		allowedID:     ErrorRBACSecretsReadAllowed,
+		message:       "Role grants read access to secrets",
+		allowed:       "Allowed granting read access to secrets",
+		permission:    "read secrets",
+		match: func(rule PolicyRuleV1) bool {
+			// Access to secrets listed by name is usually intended
+			return len(rule.ResourceNames) == 0 && ruleGrants(rule, []string{""}, "secrets", "get", "list", "watch")
+		},
+	},
+	{
+		overrideLabel: "allow-pod-exec",
+		id:            ErrorRBACPodExec,
+		allowedID:     ErrorRBACPodExecAllowed,
+		message:       "Role allows executing commands in pods",
+		allowed:       "Allowed executing commands in pods",
+		permission:    "exec into pods",
+		match: func(rule PolicyRuleV1) bool {
+			return ruleGrants(rule, []string{""}, "pods/exec", "create", "get") ||
+				ruleGrants(rule, []string{""}, "pods/attach", "create", "get")
+		},
+	},
+	{
+		overrideLabel: "allow-pod-create",
+		id:            ErrorRBACPodCreate,
+		allowedID:     ErrorRBACPodCreateAllowed,
+		message:       "Role allows creating pods, which can run with any service account of the namespace",
+		allowed:       "Allowed creating pods",
+		permission:    "create pods",
+		match: func(rule PolicyRuleV1) bool {
+			for resource, groups := range podCreatingResources {
+				if ruleGrants(rule, groups, resource, "create") {
+					return true
+				}
+			}
+			return false
+		},
+	},
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleGrants returns true if the rule grants any of the verbs on the resource in any of the API groups, either
+// explicitly or through a wildcard.
+func ruleGrants(rule PolicyRuleV1, groups []string, resource string, verbs ...string) bool {
+	groupMatches := containsString(rule.APIGroups, "*")
+	for _, group := range groups {
+		groupMatches = groupMatches || containsString(rule.APIGroups, group)
+	}
+	if !groupMatches || !(containsString(rule.Resources, "*") || containsString(rule.Resources, resource)) {
+		return false
+	}
+	if containsString(rule.Verbs, "*") {
+		return true
+	}
+	for _, verb := range verbs {
+		if containsString(rule.Verbs, verb) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingRuleChecks returns the checks failed by any of the rules.
+func matchingRuleChecks(rules []PolicyRuleV1) (checks []rbacRuleCheck) {
+	for _, check := range rbacRuleChecks {
+		for _, rule := range rules {
+			if check.match(rule) {
+				checks = append(checks, check)
+				break
+			}
+		}
+	}
+	return checks
+}
+
+func ruleMetadata(rule PolicyRuleV1) Metadata {
+	return Metadata{
+		"APIGroups": strings.Join(rule.APIGroups, ","),
+		"Resources": strings.Join(rule.Resources, ","),
+		"Verbs":     strings.Join(rule.Verbs, ","),
+	}
+}
+
+// checkRBACRules checks the rules of a Role or ClusterRole. Reading secrets is only a warning for a Role since it is
+// limited to the Role's namespace.
+func checkRBACRules(rules []PolicyRuleV1, result *Result, secretsReadKind int) {
+	for _, check := range rbacRuleChecks {
+		var matched *PolicyRuleV1
+		for i := range rules {
+			if check.match(rules[i]) {
+				matched = &rules[i]
+				break
+			}
+		}
+
+		if labelExists, reason := getRBACOverrideLabelReason(result, check.overrideLabel); labelExists {
+			if matched != nil {
+				metadata := ruleMetadata(*matched)
+				metadata["Reason"] = prettifyReason(reason)
+				occ := Occurrence{
+					id:       check.allowedID,
+					kind:     Warn,
+					message:  check.allowed,
+					metadata: metadata,
+				}
+				result.Occurrences = append(result.Occurrences, occ)
+			} else {
+				occ := Occurrence{
+					id:       ErrorMisconfiguredKubeauditAllow,
+					kind:     Warn,
+					message:  "Allowed " + check.overrideLabel + " but the role doesn't need it",
+					metadata: Metadata{"Reason": prettifyReason(reason)},
+				}
+				result.Occurrences = append(result.Occurrences, occ)
+			}
+		} else if matched != nil {
+			kind := Error
+			if check.id == ErrorRBACSecretsRead {
+				kind = secretsReadKind
+			}
+			occ := Occurrence{
+				id:       check.id,
+				kind:     kind,
+				message:  check.message,
+				metadata: ruleMetadata(*matched),
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+	}
+}
+
+func isAnonymousSubject(subject SubjectV1) bool {
+	return (subject.Kind == "User" && subject.Name == "system:anonymous") ||
+		(subject.Kind == "Group" && subject.Name == "system:unauthenticated")
+}
+
+func checkRBACBinding(roleRef RoleRefV1, subjects []SubjectV1, result *Result) {
+	role := roleRef.Kind + "/" + roleRef.Name
+	isClusterAdmin := roleRef.Kind == "ClusterRole" && roleRef.Name == "cluster-admin"
+	if labelExists, reason := getRBACOverrideLabelReason(result, "allow-cluster-admin-binding"); labelExists {
+		if isClusterAdmin {
+			occ := Occurrence{
+				id:       ErrorRBACClusterAdminBindingAllowed,
+				kind:     Warn,
+				message:  "Allowed binding the cluster-admin ClusterRole",
+				metadata: Metadata{"RoleRef": role, "Reason": prettifyReason(reason)},
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		} else {
+			occ := Occurrence{
+				id:       ErrorMisconfiguredKubeauditAllow,
+				kind:     Warn,
+				message:  "Allowed binding the cluster-admin ClusterRole, but the binding refers to another role",
+				metadata: Metadata{"RoleRef": role, "Reason": prettifyReason(reason)},
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+	} else if isClusterAdmin {
+		occ := Occurrence{
+			id:       ErrorRBACClusterAdminBinding,
+			kind:     Error,
+			message:  "Binding grants the cluster-admin ClusterRole, bind a role with only the permissions needed instead",
+			metadata: Metadata{"RoleRef": role},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+
+	var anonymous []string
+	for _, subject := range subjects {
+		if isAnonymousSubject(subject) {
+			anonymous = append(anonymous, subject.Kind+"/"+subject.Name)
+		}
+	}
+	if labelExists, reason := getRBACOverrideLabelReason(result, "allow-anonymous-binding"); labelExists {
+		if len(anonymous) > 0 {
+			occ := Occurrence{
+				id:      ErrorRBACAnonymousBindingAllowed,
+				kind:    Warn,
+				message: "Allowed binding a role to unauthenticated users",
+				metadata: Metadata{
+					"RoleRef":  role,
+					"Subjects": strings.Join(anonymous, ","),
+					"Reason":   prettifyReason(reason),
+				},
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		} else {
+			occ := Occurrence{
+				id:       ErrorMisconfiguredKubeauditAllow,
+				kind:     Warn,
+				message:  "Allowed binding a role to unauthenticated users, but the binding has no such subject",
+				metadata: Metadata{"Reason": prettifyReason(reason)},
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+	} else if len(anonymous) > 0 {
+		occ := Occurrence{
+			id:       ErrorRBACAnonymousBinding,
+			kind:     Error,
+			message:  "Binding grants a role to unauthenticated users",
+			metadata: Metadata{"RoleRef": role, "Subjects": strings.Join(anonymous, ",")},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+}
+
+// isRBACDefault returns true for the roles and bindings every cluster is bootstrapped with, which can't be changed
+// by their users.
+func isRBACDefault(resource Resource) bool {
+	meta, ok := resource.(metav1.Object)
+	return ok && meta.GetLabels()["kubernetes.io/bootstrapping"] == "rbac-defaults"
+}
+
+func auditRBAC(resource Resource) (results []Result) {
+	if !IsRBACResourceType(resource) || isRBACDefault(resource) {
+		return
+	}
+	result, err, warn := newResultFromResource(resource)
+	if warn != nil {
+		log.Warn(warn)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	switch kubeType := resource.(type) {
+	case *RoleV1:
+		checkRBACRules(kubeType.Rules, result, Warn)
+	case *ClusterRoleV1:
+		checkRBACRules(kubeType.Rules, result, Error)
+	case *RoleBindingV1:
+		checkRBACBinding(kubeType.RoleRef, kubeType.Subjects, result)
+	case *ClusterRoleBindingV1:
+		checkRBACBinding(kubeType.RoleRef, kubeType.Subjects, result)
+	}
+	if len(result.Occurrences) > 0 {
+		results = append(results, *result)
+	}
+	return
+}
+
+var rbacCmd = &cobra.Command{
+	Use:   "rbac",
+	Short: "Audit Roles, ClusterRoles and their bindings",
+	Long: `This command audits Roles and ClusterRoles for rules which are too broad
+or allow privilege escalation, and RoleBindings and ClusterRoleBindings
+which grant cluster-admin or grant anything to unauthenticated users.
+
+A FAIL is generated when a role:
+- grants every verb or every resource with a wildcard
+- grants the escalate, bind or impersonate verbs
+- grants read access to secrets (a warning for a namespaced Role)
+- allows executing commands in pods
+- allows creating pods, directly or through a workload controller
+A FAIL is generated when a binding:
+- binds the cluster-admin ClusterRole
+- has system:anonymous or system:unauthenticated as a subject
+
+The default roles and bindings of the cluster, labeled
+kubernetes.io/bootstrapping=rbac-defaults, are not audited.
+
+Example usage:
+kubeaudit rbac`,
+	PreRun: func(*cobra.Command, []string) { auditsRBAC = true },
+	Run:    runAudit(auditRBAC),
+}
+
+func init() {
+	RootCmd.AddCommand(rbacCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRBACRolesV1(t *testing.T) {
+	results := runAuditTest(t, "rbac_roles_v1.yml", auditRBAC, []int{
+		ErrorRBACWildcardVerbs, ErrorRBACWildcardResources, ErrorRBACEscalationVerbs, ErrorRBACSecretsRead,
+		ErrorRBACPodExec, ErrorRBACPodCreate,
+	})
+	// The bootstrapped role and the role which only reads a named secret aren't reported
+	assert.Len(t, results, 3)
+}
+
+func TestRBACRoleSecretsReadV1(t *testing.T) {
+	results := runAuditTest(t, "rbac_secrets_read_v1.yml", auditRBAC, []int{ErrorRBACSecretsRead})
+	assert.Equal(t, Warn, results[0].Occurrences[0].kind)
+}
+
+func TestRBACRolesAllowedV1(t *testing.T) {
+	runAuditTest(t, "rbac_roles_allowed_v1.yml", auditRBAC, []int{ErrorRBACSecretsReadAllowed, ErrorMisconfiguredKubeauditAllow})
+}
+
+func TestRBACBindingsV1(t *testing.T) {
+	results := runAuditTest(t, "rbac_bindings_v1.yml", auditRBAC, []int{ErrorRBACClusterAdminBinding, ErrorRBACAnonymousBinding})
+	assert.Len(t, results, 2)
+}
+
+func TestRBACBindingsAllowedV1(t *testing.T) {
+	runAuditTest(t, "rbac_bindings_allowed_v1.yml", auditRBAC, []int{ErrorRBACClusterAdminBindingAllowed, ErrorMisconfiguredKubeauditAllow})
+}
+
+func TestRBACAllowedFromConfig(t *testing.T) {
+	rootConfig.auditConfig = "../configs/allow_rbac_from_config.yml"
+	runAuditTest(t, "rbac_bindings_v1.yml", auditRBAC, []int{ErrorRBACClusterAdminBindingAllowed, ErrorRBACAnonymousBinding, ErrorMisconfiguredKubeauditAllow})
+	rootConfig.auditConfig = ""
+}
+
+func TestRBACIgnoresWorkloads(t *testing.T) {
+	runAuditTest(t, "pod_v1.yml", auditRBAC, []int{})
+}
+
+func TestGetKubeResourcesRBAC(t *testing.T) {
+	rootConfig.namespaces = nil
+	client := fakeclientset.NewSimpleClientset(
+		&NamespaceV1{ObjectMeta: ObjectMetaV1{Name: "ns1"}},
+		&RoleV1{ObjectMeta: ObjectMetaV1{Name: "role1", Namespace: "ns1"}},
+		&RoleBindingV1{ObjectMeta: ObjectMetaV1{Name: "rolebinding1", Namespace: "ns1"}},
+		&ClusterRoleV1{ObjectMeta: ObjectMetaV1{Name: "clusterrole1"}},
+		&ClusterRoleBindingV1{ObjectMeta: ObjectMetaV1{Name: "clusterrolebinding1"}},
+	)
+	allowAccessReviews(client, func(attributes *authorizationv1.ResourceAttributes) bool { return true })
+
+	resources, err := getKubeResources(context.Background(), client, "")
+	assert.Nil(t, err)
+	assert.Len(t, resources, 1, "RBAC resources are only listed for the audits which need them")
+
+	auditsRBAC = true
+	defer func() { auditsRBAC = false }()
+	resources, err = getKubeResources(context.Background(), client, "")
+	assert.Nil(t, err)
+	assert.Len(t, resources, 5)
+}
@@ -0,0 +1,405 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// manifestMediaTypes are the manifests the registry client accepts. Indexes and manifest lists come first so that the digest
+// of a multi-platform image is the one of the index, which is what the container runtime pulls.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// dockerHubRegistry is the host serving the distribution API of the default registry.
+const dockerHubRegistry = "registry-1.docker.io"
+
+// dockerConfig is the part of a docker config file holding registry credentials. Credential helpers and stores aren't
+// supported, run `docker login` with them disabled to get the credentials into the file.
+type dockerConfig struct {
+	Auths map[string]dockerConfigAuth `json:"auths"`
+}
+
+type dockerConfigAuth struct {
+	Auth     string `json:"auth"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// registryCredentials are the username and password used to log into a registry.
+type registryCredentials struct {
+	username string
+	password string
+}
+
+// defaultDockerConfigPath returns the docker config file the docker CLI uses.
+func defaultDockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, ok := os.LookupEnv("HOME")
+	if !ok {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// loadDockerConfig reads the credentials of a docker config file. A missing file has no credentials.
+func loadDockerConfig(filename string) (dockerConfig, error) {
+	var config dockerConfig
+	if filename == "" {
+		return config, nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("parsing docker config %s: %v", filename, err)
+	}
+	return config, nil
+}
+
+// normalizeRegistryHost turns the keys of the docker config, which may be URLs such as https://index.docker.io/v1/,
+// into the registry of an ImageReference.
+func normalizeRegistryHost(registry string) string {
+	registry = strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://")
+	if i := strings.Index(registry, "/"); i >= 0 {
+		registry = registry[:i]
+	}
+	switch registry {
+	case "index.docker.io", dockerHubRegistry:
+		return DefaultRegistry
+	}
+	return registry
+}
+
+// credentials returns the credentials of the registry, and false if the config doesn't have any.
+func (config dockerConfig) credentials(registry string) (registryCredentials, bool) {
+	for key, auth := range config.Auths {
+		if normalizeRegistryHost(key) != registry {
+			continue
+		}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				continue
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			return registryCredentials{username: parts[0], password: parts[1]}, true
+		}
+		if auth.Username != "" {
+			return registryCredentials{username: auth.Username, password: auth.Password}, true
+		}
+	}
+	return registryCredentials{}, false
+}
+
+// registryBaseURL returns the URL of the distribution API of the registry. Registries on the loopback interface are
+// contacted over plain HTTP, the way docker treats them as insecure registries by default.
+func registryBaseURL(registry string) string {
+	if registry == DefaultRegistry {
+		registry = dockerHubRegistry
+	}
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return "http://" + registry
+	}
+	return "https://" + registry
+}
+
+// parseAuthChallenge parses a WWW-Authenticate header such as
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io"` into its scheme and parameters.
+func parseAuthChallenge(header string) (string, map[string]string) {
+	params := map[string]string{}
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	scheme := strings.ToLower(parts[0])
+	if len(parts) == 1 {
+		return scheme, params
+	}
+	rest := parts[1]
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma+1:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+	}
+	return scheme, params
+}
+
+// registryClient fetches manifests and blobs of images through the OCI distribution API. It is safe for concurrent use.
+type registryClient struct {
+	client *http.Client
+	config dockerConfig
+	mutex  sync.Mutex
+	// digests caches the digest of every image resolved so far, by fully qualified reference
+	digests map[string]string
+}
+
+// errRegistryNotFound is returned when the registry doesn't have the manifest or blob.
+var errRegistryNotFound = errors.New("not found in the registry")
+
+func newRegistryClient(client *http.Client, config dockerConfig) *registryClient {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &registryClient{client: client, config: config, digests: map[string]string{}}
+}
+
+// resolve returns the digest of the manifest the tag of the image points to. An image without a tag resolves latest.
+func (registry *registryClient) resolve(ref ImageReference) (string, error) {
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	key := ref.Name() + ":" + tag
+	registry.mutex.Lock()
+	digest, ok := registry.digests[key]
+	registry.mutex.Unlock()
+	if ok {
+		return digest, nil
+	}
+
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", registryBaseURL(ref.Registry), ref.Repository, tag)
+	// HEAD is enough when the registry returns the digest in a header, which every registry is supposed to do
+	resp, err := registry.do(http.MethodHead, manifestURL, ref)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	digest = resp.Header.Get("Docker-Content-Digest")
+	if resp.StatusCode != http.StatusOK || !sha256DigestRegexp.MatchString(digest) {
+		manifest, err := registry.fetchManifest(ref, tag)
+		if err != nil {
+			return "", err
+		}
+		digest = fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	}
+
+	registry.mutex.Lock()
+	registry.digests[key] = digest
+	registry.mutex.Unlock()
+	return digest, nil
+}
+
+// fetchManifest returns the manifest of the repository of the image with the tag or digest. It returns
+// errRegistryNotFound if the registry doesn't have it.
+func (registry *registryClient) fetchManifest(ref ImageReference, reference string) ([]byte, error) {
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", registryBaseURL(ref.Registry), ref.Repository, reference)
+	return registry.fetch(manifestURL, ref, fmt.Sprintf("manifest %s of %s", reference, ref.Name()))
+}
+
+// fetchBlob returns the blob of the repository of the image with the digest, after checking that it has that digest.
+func (registry *registryClient) fetchBlob(ref ImageReference, digest string) ([]byte, error) {
+	blob, err := registry.openBlob(ref, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+	return ioutil.ReadAll(blob)
+}
+
+// openBlob streams the blob of the repository of the image with the digest. Reading it to the end returns an error
+// if it doesn't have that digest.
+func (registry *registryClient) openBlob(ref ImageReference, digest string) (io.ReadCloser, error) {
+	if !sha256DigestRegexp.MatchString(digest) {
+		return nil, fmt.Errorf("unsupported blob digest %q", digest)
+	}
+	blobURL := fmt.Sprintf("%s/v2/%s/blobs/%s", registryBaseURL(ref.Registry), ref.Repository, digest)
+	what := fmt.Sprintf("blob %s of %s", digest, ref.Name())
+	body, err := registry.open(blobURL, ref, what)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	return &verifiedBlob{Reader: io.TeeReader(body, hash), body: body, hash: hash, digest: digest, what: what}, nil
+}
+
+// verifiedBlob hashes a blob as it is read and checks it against its digest once the whole blob was read.
+type verifiedBlob struct {
+	io.Reader
+	body   io.ReadCloser
+	hash   hash.Hash
+	digest string
+	what   string
+}
+
+func (blob *verifiedBlob) Read(p []byte) (int, error) {
+	n, err := blob.Reader.Read(p)
+	if err == io.EOF && fmt.Sprintf("sha256:%x", blob.hash.Sum(nil)) != blob.digest {
+		return n, fmt.Errorf("%s doesn't match its digest", blob.what)
+	}
+	return n, err
+}
+
+func (blob *verifiedBlob) Close() error {
+	return blob.body.Close()
+}
+
+func (registry *registryClient) fetch(fetchURL string, ref ImageReference, what string) ([]byte, error) {
+	body, err := registry.open(fetchURL, ref, what)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %v", what, err)
+	}
+	return data, nil
+}
+
+// open returns the body of the response to a GET of the URL. It returns errRegistryNotFound if the registry doesn't
+// have what is asked for.
+func (registry *registryClient) open(fetchURL string, ref ImageReference, what string) (io.ReadCloser, error) {
+	resp, err := registry.do(http.MethodGet, fetchURL, ref)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, errRegistryNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching %s: %s", what, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// do sends the request, logging into the registry with the credentials of the docker config if it asks for it.
+func (registry *registryClient) do(method, requestURL string, ref ImageReference) (*http.Response, error) {
+	req, err := registry.newRequest(method, requestURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := registry.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	credentials, hasCredentials := registry.config.credentials(ref.Registry)
+	scheme, params := parseAuthChallenge(resp.Header.Get("WWW-Authenticate"))
+	req, err = registry.newRequest(method, requestURL)
+	if err != nil {
+		return nil, err
+	}
+	switch scheme {
+	case "bearer":
+		token, err := registry.token(params, ref, credentials, hasCredentials)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case "basic":
+		if !hasCredentials {
+			return nil, fmt.Errorf("registry %s requires credentials but the docker config has none", ref.Registry)
+		}
+		req.SetBasicAuth(credentials.username, credentials.password)
+	default:
+		return nil, fmt.Errorf("registry %s requires unsupported authentication %q", ref.Registry, scheme)
+	}
+	return registry.client.Do(req)
+}
+
+func (registry *registryClient) newRequest(method, requestURL string) (*http.Request, error) {
+	req, err := http.NewRequest(method, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	return req, nil
+}
+
+// token gets a token to pull the repository from the token server of the registry, anonymously if the docker config
+// doesn't have credentials for it.
+func (registry *registryClient) token(params map[string]string, ref ImageReference, credentials registryCredentials, hasCredentials bool) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("registry %s returned an invalid token realm %q", ref.Registry, params["realm"])
+	}
+	query := realm.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", ref.Repository))
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasCredentials {
+		req.SetBasicAuth(credentials.username, credentials.password)
+	}
+	resp, err := registry.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("getting a token for %s: %s", ref.Name(), resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("getting a token for %s: %v", ref.Name(), err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("getting a token for %s: the token server returned no token", ref.Name())
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRegistryUser     = "user"
+	testRegistryPassword = "secret"
+	testRegistryToken    = "token"
+	testManifest         = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`
+)
+
+var testManifestDigest = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(testManifest)))
+
+// newTestRegistry starts a registry serving the manifest of team/app:1.0, which requires a token from its token
+// server, and of public/app:1.0, which doesn't return the digest in a header. basic/app:1.0 requires basic auth.
+// public/app also serves the manifest as a blob, under its digest and under another one.
+func newTestRegistry(t *testing.T) (*httptest.Server, string) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if user, password, ok := r.BasicAuth(); !ok || user != testRegistryUser || password != testRegistryPassword {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "repository:team/app:pull", r.URL.Query().Get("scope"))
+			assert.Equal(t, "test-registry", r.URL.Query().Get("service"))
+			fmt.Fprintf(w, `{"token":%q}`, testRegistryToken)
+		case "/v2/team/app/manifests/1.0":
+			if r.Header.Get("Authorization") != "Bearer "+testRegistryToken {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Contains(t, r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json")
+			w.Header().Set("Docker-Content-Digest", testManifestDigest)
+			if r.Method == http.MethodGet {
+				fmt.Fprint(w, testManifest)
+			}
+		case "/v2/basic/app/manifests/1.0":
+			if user, password, ok := r.BasicAuth(); !ok || user != testRegistryUser || password != testRegistryPassword {
+				w.Header().Set("WWW-Authenticate", `Basic realm="test-registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", testManifestDigest)
+		case "/v2/public/app/manifests/1.0":
+			if r.Method == http.MethodGet {
+				fmt.Fprint(w, testManifest)
+			}
+		case "/v2/public/app/blobs/" + testManifestDigest:
+			fmt.Fprint(w, testManifest)
+		case "/v2/public/app/blobs/sha256:" + strings.Repeat("b", 64):
+			// A blob which doesn't match its digest
+			fmt.Fprint(w, testManifest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, strings.TrimPrefix(server.URL, "http://")
+}
+
+func writeTestDockerConfig(t *testing.T, registry string) string {
+	dir, err := ioutil.TempDir("", "kubeaudit_docker_config")
+	require.NoError(t, err)
+	auth := base64.StdEncoding.EncodeToString([]byte(testRegistryUser + ":" + testRegistryPassword))
+	filename := filepath.Join(dir, "config.json")
+	config := fmt.Sprintf(`{"auths":{"http://%s/v2/":{"auth":%q},"https://index.docker.io/v1/":{"username":"hub","password":"pw"}}}`, registry, auth)
+	require.NoError(t, ioutil.WriteFile(filename, []byte(config), 0600))
+	return filename
+}
+
+func TestDockerConfigCredentials(t *testing.T) {
+	filename := writeTestDockerConfig(t, "127.0.0.1:5000")
+	defer os.RemoveAll(filepath.Dir(filename))
+	config, err := loadDockerConfig(filename)
+	require.NoError(t, err)
+
+	credentials, ok := config.credentials("127.0.0.1:5000")
+	assert.True(t, ok)
+	assert.Equal(t, registryCredentials{username: testRegistryUser, password: testRegistryPassword}, credentials)
+	credentials, ok = config.credentials(DefaultRegistry)
+	assert.True(t, ok)
+	assert.Equal(t, registryCredentials{username: "hub", password: "pw"}, credentials)
+	_, ok = config.credentials("gcr.io")
+	assert.False(t, ok)
+
+	config, err = loadDockerConfig(filepath.Join(filepath.Dir(filename), "missing.json"))
+	assert.NoError(t, err)
+	assert.Empty(t, config.Auths)
+}
+
+func TestRegistryBaseURL(t *testing.T) {
+	assert.Equal(t, "https://registry-1.docker.io", registryBaseURL(DefaultRegistry))
+	assert.Equal(t, "https://gcr.io", registryBaseURL("gcr.io"))
+	assert.Equal(t, "http://localhost:5000", registryBaseURL("localhost:5000"))
+	assert.Equal(t, "http://127.0.0.1:5000", registryBaseURL("127.0.0.1:5000"))
+	assert.Equal(t, "http://[::1]:5000", registryBaseURL("[::1]:5000"))
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	scheme, params := parseAuthChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`)
+	assert.Equal(t, "bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull",
+	}, params)
+
+	scheme, params = parseAuthChallenge(`Basic realm=registry`)
+	assert.Equal(t, "basic", scheme)
+	assert.Equal(t, map[string]string{"realm": "registry"}, params)
+}
+
+func TestPinImage(t *testing.T) {
+	server, registry := newTestRegistry(t)
+	defer server.Close()
+	filename := writeTestDockerConfig(t, registry)
+	defer os.RemoveAll(filepath.Dir(filename))
+	config, err := loadDockerConfig(filename)
+	require.NoError(t, err)
+	client := newRegistryClient(server.Client(), config)
+
+	for _, image := range []string{"team/app:1.0", "basic/app:1.0", "public/app:1.0"} {
+		pinned, err := pinImage(registry+"/"+image, client)
+		assert.NoError(t, err, image)
+		assert.Equal(t, registry+"/"+image+"@"+testManifestDigest, pinned)
+	}
+
+	pinnedImage := registry + "/team/app:1.0@sha256:" + strings.Repeat("a", 64)
+	pinned, err := pinImage(pinnedImage, client)
+	assert.NoError(t, err)
+	assert.Equal(t, pinnedImage, pinned)
+
+	_, err = pinImage(registry+"/missing/app:1.0", client)
+	assert.Error(t, err)
+
+	// Without credentials the token server refuses to hand out a token
+	anonymous := newRegistryClient(server.Client(), dockerConfig{})
+	_, err = pinImage(registry+"/team/app:1.0", anonymous)
+	assert.Error(t, err)
+	_, err = pinImage(registry+"/basic/app:1.0", anonymous)
+	assert.Error(t, err)
+}
+
+func TestFetchBlob(t *testing.T) {
+	server, registry := newTestRegistry(t)
+	defer server.Close()
+	client := newRegistryClient(server.Client(), dockerConfig{})
+	ref := ImageReference{Registry: registry, Repository: "public/app"}
+
+	blob, err := client.fetchBlob(ref, testManifestDigest)
+	require.NoError(t, err)
+	assert.Equal(t, testManifest, string(blob))
+
+	_, err = client.fetchBlob(ref, "sha256:"+strings.Repeat("b", 64))
+	assert.Error(t, err)
+	_, err = client.fetchBlob(ref, "sha256:"+strings.Repeat("c", 64))
+	assert.Equal(t, errRegistryNotFound, err)
+	_, err = client.fetchBlob(ref, "md5:abc")
+	assert.Error(t, err)
+}
+
+func TestFixPinDigestsV1(t *testing.T) {
+	server, registry := newTestRegistry(t)
+	defer server.Close()
+	filename := writeTestDockerConfig(t, registry)
+	defer os.RemoveAll(filepath.Dir(filename))
+
+	data, err := ioutil.ReadFile("../fixtures/pin_digests_v1.yml")
+	require.NoError(t, err)
+	origFile, err := ioutil.TempFile("", "kubeaudit_pin_digests")
+	require.NoError(t, err)
+	defer os.Remove(origFile.Name())
+	_, err = origFile.Write([]byte(strings.Replace(string(data), "REGISTRY", registry, -1)))
+	require.NoError(t, err)
+	origFile.Close()
+
+	resources, err := getKubeResourcesManifest(origFile.Name())
+	require.NoError(t, err)
+	autofixConfig = autofixFlags{pinDigests: true}
+	rootConfig.dockerConfig = filename
+	defer func() {
+		autofixConfig = autofixFlags{}
+		rootConfig.dockerConfig = ""
+	}()
+	fixedResources, _ := fix(resources)
+	require.Len(t, fixedResources, 1)
+	containers := getContainers(fixedResources[0])
+	assert.Equal(t, registry+"/team/app:1.0@"+testManifestDigest, containers[0].Image)
+	assert.Equal(t, registry+"/team/app:1.0@sha256:"+strings.Repeat("a", 64), containers[1].Image)
+	podSpec, _ := podSpecOf(fixedResources[0])
+	assert.Equal(t, registry+"/public/app:1.0@"+testManifestDigest, podSpec.InitContainers[0].Image)
+
+	// The pinned image is merged back into the original manifest, keeping its comments
+	var fixed map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(strings.Replace(string(data), "REGISTRY", registry, -1)), &fixed))
+	fixed["spec"].(map[interface{}]interface{})["containers"].([]interface{})[0].(map[interface{}]interface{})["image"] = containers[0].Image
+	fixedData, err := yaml.Marshal(fixed)
+	require.NoError(t, err)
+	fixedFile, err := ioutil.TempFile("", "kubeaudit_pin_digests_fixed")
+	require.NoError(t, err)
+	defer os.Remove(fixedFile.Name())
+	_, err = fixedFile.Write(fixedData)
+	require.NoError(t, err)
+	fixedFile.Close()
+
+	merged, err := mergeYAML(origFile.Name(), fixedFile.Name())
+	require.NoError(t, err)
+	assert.Contains(t, string(merged), "# The application")
+	assert.Contains(t, string(merged), "image: "+containers[0].Image+" # bumped by the release pipeline")
+}
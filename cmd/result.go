@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"reflect"
+	"sort"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -11,6 +12,7 @@ import (
 // resource.
 type Result struct {
 	CPULimitActual string
+	Cluster        string
 	CPULimitMax    string
 	DSA            string
 	Err            int
@@ -23,6 +25,8 @@ type Result struct {
 	Name           string
 	Namespace      string
 	Occurrences    []Occurrence
+	Owner          string
+	Pods           []string
 	SA             string
 	Token          *bool
 }
@@ -61,6 +65,15 @@ func createFields(res Result, occ Occurrence) (fields log.Fields) {
 	if len(occ.container) != 0 {
 		fields["Container"] = occ.container
 	}
+	if len(res.Cluster) != 0 {
+		fields["Cluster"] = res.Cluster
+	}
+	if len(res.Owner) != 0 {
+		fields["Owner"] = res.Owner
+	}
+	if len(res.Pods) != 0 {
+		fields["Pods"] = strings.Join(res.Pods, ",")
+	}
 
 	if occ.id == ErrorRunAsNonRootPSCFalseCSCNil && len(occ.podHost) != 0 {
 		fields["Pod"] = occ.podHost
@@ -90,6 +103,39 @@ func shouldLog(err int) (members []string) {
 	return
 }
 
+// sortResults orders results by cluster, namespace, kind, name, container and rule so that two runs over the same
+// resources print the same report. Results which compare equal keep the order in which the audits produced them.
+func sortResults(results []Result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.KubeType != b.KubeType {
+			return a.KubeType < b.KubeType
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		containerA, idA := a.firstOccurrence()
+		containerB, idB := b.firstOccurrence()
+		if containerA != containerB {
+			return containerA < containerB
+		}
+		return idA < idB
+	})
+}
+
+func (res Result) firstOccurrence() (container string, id int) {
+	if len(res.Occurrences) == 0 {
+		return "", 0
+	}
+	return res.Occurrences[0].container, res.Occurrences[0].id
+}
+
 func (res *Result) allowedCaps(container ContainerV1) (allowed map[CapabilityV1]string) {
 
 	allowed = make(map[CapabilityV1]string)
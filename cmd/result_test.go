@@ -38,3 +38,20 @@ func TestCreateFields(t *testing.T) {
 	fields := createFields(results[0], results[0].Occurrences[0])
 	assert.Equal(t, 5, len(fields))
 }
+
+func TestSortResults(t *testing.T) {
+	results := []Result{
+		{Namespace: "b", KubeType: "pod", Name: "a"},
+		{Namespace: "a", KubeType: "pod", Name: "b", Occurrences: []Occurrence{{container: "c2", id: ErrorPrivilegedNil}}},
+		{Namespace: "a", KubeType: "pod", Name: "b", Occurrences: []Occurrence{{container: "c1", id: ErrorPrivilegedTrue}}},
+		{Namespace: "a", KubeType: "pod", Name: "b", Occurrences: []Occurrence{{container: "c1", id: ErrorPrivilegedNil}}},
+		{Namespace: "a", KubeType: "deployment", Name: "z"},
+	}
+	sortResults(results)
+	assert.Equal(t, "deployment", results[0].KubeType)
+	assert.Equal(t, ErrorPrivilegedNil, results[1].Occurrences[0].id)
+	assert.Equal(t, "c1", results[1].Occurrences[0].container)
+	assert.Equal(t, ErrorPrivilegedTrue, results[2].Occurrences[0].id)
+	assert.Equal(t, "c2", results[3].Occurrences[0].container)
+	assert.Equal(t, "b", results[4].Namespace)
+}
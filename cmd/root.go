@@ -4,31 +4,40 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"io/ioutil"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	apiv1 "k8s.io/api/core/v1"
-
-	"github.com/Shopify/yaml"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 var rootConfig rootFlags
 
 type rootFlags struct {
-	allPods     bool
-	json        bool
-	kubeConfig  string
-	localMode   bool
-	manifest    string
-	namespace   string
-	verbose     string
-	auditConfig string
+	allPods           bool
+	json              bool
+	kubeConfig        string
+	kubeContexts      []string
+	allContexts       bool
+	localMode         bool
+	manifest          string
+	snapshot          string
+	namespaces        []string
+	excludeNamespaces []string
+	selector          string
+	kinds             []string
+	verbose           string
+	auditConfig       string
+	parallelism       int
+	timeout           time.Duration
+	kubeQPS           float32
+	kubeBurst         int
+	showPods          bool
+	dockerConfig      string
 }
 
-var kubeauditConfig = &KubeauditConfig{}
-
 // RootCmd defines the shell command usage for kubeaudit.
 var RootCmd = &cobra.Command{
 	Use:   "kubeaudit",
@@ -50,12 +59,26 @@ func init() {
 	RootCmd.PersistentFlags().BoolVarP(&rootConfig.localMode, "local", "l", false, "[DEPRECATED] Local mode, uses $HOME/.kube/config as configuration")
 	RootCmd.Flags().MarkHidden("local")
 	RootCmd.PersistentFlags().StringVarP(&rootConfig.kubeConfig, "kubeconfig", "c", "", "Specify local config file (default is $HOME/.kube/config)")
+	RootCmd.PersistentFlags().StringSliceVar(&rootConfig.kubeContexts, "context", nil, "Kubeconfig context to audit, can be repeated to audit several clusters (default is the current context)")
+	RootCmd.PersistentFlags().BoolVar(&rootConfig.allContexts, "all-contexts", false, "Audit the clusters of every context in the kubeconfig")
+	clientcmd.BindOverrideFlags(&kubeConfigOverrides, RootCmd.PersistentFlags(), kubeConfigOverrideFlags())
+	RootCmd.PersistentFlags().StringVar(&kubeConfigOverrides.AuthInfo.TokenFile, "token-file", "", "Path to a file containing a bearer token for authentication to the API server")
 	RootCmd.PersistentFlags().StringVarP(&rootConfig.verbose, "verbose", "v", "INFO", "Set the debug level")
 	RootCmd.PersistentFlags().BoolVarP(&rootConfig.json, "json", "j", false, "Enable json logging")
 	RootCmd.PersistentFlags().BoolVarP(&rootConfig.allPods, "allPods", "a", false, "Audit againsts pods in all the phases (default Running Phase)")
-	RootCmd.PersistentFlags().StringVarP(&rootConfig.namespace, "namespace", "n", apiv1.NamespaceAll, "Specify the namespace scope to audit")
+	RootCmd.PersistentFlags().StringSliceVarP(&rootConfig.namespaces, "namespace", "n", nil, "Specify the namespace scope to audit, can be repeated (default is all namespaces)")
+	RootCmd.PersistentFlags().StringSliceVar(&rootConfig.excludeNamespaces, "exclude-namespace", nil, "Namespace to leave out of the audit, can be repeated")
+	RootCmd.PersistentFlags().StringVar(&rootConfig.selector, "selector", "", "Only audit workloads matching the label selector, e.g. app=web,tier!=db")
+	RootCmd.PersistentFlags().StringSliceVar(&rootConfig.kinds, "kinds", nil, "Only audit resources of the given kinds, e.g. deployment,statefulset")
 	RootCmd.PersistentFlags().StringVarP(&rootConfig.manifest, "manifest", "f", "", "yaml configuration to audit")
+	RootCmd.PersistentFlags().StringVar(&rootConfig.snapshot, "snapshot", "", "Audit a snapshot taken with kubeaudit snapshot instead of a cluster")
 	RootCmd.PersistentFlags().StringVarP(&rootConfig.auditConfig, "auditconfig", "k", "", "filepath for kubeaudit config file")
+	RootCmd.PersistentFlags().DurationVar(&rootConfig.timeout, "timeout", 0, "Maximum time to spend fetching resources from the cluster, e.g. 30s or 5m (default is no timeout)")
+	RootCmd.PersistentFlags().Float32Var(&rootConfig.kubeQPS, "kube-api-qps", 50, "Maximum queries per second to the Kubernetes API server")
+	RootCmd.PersistentFlags().IntVar(&rootConfig.kubeBurst, "kube-api-burst", 100, "Maximum burst of queries to the Kubernetes API server")
+	RootCmd.PersistentFlags().BoolVar(&rootConfig.showPods, "show-pods", false, "List the pods affected by each finding on a controller")
+	RootCmd.PersistentFlags().IntVar(&rootConfig.parallelism, "parallelism", 0, "Number of resources to audit concurrently (default is the number of CPUs)")
+	RootCmd.PersistentFlags().StringVar(&rootConfig.dockerConfig, "docker-config", defaultDockerConfigPath(), "Docker config file with the credentials of private registries")
 }
 
 func processFlags() {
@@ -78,20 +101,30 @@ func processFlags() {
 	}
 
 	if rootConfig.auditConfig != "" {
-		var kubeauditConfig = &KubeauditConfig{}
 		data, err := ioutil.ReadFile(rootConfig.auditConfig)
 		if err != nil {
 			log.Warn("Unable to find file at set auditConfig path, auditing without any config")
 			return
 		}
-		err = yaml.Unmarshal(data, kubeauditConfig)
+		kubeauditConfig, err := parseAuditConfig(data)
 		if err != nil {
-			log.Fatal("Unable to parse given auditConfig file, please check the syntax of your config file")
+			log.Fatalf("Unable to parse given auditConfig file, please check the syntax of your config file: %v", err)
 		}
+		setAuditConfig(rootConfig.auditConfig, kubeauditConfig)
 		if !kubeauditConfig.Audit {
 			log.Warn("kubeaudit set to no-audit mode in auditConfig!")
 			os.Exit(0)
 		}
+		if kubeauditConfig.Spec != nil {
+			applyConfigFilters(kubeauditConfig.Spec.Filters)
+			if len(rootConfig.kubeContexts) == 0 {
+				rootConfig.kubeContexts = kubeauditConfig.Spec.Contexts
+			}
+		}
 	}
 
+	if rootConfig.manifest != "" && rootConfig.snapshot != "" {
+		log.Fatal("--manifest and --snapshot can't be used together")
+	}
+	validateFilters()
 }
@@ -86,7 +86,7 @@ func checkRunAsNonRootPSC(podSpec PodSpecV1, container ContainerV1, result *Resu
 
 func auditRunAsNonRoot(resource Resource) (results []Result) {
 	// get PodSpec for PodSecurityContext
-	podSpec := getPodSpecs(resource)
+	podSpec, _ := podSpecOf(resource)
 	for _, container := range getContainers(resource) {
 		result, err, warn := newResultFromResource(resource)
 		if warn != nil {
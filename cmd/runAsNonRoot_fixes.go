@@ -1,6 +1,10 @@
 package cmd
 
 func fixRunAsNonRoot(result *Result, resource Resource, occurrence Occurrence) Resource {
+	// A container setting runAsNonRoot to false overrides the pod, so it has to be fixed in the container
+	if autofixConfig.podSecurityContext && occurrence.id != ErrorRunAsNonRootPSCTrueFalseCSCFalse {
+		return fixRunAsNonRootPSC(result, resource)
+	}
 	var containers []ContainerV1
 	for _, container := range getContainers(resource) {
 		if labelExists, _ := getContainerOverrideLabelReason(result, container, "allow-run-as-root"); occurrence.container == container.Name && !labelExists {
@@ -10,3 +14,32 @@ func fixRunAsNonRoot(result *Result, resource Resource, occurrence Occurrence) R
 	}
 	return setContainers(resource, containers)
 }
+
+// fixRunAsNonRootPSC sets runAsNonRoot in the pod security context. Containers which are allowed to run as root and
+// used to inherit it from the pod get runAsNonRoot set to false so they keep running as root.
+func fixRunAsNonRootPSC(result *Result, resource Resource) Resource {
+	podSpec, ok := podSpecOf(resource)
+	if !ok {
+		return resource
+	}
+	var containers []ContainerV1
+	for _, container := range getContainers(resource) {
+		if labelExists, _ := getContainerOverrideLabelReason(result, container, "allow-run-as-root"); labelExists {
+			if container.SecurityContext == nil {
+				container.SecurityContext = &SecurityContextV1{}
+			}
+			if container.SecurityContext.RunAsNonRoot == nil {
+				container.SecurityContext.RunAsNonRoot = newFalse()
+			}
+		}
+		containers = append(containers, container)
+	}
+	resource = setContainers(resource, containers)
+
+	securityContext := podSpec.SecurityContext.DeepCopy()
+	if securityContext == nil {
+		securityContext = &PodSecurityContextV1{}
+	}
+	securityContext.RunAsNonRoot = newTrue()
+	return setPodSecurityContext(resource, securityContext)
+}
@@ -59,3 +59,25 @@ func TestFixRunAsRootFalseAllowedMultiContainersSingleLabelV1(t *testing.T) {
 		}
 	}
 }
+
+func TestFixRunAsNonRootPodSecurityContextV1(t *testing.T) {
+	autofixConfig.podSecurityContext = true
+	defer func() { autofixConfig.podSecurityContext = false }()
+	assert, resources := FixTestSetupMultipleResources(t, "run_as_non_root_nil_allowed_deployment_v1.yml", auditRunAsNonRoot)
+	podSpec, _ := podSpecOf(resources[0])
+	assert.True(*podSpec.SecurityContext.RunAsNonRoot)
+	for _, container := range getContainers(resources[0]) {
+		switch container.Name {
+		case "fakeContainerRANR":
+			assert.Nil(container.SecurityContext.RunAsNonRoot)
+		case "fakeContainerRANR2":
+			assert.False(*container.SecurityContext.RunAsNonRoot)
+		case "fakeContainerRANR3":
+			assert.True(*container.SecurityContext.RunAsNonRoot)
+		}
+	}
+	// Only the container allowed to run as root is still reported
+	results := getResults(resources, auditRunAsNonRoot)
+	assert.Len(results, 1)
+	assert.Equal(ErrorRunAsNonRootFalseAllowed, results[0].Occurrences[0].id)
+}
@@ -65,10 +65,20 @@ func TestPSCRunAsRootFalseAllowedMultiContainersV2(t *testing.T) {
 func TestAllowAuditPSCRunAsRootFalseAllowedMultiContainersFromConfigV2(t *testing.T) {
 	rootConfig.auditConfig = "../configs/allow_audit_from_config.yml"
 	runAuditTest(t, "run_as_non_root_psc_false_allowed_multi_containers_single_label_v1.yml", auditRunAsNonRoot, []int{ErrorRunAsNonRootPSCTrueFalseCSCFalse, ErrorRunAsNonRootPSCTrueFalseCSCFalse})
+	rootConfig.auditConfig = ""
 }
 func TestAllowRunAsNonRootFromConfig(t *testing.T) {
 	rootConfig.auditConfig = "../configs/allow_run_as_non_root_from_config.yml"
 	runAuditTest(t, "security_context_nil_v1.yml", auditRunAsNonRoot, []int{ErrorRunAsNonRootFalseAllowed})
 	runAuditTest(t, "run_as_non_root_nil_v1.yml", auditRunAsNonRoot, []int{ErrorRunAsNonRootFalseAllowed})
 	runAuditTest(t, "run_as_non_root_false_v1.yml", auditRunAsNonRoot, []int{ErrorRunAsNonRootFalseAllowed})
+	rootConfig.auditConfig = ""
+}
+
+func TestPSCTrueCSCNilDeploymentV1(t *testing.T) {
+	runAuditTest(t, "run_as_non_root_psc_true_deployment_v1.yml", auditRunAsNonRoot, []int{})
+}
+
+func TestPSCFalseCSCNilStatefulSetV1(t *testing.T) {
+	runAuditTest(t, "run_as_non_root_psc_false_statefulset_v1.yml", auditRunAsNonRoot, []int{ErrorRunAsNonRootPSCFalseCSCNil})
 }
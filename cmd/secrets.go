@@ -0,0 +1,387 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// auditsConfigMaps is set by the secrets audit so that ConfigMaps are only read from the cluster when needed. The
+// other audits then don't require permission to list them. Snapshots always save them.
+var auditsConfigMaps bool
+
+// Strings of random characters are taken for credentials when they're at least minSecretLength characters long and
+// their Shannon entropy, in bits per character, is above the threshold of their alphabet.
+const (
+	minSecretLength        = 20
+	base64EntropyThreshold = 4.5
+	hexEntropyThreshold    = 3.0
+)
+
+// secretPattern is a known credential format.
+type secretPattern struct {
+	kind    string
+	pattern *regexp.Regexp
+}
+
+var secretPatterns = []secretPattern{
+	{"AWS access key ID", regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`)},
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----(?:[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----)?`)},
+	{"GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}`)},
+	{"JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"password in URL", regexp.MustCompile(`\b[A-Za-z][A-Za-z0-9+.-]*://[^/\s:@]+:[^/\s:@$][^/\s:@]*@`)},
+}
+
+var (
+	// A kubeconfig only holds credentials when one of its users has a key, token or password.
+	kubeconfigPattern           = regexp.MustCompile(`(?m)^kind:\s*["']?Config["']?\s*$`)
+	kubeconfigCredentialPattern = regexp.MustCompile(`(?m)^[\s-]*(?:client-key-data|client-key|token|password):\s*\S`)
+
+	// Certificates and digests are random but public, so they're left out of the entropy check.
+	certificatePattern = regexp.MustCompile(`-----BEGIN CERTIFICATE-----[\s\S]*?-----END CERTIFICATE-----`)
+	digestPattern      = regexp.MustCompile(`\b(?:md5|sha1|sha224|sha256|sha384|sha512):[0-9A-Fa-f]+`)
+
+	// Variables and keys named like credentials whose values aren't paths, references or settings of them.
+	credentialNamePattern    = regexp.MustCompile(`(?i)(?:^|[_.-])(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credentials?)(?:$|[_.-])`)
+	notCredentialNamePattern = regexp.MustCompile(`(?i)[_.-](?:file|path|dir|name|ref|url|host|user|username|ttl|timeout|expiry|length|enabled)$`)
+)
+
+// secretFinding is a probable credential, which is only ever printed as its fingerprint.
+type secretFinding struct {
+	kind        string
+	fingerprint string
+}
+
+// runFingerprintKey keys the fingerprints when the config has no fingerprintKey, so that they can't be matched against
+// hashes of guessed passwords. Fingerprints then change from one run to the next.
+var runFingerprintKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatal("Unable to generate a fingerprint key: ", err)
+	}
+	return key
+}()
+
+// secretFingerprint identifies a credential without giving it away, so that findings can be told apart and ignored.
+// It's an HMAC keyed with the fingerprintKey of the config, since a plain hash of a password with little entropy could
+// be reversed by hashing guesses.
+func secretFingerprint(secret string) string {
+	key := runFingerprintKey
+	if configured := secretsConfig().FingerprintKey; configured != "" {
+		key = []byte(configured)
+	}
+	return keyedSecretFingerprint(key, secret)
+}
+
+func keyedSecretFingerprint(key []byte, secret string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(secret))
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// shannonEntropy returns the average number of bits of information per character of s.
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	var entropy float64
+	length := float64(len([]rune(s)))
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func isHexString(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isBase64String(s string) bool {
+	for _, r := range s {
+		if !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("+/=_-", r))) {
+			return false
+		}
+	}
+	return true
+}
+
+// isHighEntropy returns true if the token looks like random characters, such as a generated password or API key.
+func isHighEntropy(token string) bool {
+	if len(token) < minSecretLength {
+		return false
+	}
+	switch {
+	case isHexString(token):
+		return shannonEntropy(token) > hexEntropyThreshold
+	case isBase64String(token):
+		return shannonEntropy(token) > base64EntropyThreshold
+	}
+	return false
+}
+
+// isTokenSeparator splits values into the tokens checked for entropy, such as the value of key=value or a quoted
+// string.
+func isTokenSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`"'=:,;()[]{}<>@&?|\`+"`", r)
+}
+
+// scanForSecrets returns the probable credentials in a value: known credential formats, kubeconfigs with credentials
+// and strings of random characters.
+func scanForSecrets(value string) (findings []secretFinding) {
+	if kubeconfigPattern.MatchString(value) {
+		if kubeconfigCredentialPattern.MatchString(value) {
+			findings = append(findings, secretFinding{kind: "kubeconfig", fingerprint: secretFingerprint(value)})
+		}
+		// Kubeconfigs are full of public certificates, they're only checked for credentials
+		return findings
+	}
+
+	rest := value
+	for _, secret := range secretPatterns {
+		for _, match := range secret.pattern.FindAllString(rest, -1) {
+			findings = append(findings, secretFinding{kind: secret.kind, fingerprint: secretFingerprint(match)})
+		}
+		rest = secret.pattern.ReplaceAllString(rest, " ")
+	}
+	rest = certificatePattern.ReplaceAllString(rest, " ")
+	rest = digestPattern.ReplaceAllString(rest, " ")
+	for _, token := range strings.FieldsFunc(rest, isTokenSeparator) {
+		if isHighEntropy(token) {
+			findings = append(findings, secretFinding{kind: "high entropy string", fingerprint: secretFingerprint(token)})
+		}
+	}
+	return findings
+}
+
+// isCredentialName returns true for environment variables and keys named like credentials.
+func isCredentialName(name string) bool {
+	return credentialNamePattern.MatchString(name) && !notCredentialNamePattern.MatchString(name)
+}
+
+// isCredentialValue returns false for values which can't be the credential a variable is named after, such as a path
+// to a file holding it, a reference to another variable or a setting like a number or a boolean.
+func isCredentialValue(value string) bool {
+	value = strings.TrimSpace(value)
+	if len(value) < 8 || strings.HasPrefix(value, "/") || strings.Contains(value, "$(") || strings.Contains(value, "${") {
+		return false
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return false
+	}
+	if _, err := strconv.ParseBool(value); err == nil {
+		return false
+	}
+	return true
+}
+
+// scanNamedValue scans the value of an environment variable or key, which is a credential if it's named like one.
+func scanNamedValue(name, value string) []secretFinding {
+	findings := scanForSecrets(value)
+	if len(findings) == 0 && isCredentialName(name) && isCredentialValue(value) {
+		findings = append(findings, secretFinding{kind: "credential named " + name, fingerprint: secretFingerprint(value)})
+	}
+	return findings
+}
+
+func secretsConfig() KubeauditConfigSecrets {
+	if configured := auditConfigSpec().Secrets; configured != nil {
+		return *configured
+	}
+	return KubeauditConfigSecrets{}
+}
+
+// ignoredFingerprints returns the fingerprints of the findings the config says aren't credentials.
+func ignoredFingerprints() map[string]bool {
+	ignored := map[string]bool{}
+	for _, fingerprint := range secretsConfig().Ignore {
+		ignored[fingerprint] = true
+	}
+	return ignored
+}
+
+func checkContainerSecrets(container ContainerV1, ignored map[string]bool, result *Result) {
+	literal := func(source string, findings []secretFinding) {
+		for _, finding := range findings {
+			if ignored[finding.fingerprint] {
+				continue
+			}
+			occ := Occurrence{
+				container: container.Name,
+				id:        ErrorSecretLiteral,
+				kind:      Error,
+				message:   fmt.Sprintf("Probable %s in %s, please move it to a Secret mounted as a volume", finding.kind, source),
+				metadata:  Metadata{"Source": source, "Type": finding.kind, "Fingerprint": finding.fingerprint},
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+	}
+	for _, env := range container.Env {
+		if env.ValueFrom == nil {
+			literal("env "+env.Name, scanNamedValue(env.Name, env.Value))
+		}
+	}
+	for i, arg := range container.Command {
+		literal(fmt.Sprintf("command[%d]", i), scanForSecrets(arg))
+	}
+	for i, arg := range container.Args {
+		literal(fmt.Sprintf("args[%d]", i), scanForSecrets(arg))
+	}
+
+	// Environment variables end up in logs, crash dumps and the environment of every child process
+	var occurrences []Occurrence
+	secretEnv := func(secret, source string) {
+		occurrences = append(occurrences, Occurrence{
+			container: container.Name,
+			id:        ErrorSecretEnv,
+			kind:      Warn,
+			message:   fmt.Sprintf("Secret %s is consumed through %s, which leaks into logs and child processes. Please mount it as a volume", secret, source),
+			metadata:  Metadata{"Secret": secret, "Source": source},
+		})
+	}
+	for _, env := range container.Env {
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+			secretEnv(env.ValueFrom.SecretKeyRef.Name, "env "+env.Name)
+		}
+	}
+	for _, envFrom := range container.EnvFrom {
+		if envFrom.SecretRef != nil {
+			secretEnv(envFrom.SecretRef.Name, "envFrom")
+		}
+	}
+
+	labelExists, reason := getContainerOverrideLabelReason(result, container, "allow-secret-env")
+	switch {
+	case !labelExists:
+		result.Occurrences = append(result.Occurrences, occurrences...)
+	case len(occurrences) == 0:
+		occ := Occurrence{
+			container: container.Name,
+			id:        ErrorMisconfiguredKubeauditAllow,
+			kind:      Warn,
+			message:   "Allowed Secrets in environment variables, but none is consumed through them",
+			metadata:  Metadata{"Reason": prettifyReason(reason)},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	default:
+		for _, occ := range occurrences {
+			occ.id = ErrorSecretEnvAllowed
+			occ.message = fmt.Sprintf("Allowed Secret %s consumed through %s", occ.metadata["Secret"], occ.metadata["Source"])
+			occ.metadata["Reason"] = prettifyReason(reason)
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+	}
+}
+
+func checkConfigMapSecrets(configMap *ConfigMapV1, ignored map[string]bool, result *Result) {
+	var keys []string
+	for key := range configMap.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, finding := range scanNamedValue(key, configMap.Data[key]) {
+			if ignored[finding.fingerprint] {
+				continue
+			}
+			occ := Occurrence{
+				id:       ErrorSecretInConfigMap,
+				kind:     Error,
+				message:  fmt.Sprintf("Probable %s in key %s of the ConfigMap, please move it to a Secret", finding.kind, key),
+				metadata: Metadata{"Key": key, "Type": finding.kind, "Fingerprint": finding.fingerprint},
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+	}
+}
+
+func auditSecrets(resource Resource) (results []Result) {
+	ignored := ignoredFingerprints()
+	if configMap, ok := resource.(*ConfigMapV1); ok {
+		result, err, warn := newResultFromResource(resource)
+		if warn != nil {
+			log.Warn(warn)
+			return
+		}
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		checkConfigMapSecrets(configMap, ignored, result)
+		if len(result.Occurrences) > 0 {
+			results = append(results, *result)
+		}
+		return
+	}
+
+	podSpec, ok := podSpecOf(resource)
+	if !ok {
+		return
+	}
+	for _, container := range podContainers(podSpec) {
+		result, err, warn := newResultFromResource(resource)
+		if warn != nil {
+			log.Warn(warn)
+			return
+		}
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		checkContainerSecrets(container, ignored, result)
+		if len(result.Occurrences) > 0 {
+			results = append(results, *result)
+		}
+	}
+	return
+}
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Audit containers and ConfigMaps for leaked credentials",
+	Long: `This command scans the env values, command and args of containers, init
+containers included, and the data of ConfigMaps for probable credentials: known
+token formats such as AWS access keys, private keys, kubeconfigs with
+credentials, long strings of random characters and values of variables named
+like credentials.
+
+An ERROR is generated for every probable credential. Credentials are never
+printed, only their fingerprint, which can be added to spec.secrets.ignore of
+the config to ignore findings which aren't credentials. Fingerprints are keyed
+with spec.secrets.fingerprintKey, and change on every run when it isn't set.
+
+A WARN is generated when a container consumes a Secret through environment
+variables, with env valueFrom secretKeyRef or envFrom secretRef, instead of
+mounting it as a volume.
+
+Example usage:
+kubeaudit secrets`,
+	PreRun: func(*cobra.Command, []string) { auditsConfigMaps = true },
+	Run:    runAudit(auditSecrets),
+}
+
+func init() {
+	RootCmd.AddCommand(secretsCmd)
+}
@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// auditsServices is set by the commands which audit Services and Ingresses so that they're only read from the
+// cluster when needed. The other audits then don't require permission to list them.
+var auditsServices bool
+
+// sourceRangesAnnotation is the annotation Services could set their loadBalancerSourceRanges with before the field.
+const sourceRangesAnnotation = "service.beta.kubernetes.io/load-balancer-source-ranges"
+
+// internalLoadBalancerAnnotations are the annotations with which cloud providers create load balancers only reachable
+// from the network of the cluster, and the values they take. An empty value matches any value other than false.
+var internalLoadBalancerAnnotations = map[string]string{
+	"service.beta.kubernetes.io/aws-load-balancer-internal":       "",
+	"service.beta.kubernetes.io/aws-load-balancer-scheme":         "internal",
+	"service.beta.kubernetes.io/azure-load-balancer-internal":     "true",
+	"service.beta.kubernetes.io/openstack-internal-load-balancer": "true",
+	"cloud.google.com/load-balancer-type":                         "internal",
+	"networking.gke.io/load-balancer-type":                        "internal",
+}
+
+// ingressSnippetAnnotations are the annotations of ingress controllers which inject raw configuration, which lets
+// whoever can edit an Ingress run code in the controller and read its credentials, such as CVE-2021-25742.
+var ingressSnippetAnnotations = map[string]bool{
+	"nginx.ingress.kubernetes.io/configuration-snippet": true,
+	"nginx.ingress.kubernetes.io/server-snippet":        true,
+	"nginx.ingress.kubernetes.io/auth-snippet":          true,
+	"nginx.ingress.kubernetes.io/stream-snippet":        true,
+	"nginx.ingress.kubernetes.io/modsecurity-snippet":   true,
+	"nginx.org/server-snippets":                         true,
+	"nginx.org/location-snippets":                       true,
+	"haproxy.org/backend-config-snippet":                true,
+	"haproxy.org/frontend-config-snippet":               true,
+}
+
+// isInternalLoadBalancer returns true if the Service asks its cloud provider for a load balancer which is only
+// reachable from the network of the cluster.
+func isInternalLoadBalancer(service *ServiceV1) bool {
+	for annotation, internal := range internalLoadBalancerAnnotations {
+		value, ok := service.Annotations[annotation]
+		if !ok {
+			continue
+		}
+		if internal == "" && !strings.EqualFold(value, "false") || internal != "" && strings.EqualFold(value, internal) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadBalancerSourceRanges returns the addresses a load balancer accepts traffic from, which are all of them when it
+// returns nil.
+func loadBalancerSourceRanges(service *ServiceV1) []string {
+	ranges := service.Spec.LoadBalancerSourceRanges
+	if len(ranges) == 0 && service.Annotations[sourceRangesAnnotation] != "" {
+		for _, sourceRange := range strings.Split(service.Annotations[sourceRangesAnnotation], ",") {
+			ranges = append(ranges, strings.TrimSpace(sourceRange))
+		}
+	}
+	for _, sourceRange := range ranges {
+		if sourceRange == "0.0.0.0/0" || sourceRange == "::/0" {
+			return nil
+		}
+	}
+	return ranges
+}
+
+// servicePorts returns the ports of a Service, or the ports it opens on every node when they're set.
+func servicePorts(service *ServiceV1, nodePorts bool) string {
+	var ports []string
+	for _, port := range service.Spec.Ports {
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = apiv1.ProtocolTCP
+		}
+		number := port.Port
+		if nodePorts && port.NodePort != 0 {
+			number = port.NodePort
+		}
+		ports = append(ports, fmt.Sprintf("%d/%s", number, protocol))
+	}
+	return strings.Join(ports, ", ")
+}
+
+// isExternallyExposed returns true if the Service is reachable from outside of the cluster through its nodes or a
+// load balancer.
+func isExternallyExposed(service *ServiceV1) bool {
+	switch service.Spec.Type {
+	case apiv1.ServiceTypeNodePort:
+		return true
+	case apiv1.ServiceTypeLoadBalancer:
+		return !isInternalLoadBalancer(service)
+	}
+	return false
+}
+
+// exposureOccurrences returns the occurrences of a Service reachable from outside of the cluster by anyone.
+func exposureOccurrences(service *ServiceV1) (occurrences []Occurrence) {
+	switch {
+	case service.Spec.Type == apiv1.ServiceTypeNodePort:
+		occurrences = append(occurrences, Occurrence{
+			id:       ErrorServiceNodePort,
+			kind:     Warn,
+			message:  "NodePort Service is reachable on every node of the cluster by anyone who can reach the nodes. Please use a LoadBalancer with loadBalancerSourceRanges or an Ingress",
+			metadata: Metadata{"Ports": servicePorts(service, true)},
+		})
+	case service.Spec.Type == apiv1.ServiceTypeLoadBalancer && !isInternalLoadBalancer(service) && loadBalancerSourceRanges(service) == nil:
+		occurrences = append(occurrences, Occurrence{
+			id:       ErrorServiceLoadBalancerSourceRangesNil,
+			kind:     Error,
+			message:  "LoadBalancer Service accepts traffic from any address. Please set loadBalancerSourceRanges",
+			metadata: Metadata{"Ports": servicePorts(service, false)},
+		})
+	}
+	return occurrences
+}
+
+func checkServiceExposure(service *ServiceV1, result *Result) {
+	occurrences := exposureOccurrences(service)
+	labelExists, reason := getServiceOverrideLabelReason(result, "allow-public-exposure")
+	switch {
+	case !labelExists:
+		result.Occurrences = append(result.Occurrences, occurrences...)
+	case len(occurrences) == 0:
+		occ := Occurrence{
+			id:       ErrorMisconfiguredKubeauditAllow,
+			kind:     Warn,
+			message:  "Allowed public exposure, but the Service isn't reachable by anyone outside of the cluster",
+			metadata: Metadata{"Reason": prettifyReason(reason)},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	default:
+		for _, occ := range occurrences {
+			occ.id = ErrorServicePublicExposureAllowed
+			occ.kind = Warn
+			occ.message = "Allowed public exposure: " + occ.message
+			occ.metadata["Reason"] = prettifyReason(reason)
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+	}
+}
+
+func checkService(service *ServiceV1, result *Result) {
+	checkServiceExposure(service, result)
+
+	// Any user who can create Services can use externalIPs to intercept traffic to those addresses, CVE-2020-8554
+	if len(service.Spec.ExternalIPs) > 0 {
+		occ := Occurrence{
+			id:       ErrorServiceExternalIPs,
+			kind:     Warn,
+			message:  "Service sets externalIPs, which takes over the traffic of the cluster to those addresses. Please use a LoadBalancer or make sure the addresses are routed to the nodes",
+			metadata: Metadata{"ExternalIPs": strings.Join(service.Spec.ExternalIPs, ", ")},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+
+	if isExternallyExposed(service) && service.Spec.ExternalTrafficPolicy != apiv1.ServiceExternalTrafficPolicyTypeLocal {
+		occ := Occurrence{
+			id:       ErrorServiceExternalTrafficPolicyCluster,
+			kind:     Warn,
+			message:  "Service forwards external traffic between nodes, which replaces the address of clients so that the pods and their NetworkPolicies can't tell who connects. Please set externalTrafficPolicy to Local",
+			metadata: Metadata{"ExternalTrafficPolicy": string(apiv1.ServiceExternalTrafficPolicyTypeCluster)},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+
+	// A Service without a selector has its endpoints managed by hand
+	if len(service.Spec.Selector) > 0 {
+		selector := labels.SelectorFromSet(service.Spec.Selector)
+		var hostNetwork []string
+		for _, workload := range scanWorkloads.inNamespace(result.Cluster, service.Namespace) {
+			if workload.podSpec.HostNetwork && selector.Matches(labels.Set(workload.labels)) {
+				hostNetwork = append(hostNetwork, workload.String())
+			}
+		}
+		if len(hostNetwork) > 0 {
+			occ := Occurrence{
+				id:       ErrorServiceHostNetwork,
+				kind:     Error,
+				message:  "Service exposes pods using the network of their node, which are also reachable on every address of the node. Please set hostNetwork to false",
+				metadata: Metadata{"Workloads": strings.Join(hostNetwork, ", ")},
+			}
+			result.Occurrences = append(result.Occurrences, occ)
+		}
+	}
+}
+
+// ingressRules is what the ingress checks need from Ingresses of every API version.
+type ingressRules struct {
+	hosts          []string
+	tls            [][]string
+	defaultBackend bool
+}
+
+func ingressRulesOf(resource Resource) (rules ingressRules, ok bool) {
+	switch kubeType := resource.(type) {
+	case *IngressV1Beta1:
+		for _, rule := range kubeType.Spec.Rules {
+			rules.hosts = append(rules.hosts, rule.Host)
+		}
+		for _, tls := range kubeType.Spec.TLS {
+			rules.tls = append(rules.tls, tls.Hosts)
+		}
+		rules.defaultBackend = kubeType.Spec.Backend != nil
+		return rules, true
+	case *IngressExtensionsV1Beta1:
+		for _, rule := range kubeType.Spec.Rules {
+			rules.hosts = append(rules.hosts, rule.Host)
+		}
+		for _, tls := range kubeType.Spec.TLS {
+			rules.tls = append(rules.tls, tls.Hosts)
+		}
+		rules.defaultBackend = kubeType.Spec.Backend != nil
+		return rules, true
+	}
+	return rules, false
+}
+
+// tlsCovers returns true if a TLS host, which may be a wildcard such as *.example.com, is valid for the host. TLS
+// sections without hosts use the default certificate of the controller for every host.
+func tlsCovers(tls [][]string, host string) bool {
+	for _, tlsHosts := range tls {
+		if len(tlsHosts) == 0 {
+			return true
+		}
+		for _, tlsHost := range tlsHosts {
+			if tlsHost == host {
+				return true
+			}
+			if strings.HasPrefix(tlsHost, "*.") && !strings.HasPrefix(host, "*.") {
+				if i := strings.Index(host, "."); i >= 0 && host[i:] == tlsHost[1:] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func checkIngress(resource Resource, rules ingressRules, result *Result) {
+	var annotations []string
+	meta, _ := resource.(metav1.Object)
+	for annotation := range meta.GetAnnotations() {
+		if ingressSnippetAnnotations[annotation] {
+			annotations = append(annotations, annotation)
+		}
+	}
+	sort.Strings(annotations)
+	for _, annotation := range annotations {
+		occ := Occurrence{
+			id:       ErrorIngressSnippetAnnotation,
+			kind:     Error,
+			message:  fmt.Sprintf("Ingress injects raw configuration into its controller with %s, which lets whoever can edit Ingresses read the secrets of the controller. Please remove it and disable snippets in the controller", annotation),
+			metadata: Metadata{"Annotation": annotation},
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+
+	if len(rules.tls) == 0 && (len(rules.hosts) > 0 || rules.defaultBackend) {
+		occ := Occurrence{
+			id:      ErrorIngressTLSNil,
+			kind:    Warn,
+			message: "Ingress has no TLS, its hosts are served over plain HTTP. Please set tls",
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	} else {
+		for _, host := range rules.hosts {
+			if host != "" && !tlsCovers(rules.tls, host) {
+				occ := Occurrence{
+					id:       ErrorIngressTLSNil,
+					kind:     Warn,
+					message:  "Host isn't listed in the tls of the Ingress, it's served over plain HTTP or with the wrong certificate. Please add it",
+					metadata: Metadata{"Host": host},
+				}
+				result.Occurrences = append(result.Occurrences, occ)
+			}
+		}
+	}
+
+	hosts := rules.hosts
+	if rules.defaultBackend {
+		hosts = append(hosts, "")
+	}
+	for _, host := range hosts {
+		if host != "" && !strings.HasPrefix(host, "*") {
+			continue
+		}
+		occ := Occurrence{
+			id:       ErrorIngressWildcardHost,
+			kind:     Warn,
+			message:  "Ingress serves every host name, so the application answers any name pointed at the controller. Please list its hosts",
+			metadata: Metadata{"Host": "*"},
+		}
+		if host != "" {
+			occ.message = fmt.Sprintf("Ingress serves every host name matching %s. Please list the hosts of the application", host)
+			occ.metadata["Host"] = host
+		}
+		result.Occurrences = append(result.Occurrences, occ)
+	}
+}
+
+func auditServices(resource Resource) (results []Result) {
+	service, isService := resource.(*ServiceV1)
+	rules, isIngress := ingressRulesOf(resource)
+	if !isService && !isIngress {
+		return
+	}
+	result, err, warn := newResultFromResource(resource)
+	if warn != nil {
+		log.Warn(warn)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if isService {
+		checkService(service, result)
+	} else {
+		checkIngress(resource, rules, result)
+	}
+	if len(result.Occurrences) > 0 {
+		results = append(results, *result)
+	}
+	return
+}
+
+var servicesCmd = &cobra.Command{
+	Use:   "services",
+	Short: "Audit what Services and Ingresses expose outside of the cluster",
+	Long: `This command checks what Services and Ingresses make reachable from outside
+of the cluster.
+
+An ERROR is generated when a public LoadBalancer Service doesn't restrict the
+addresses it accepts traffic from with loadBalancerSourceRanges, when a Service
+exposes pods using hostNetwork and when an Ingress injects raw configuration
+into its controller with a snippet annotation.
+
+A WARN is generated for NodePort Services, Services setting externalIPs,
+exposed Services with externalTrafficPolicy Cluster, which hides the address of
+clients, Ingresses serving hosts without TLS and Ingresses serving every host
+name or every name of a domain.
+
+Example usage:
+kubeaudit services`,
+	PreRun: func(*cobra.Command, []string) { auditsServices = true },
+	Run:    runAudit(auditServices),
+}
+
+func init() {
+	RootCmd.AddCommand(servicesCmd)
+}
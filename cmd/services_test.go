@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// byResource indexes the occurrences by Service or Ingress.
+func byResource(result Result, occ Occurrence) string {
+	return result.KubeType + "/" + result.Name
+}
+
+func TestServicesV1(t *testing.T) {
+	results := runAuditTest(t, "services_v1.yml", auditServices, []int{ErrorServiceNodePort, ErrorServiceLoadBalancerSourceRangesNil,
+		ErrorServicePublicExposureAllowed, ErrorMisconfiguredKubeauditAllow, ErrorServiceExternalIPs,
+		ErrorServiceExternalTrafficPolicyCluster, ErrorServiceHostNetwork, ErrorIngressTLSNil, ErrorIngressWildcardHost,
+		ErrorIngressSnippetAnnotation})
+	occurrences := occurrenceIDs(indexOccurrences(results, byResource))
+	assert.Equal(t, []int{ErrorServiceNodePort, ErrorServiceExternalTrafficPolicyCluster}, occurrences["service/nodeport"])
+	assert.Equal(t, []int{ErrorServiceLoadBalancerSourceRangesNil}, occurrences["service/lb-open"])
+	assert.Equal(t, []int{ErrorServiceLoadBalancerSourceRangesNil}, occurrences["service/lb-any-address"])
+	assert.NotContains(t, occurrences, "service/lb-restricted")
+	assert.NotContains(t, occurrences, "service/lb-internal")
+	assert.Equal(t, []int{ErrorServicePublicExposureAllowed}, occurrences["service/lb-allowed"])
+	assert.Equal(t, []int{ErrorMisconfiguredKubeauditAllow}, occurrences["service/clusterip-allowed"])
+	assert.Equal(t, []int{ErrorServiceExternalIPs}, occurrences["service/external-ips"])
+	assert.Equal(t, []int{ErrorServiceHostNetwork}, occurrences["service/agent"])
+	assert.Equal(t, []int{ErrorIngressTLSNil}, occurrences["ingress/no-tls"])
+	assert.Equal(t, []int{ErrorIngressTLSNil}, occurrences["ingress/partial-tls"])
+	assert.Equal(t, []int{ErrorIngressWildcardHost}, occurrences["ingress/wildcard"])
+	assert.Equal(t, []int{ErrorIngressWildcardHost}, occurrences["ingress/catch-all"])
+	assert.Equal(t, []int{ErrorIngressSnippetAnnotation}, occurrences["ingress/snippet"])
+
+	for _, result := range results {
+		for _, occ := range result.Occurrences {
+			switch {
+			case occ.id == ErrorServiceNodePort:
+				assert.Equal(t, "30080/TCP", occ.metadata["Ports"])
+			case occ.id == ErrorServiceHostNetwork:
+				assert.Equal(t, "daemonSet/agent", occ.metadata["Workloads"])
+			case occ.id == ErrorIngressTLSNil && result.Name == "partial-tls":
+				assert.Equal(t, "shop.example.org", occ.metadata["Host"])
+			case occ.id == ErrorIngressSnippetAnnotation:
+				assert.Equal(t, "nginx.ingress.kubernetes.io/configuration-snippet", occ.metadata["Annotation"])
+			}
+		}
+	}
+}
+
+func TestTLSCovers(t *testing.T) {
+	tls := [][]string{{"*.example.com", "example.org"}}
+	assert.True(t, tlsCovers(tls, "shop.example.com"))
+	assert.True(t, tlsCovers(tls, "example.org"))
+	assert.False(t, tlsCovers(tls, "example.com"))
+	assert.False(t, tlsCovers(tls, "a.shop.example.com"))
+	assert.True(t, tlsCovers([][]string{nil}, "anything.example.net"))
+}
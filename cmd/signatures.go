@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// cosignSignatureAnnotation is the annotation of the layers of a cosign signature manifest holding the base64 encoded
+// signature of the layer, which is the simple signing payload naming the signed image.
+const cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+type sigFlags struct {
+	registry *registryClient
+	policies []signaturePolicy
+}
+
+var sigConfig sigFlags
+
+// signaturePolicy requires the images matching the pattern to be signed with every key.
+type signaturePolicy struct {
+	images string
+	keys   []signatureKey
+}
+
+// signatureKey is a public key along with the name it's reported with, which is its file name.
+type signatureKey struct {
+	name string
+	key  crypto.PublicKey
+}
+
+// cosignManifest is the part of the manifest of a cosign signature needed to verify it.
+type cosignManifest struct {
+	Layers []struct {
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// cosignPayload is the simple signing payload cosign signs.
+type cosignPayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// signaturePolicies returns the signature policies of the config.
+func signaturePolicies() []*KubeauditConfigSignaturePolicy {
+	return auditConfigSpec().Signatures
+}
+
+// loadPolicy loads the keys of the signature policies of the config and the credentials used to fetch signatures.
+func (sigs *sigFlags) loadPolicy() error {
+	sigs.policies = nil
+	for _, configured := range signaturePolicies() {
+		if configured == nil {
+			continue
+		}
+		if configured.Images == "" || len(configured.Keys) == 0 {
+			return fmt.Errorf("signature policy %q must have images and keys", configured.Images)
+		}
+		policy := signaturePolicy{images: configured.Images}
+		for _, value := range configured.Keys {
+			key, err := loadPublicKey(value)
+			if err != nil {
+				return err
+			}
+			policy.keys = append(policy.keys, key)
+		}
+		sigs.policies = append(sigs.policies, policy)
+	}
+	if len(sigs.policies) == 0 {
+		return fmt.Errorf("no signature policy in the config, add signatures to the config given with -k")
+	}
+	config, err := loadDockerConfig(rootConfig.dockerConfig)
+	if err != nil {
+		return err
+	}
+	sigs.registry = newRegistryClient(nil, config)
+	return nil
+}
+
+// loadPublicKey loads a PEM encoded public key from a file, or from the value itself if it's a PEM block.
+func loadPublicKey(value string) (signatureKey, error) {
+	name := value
+	data := []byte(value)
+	if !strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		var err error
+		if data, err = ioutil.ReadFile(value); err != nil {
+			return signatureKey{}, err
+		}
+	} else {
+		hash := sha256.Sum256(data)
+		name = fmt.Sprintf("key %x", hash[:6])
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return signatureKey{}, fmt.Errorf("public key %s isn't PEM encoded", name)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return signatureKey{}, fmt.Errorf("parsing public key %s: %v", name, err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return signatureKey{name: name, key: key}, nil
+	}
+	return signatureKey{}, fmt.Errorf("public key %s is of unsupported type %T", name, key)
+}
+
+// verifySignature returns true if the signature of the payload was made with the key. ECDSA and RSA signatures are of
+// the SHA-256 hash of the payload, the way cosign signs.
+func verifySignature(key crypto.PublicKey, payload, signature []byte) bool {
+	hash := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(k, hash[:], signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], signature) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, payload, signature)
+	}
+	return false
+}
+
+// requiredKeys returns the keys of every policy matching the image.
+func (sigs sigFlags) requiredKeys(ref ImageReference) (keys []signatureKey) {
+	seen := map[string]bool{}
+	for _, policy := range sigs.policies {
+		matches, err := imageMatchesPattern(ref, policy.images)
+		if err != nil {
+			log.Warnf("Ignoring invalid signature policy %q: %v", policy.images, err)
+			continue
+		}
+		if !matches {
+			continue
+		}
+		for _, key := range policy.keys {
+			if !seen[key.name] {
+				seen[key.name] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// verifyImage checks the cosign signatures of the image with the digest. It returns how many signatures the image has
+// and the names of the keys which made a valid signature of it.
+func (sigs sigFlags) verifyImage(ref ImageReference, digest string, keys []signatureKey) (signatures int, verified map[string]bool, err error) {
+	verified = map[string]bool{}
+	// cosign stores the signatures of an image in the same repository, under a tag named after its digest
+	data, err := sigs.registry.fetchManifest(ref, strings.Replace(digest, ":", "-", 1)+".sig")
+	if err == errRegistryNotFound {
+		return 0, verified, nil
+	}
+	if err != nil {
+		return 0, verified, err
+	}
+	var manifest cosignManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return 0, verified, fmt.Errorf("parsing the signature manifest of %s: %v", ref.Name(), err)
+	}
+
+	for _, layer := range manifest.Layers {
+		encoded, ok := layer.Annotations[cosignSignatureAnnotation]
+		if !ok {
+			continue
+		}
+		signatures++
+		signature, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		payload, err := sigs.registry.fetchBlob(ref, layer.Digest)
+		if err != nil {
+			return signatures, verified, err
+		}
+		// A valid signature of another image doesn't count
+		var signed cosignPayload
+		if err := json.Unmarshal(payload, &signed); err != nil || signed.Critical.Image.DockerManifestDigest != digest {
+			continue
+		}
+		for _, key := range keys {
+			if verifySignature(key.key, payload, signature) {
+				verified[key.name] = true
+			}
+		}
+	}
+	return signatures, verified, nil
+}
+
+// imageDigest returns the digest of the image the container runs: the one it's pinned to, the one the pod reports in
+// its status, or the one its tag points to in the registry.
+func (sigs sigFlags) imageDigest(ref ImageReference, runningDigest string) (string, error) {
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	if runningDigest != "" {
+		return runningDigest, nil
+	}
+	return sigs.registry.resolve(ref)
+}
+
+func checkImageSignatures(container ContainerV1, runningDigest string, sigs sigFlags, result *Result) {
+	ref, err := parseImageReference(container.Image)
+	if err != nil {
+		// Invalid image references are reported by the image audit
+		return
+	}
+	keys := sigs.requiredKeys(ref)
+	if len(keys) == 0 {
+		return
+	}
+	var keyNames []string
+	for _, key := range keys {
+		keyNames = append(keyNames, key.name)
+	}
+	metadata := Metadata{"Image": container.Image, "Keys": strings.Join(keyNames, ", ")}
+
+	digest, err := sigs.imageDigest(ref, runningDigest)
+	var signatures int
+	var verified map[string]bool
+	if err == nil {
+		metadata["Digest"] = digest
+		signatures, verified, err = sigs.verifyImage(ref, digest, keys)
+	}
+	occ := Occurrence{container: container.Name, metadata: metadata}
+	switch {
+	case err != nil:
+		occ.id, occ.kind = ErrorImageSignatureUnverified, Warn
+		occ.message = "Unable to verify the signature of the image: " + err.Error()
+	case signatures == 0:
+		occ.id, occ.kind = ErrorImageUnsigned, Error
+		occ.message = "Image is not signed, please sign it with cosign"
+	case len(verified) < len(keys):
+		var missing []string
+		for _, name := range keyNames {
+			if !verified[name] {
+				missing = append(missing, name)
+			}
+		}
+		sort.Strings(missing)
+		metadata["MissingKeys"] = strings.Join(missing, ", ")
+		occ.id, occ.kind = ErrorImageSignatureInvalid, Error
+		occ.message = "Image has no valid signature made with the required keys"
+	default:
+		occ.id, occ.kind = InfoImageSignatureVerified, Info
+		occ.message = "Image signature was verified"
+	}
+	result.Occurrences = append(result.Occurrences, occ)
+}
+
+func auditImageSignatures(sigs sigFlags, resource Resource) (results []Result) {
+	if sigs.registry == nil {
+		return
+	}
+	podSpec, ok := podSpecOf(resource)
+	if !ok {
+		return
+	}
+	runningDigests := runningImageDigests(resource)
+	for _, container := range podContainers(podSpec) {
+		result, err, warn := newResultFromResource(resource)
+		if warn != nil {
+			log.Warn(warn)
+			return
+		}
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		checkImageSignatures(container, runningDigests[container.Name], sigs, result)
+		if len(result.Occurrences) > 0 {
+			results = append(results, *result)
+		}
+	}
+	return
+}
+
+var signaturesCmd = &cobra.Command{
+	Use:   "signatures",
+	Short: "Audit container images for valid cosign signatures",
+	Long: `This command verifies the cosign signatures of the image of every container
+against the public keys of the signature policies in the config file. A policy
+maps images, such as registry.local:5000/* or gcr.io/project/app, to the keys
+which must have signed them:
+
+spec:
+  signatures:
+    - images: registry.local:5000/*
+      keys: [/path/to/cosign.pub]
+
+The signatures are fetched from the registry, using the credentials of the
+docker config file. Images are verified by digest: the one they are pinned to,
+the one a running pod reports, or the one their tag points to.
+
+An ERROR is generated for images without a signature, and for images without a
+valid signature made with each of the keys. A WARN is generated when the
+signatures can't be fetched.
+
+Example usage:
+kubeaudit signatures -k config.yaml`,
+	Run: runAudit(auditImageSignatures),
+}
+
+func init() {
+	RootCmd.AddCommand(signaturesCmd)
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSignatureRegistry is a registry serving the images and cosign signatures added to it.
+type testSignatureRegistry struct {
+	host    string
+	content map[string][]byte
+}
+
+func (registry *testSignatureRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, ok := registry.content[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if strings.Contains(r.URL.Path, "/manifests/") {
+		w.Header().Set("Docker-Content-Digest", fmt.Sprintf("sha256:%x", sha256.Sum256(data)))
+	}
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+// addImage adds an image with the tag and returns its digest.
+func (registry *testSignatureRegistry) addImage(repository, tag string) string {
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"name":%q}}`, repository))
+	registry.content["/v2/"+repository+"/manifests/"+tag] = manifest
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+}
+
+// sign adds a cosign signature of the image with the digest to the repository. The payload names signedDigest, which
+// is the digest of the image unless testing a signature of another image.
+func (registry *testSignatureRegistry) sign(t *testing.T, repository, digest, signedDigest string, key *ecdsa.PrivateKey) {
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s/%s"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`,
+		registry.host, repository, signedDigest))
+	hash := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	require.NoError(t, err)
+	payloadDigest := fmt.Sprintf("sha256:%x", hash)
+	registry.content["/v2/"+repository+"/blobs/"+payloadDigest] = payload
+
+	manifest, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"layers": []map[string]interface{}{{
+			"mediaType":   "application/vnd.dev.cosign.simplesigning.v1+json",
+			"digest":      payloadDigest,
+			"size":        len(payload),
+			"annotations": map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(signature)},
+		}},
+	})
+	require.NoError(t, err)
+	registry.content["/v2/"+repository+"/manifests/"+strings.Replace(digest, ":", "-", 1)+".sig"] = manifest
+}
+
+func writeTestPublicKey(t *testing.T, dir string, name string, key *ecdsa.PrivateKey) string {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	filename := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+	return filename
+}
+
+func TestImageSignaturesV1(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeaudit_signatures")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyFile := writeTestPublicKey(t, dir, "cosign.pub", key)
+
+	registry := &testSignatureRegistry{content: map[string][]byte{}}
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	registry.host = strings.TrimPrefix(server.URL, "http://")
+
+	digest := registry.addImage("team/signed", "1.0")
+	registry.sign(t, "team/signed", digest, digest, key)
+	registry.addImage("team/unsigned", "1.0")
+	otherKeyDigest := registry.addImage("team/other-key", "1.0")
+	registry.sign(t, "team/other-key", otherKeyDigest, otherKeyDigest, otherKey)
+	otherImageDigest := registry.addImage("team/other-image", "1.0")
+	registry.sign(t, "team/other-image", otherImageDigest, digest, key)
+
+	config := filepath.Join(dir, "config.yml")
+	require.NoError(t, ioutil.WriteFile(config, []byte(fmt.Sprintf(`apiVersion: v1
+kind: kubeauditConfig
+audit: true
+spec:
+  signatures:
+    - images: %s/team/*
+      keys: [%s]
+`, registry.host, keyFile)), 0644))
+	rootConfig.auditConfig = config
+	defer func() { rootConfig.auditConfig = "" }()
+
+	data, err := ioutil.ReadFile("../fixtures/image_signatures_v1.yml")
+	require.NoError(t, err)
+	manifest := strings.NewReplacer("REGISTRY", registry.host, "DIGEST", digest).Replace(string(data))
+	manifestFile := filepath.Join(dir, "image_signatures_v1.yml")
+	require.NoError(t, ioutil.WriteFile(manifestFile, []byte(manifest), 0644))
+	resources, err := getKubeResourcesManifest(manifestFile)
+	require.NoError(t, err)
+
+	sigs := sigFlags{}
+	require.NoError(t, sigs.loadPolicy())
+	occurrences := map[string]Occurrence{}
+	for _, result := range auditImageSignatures(sigs, resources[0]) {
+		for _, occ := range result.Occurrences {
+			occurrences[occ.container] = occ
+		}
+	}
+
+	assert.Equal(t, InfoImageSignatureVerified, occurrences["signed"].id)
+	assert.Equal(t, digest, occurrences["signed"].metadata["Digest"])
+	assert.Equal(t, InfoImageSignatureVerified, occurrences["pinned"].id)
+	// The image ID the pod reports without a repository isn't the digest of the manifest, so the tag is resolved
+	assert.Equal(t, InfoImageSignatureVerified, occurrences["bare-id"].id)
+	assert.Equal(t, digest, occurrences["bare-id"].metadata["Digest"])
+	assert.Equal(t, ErrorImageUnsigned, occurrences["unsigned"].id)
+	assert.Equal(t, ErrorImageUnsigned, occurrences["init-unsigned"].id)
+	assert.Equal(t, ErrorImageSignatureInvalid, occurrences["other-key"].id)
+	assert.Equal(t, keyFile, occurrences["other-key"].metadata["MissingKeys"])
+	// A signature of another image copied over isn't valid
+	assert.Equal(t, ErrorImageSignatureInvalid, occurrences["other-image"].id)
+	assert.Equal(t, ErrorImageSignatureUnverified, occurrences["missing"].id)
+	assert.NotContains(t, occurrences, "no-policy")
+}
+
+func TestLoadSignaturePolicy(t *testing.T) {
+	sigs := sigFlags{}
+	assert.Error(t, sigs.loadPolicy())
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	inline := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	loaded, err := loadPublicKey(inline)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(loaded.name, "key "))
+
+	payload := []byte("payload")
+	hash := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	require.NoError(t, err)
+	assert.True(t, verifySignature(loaded.key, payload, signature))
+	assert.False(t, verifySignature(loaded.key, []byte("other payload"), signature))
+
+	_, err = loadPublicKey("../fixtures/missing.pub")
+	assert.Error(t, err)
+	_, err = loadPublicKey("-----BEGIN PUBLIC KEY-----\nnot a key\n-----END PUBLIC KEY-----\n")
+	assert.Error(t, err)
+}
+
+func TestImageMatchesPattern(t *testing.T) {
+	ref, err := parseImageReference("registry.local:5000/team/app:1.0")
+	require.NoError(t, err)
+	for pattern, matches := range map[string]bool{
+		"*":                            true,
+		"registry.local:5000/*":        true,
+		"registry.local/*":             false,
+		"registry.local:5000/team/*":   true,
+		"registry.local:5000/team/app": true,
+		"registry.local:5000/team":     false,
+		"team/app":                     false,
+	} {
+		matched, err := imageMatchesPattern(ref, pattern)
+		assert.NoError(t, err, pattern)
+		assert.Equal(t, matches, matched, pattern)
+	}
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/kubeaudit/scheme"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+)
+
+type snapshotFlags struct {
+	output string
+}
+
+var snapshotConfig snapshotFlags
+
+// networkPolicyLister, limitRangeLister and resourceQuotaLister are only used to take snapshots, the audits list
+// these kinds namespace by namespace.
+var networkPolicyLister = kindLister{
+	kind: "NetworkPolicy", group: "networking.k8s.io", resource: "networkpolicies", namespaced: true, related: true,
+	list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+		list, err := getNetworkPolicies(ctx, clientset, namespace)
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+		return resources, err
+	},
+}
+
+var limitRangeLister = kindLister{
+	kind: "LimitRange", resource: "limitranges", namespaced: true, related: true,
+	list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+		list, err := getLimitRanges(ctx, clientset, namespace)
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+		return resources, err
+	},
+}
+
+var resourceQuotaLister = kindLister{
+	kind: "ResourceQuota", resource: "resourcequotas", namespaced: true, related: true,
+	list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+		list, err := getResourceQuotas(ctx, clientset, namespace)
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+		return resources, err
+	},
+}
+
+// snapshotEntryName returns the name of the file holding the resources of a kind in the snapshot. The resources of
+// each kubeconfig context are in a directory named after it, and those of the current context at the top level.
+func snapshotEntryName(cluster, kind string) string {
+	if cluster == "" {
+		return kind + ".json"
+	}
+	return cluster + "/" + kind + ".json"
+}
+
+// writeSnapshot writes the resources as a gzipped tarball with a file per cluster and kind. Each line of a file is a
+// resource encoded in JSON.
+func writeSnapshot(w io.Writer, resources []Resource) error {
+	files := map[string]*bytes.Buffer{}
+	for _, resource := range resources {
+		kinds, _, err := scheme.Scheme.ObjectKinds(resource)
+		if err != nil || len(kinds) == 0 {
+			return fmt.Errorf("unable to find the kind of %T: %v", resource, err)
+		}
+		// Objects returned by List calls don't have their TypeMeta set
+		resource.GetObjectKind().SetGroupVersionKind(kinds[0])
+		data, err := json.Marshal(resource)
+		if err != nil {
+			return err
+		}
+		name := snapshotEntryName(clusterNameOf(resource), kinds[0].Kind)
+		if files[name] == nil {
+			files[name] = &bytes.Buffer{}
+		}
+		files[name].Write(append(data, '\n'))
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(files[name].Len()), ModTime: time.Now()}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(files[name].Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// readSnapshot decodes every resource of a snapshot and tags it with the cluster it was taken from.
+func readSnapshot(r io.Reader) (resources []Resource, err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot is not a gzipped tarball: %v", err)
+	}
+	defer gz.Close()
+
+	decoder := scheme.Codecs.UniversalDeserializer()
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return resources, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read snapshot: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(header.Name, ".json") {
+			continue
+		}
+		cluster := ""
+		if i := strings.LastIndex(header.Name, "/"); i >= 0 {
+			cluster = header.Name[:i]
+		}
+
+		scanner := bufio.NewScanner(archive)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		var fileResources []Resource
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			obj, _, err := decoder.Decode(scanner.Bytes(), nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode line %d of %s in snapshot: %v", line, header.Name, err)
+			}
+			fileResources = append(fileResources, obj)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("unable to read %s in snapshot: %v", header.Name, err)
+		}
+		setClusterName(fileResources, cluster)
+		resources = append(resources, fileResources...)
+	}
+}
+
+// snapshotCache keeps the snapshot being audited in memory since the network policy audit reads it once per
+// namespace.
+var snapshotCache struct {
+	sync.Mutex
+	filename  string
+	resources []Resource
+}
+
+func loadSnapshot(filename string) ([]Resource, error) {
+	snapshotCache.Lock()
+	defer snapshotCache.Unlock()
+	if snapshotCache.filename == filename {
+		return snapshotCache.resources, nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	resources, err := readSnapshot(f)
+	if err != nil {
+		return nil, err
+	}
+	snapshotCache.filename, snapshotCache.resources = filename, resources
+	return resources, nil
+}
+
+// takeSnapshot lists the resources of the current context, or of each context given with --context or
+// --all-contexts, without rolling Pods up or leaving out the resources only needed for the analysis.
+func takeSnapshot(ctx context.Context) ([]Resource, error) {
+	contexts, err := kubeContexts()
+	if err != nil {
+		return nil, err
+	}
+	if len(contexts) == 0 {
+		contexts = []string{""}
+	}
+	listers := append(kubeListers(), networkPolicyLister, limitRangeLister, resourceQuotaLister)
+	var resources []Resource
+	for _, kubeContext := range contexts {
+		kube, err := kubeClientForContext(kubeContext)
+		if err != nil {
+			if kubeContext == "" {
+				return nil, err
+			}
+			scanCoverage.addGap(kubeContext, "", "", err.Error())
+			continue
+		}
+		clusterResources, err := listKubeResources(ctx, kube, kubeContext, listers)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, clusterResources...)
+	}
+	return resources, nil
+}
+
+func runSnapshot(*cobra.Command, []string) {
+	setFormatter()
+	if snapshotConfig.output == "" {
+		log.Fatal("Missing the file to write the snapshot to, use -o")
+	}
+	ctx, cancel := newAuditContext()
+	defer cancel()
+	scanCoverage.reset()
+	resources, err := takeSnapshot(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			abortIncompleteScan(err)
+		}
+		log.Fatal(err)
+	}
+
+	f, err := os.Create(snapshotConfig.output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = writeSnapshot(f, resources)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// A truncated snapshot would pass for the whole cluster
+		os.Remove(snapshotConfig.output)
+		log.Fatal(err)
+	}
+	log.WithFields(log.Fields{"File": snapshotConfig.output, "Resources": len(resources)}).Info("Snapshot written")
+	scanCoverage.Print()
+	if !scanCoverage.Complete() {
+		os.Exit(ExitCodeIncompleteScan)
+	}
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save the resources kubeaudit audits to a file to audit them offline",
+	Long: `This command saves the namespaces, workloads, network policies, limit
+ranges, resource quotas, services, ingresses, ConfigMaps, service accounts and
+RBAC resources of a cluster to a gzipped tarball. Every audit can
+then run against the snapshot with --snapshot instead of a live cluster.
+
+Snapshots are taken from the current context, or from every context given with
+--context or --all-contexts. The filters restrict what is saved.
+
+Example usage:
+kubeaudit snapshot -o cluster.tar.gz
+kubeaudit all --snapshot cluster.tar.gz`,
+	PreRun: func(*cobra.Command, []string) { auditsRBAC, auditsServices, auditsConfigMaps = true, true, true },
+	Run:    runSnapshot,
+}
+
+func init() {
+	RootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.Flags().StringVarP(&snapshotConfig.output, "output", "o", "", "File to write the snapshot to, e.g. cluster.tar.gz")
+}
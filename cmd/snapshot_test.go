@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func newSnapshotTestResources(t *testing.T, cluster string) []Resource {
+	controller := true
+	client := fakeclientset.NewSimpleClientset(
+		&NamespaceV1{ObjectMeta: ObjectMetaV1{Name: "ns1"}},
+		&DeploymentV1{ObjectMeta: ObjectMetaV1{Name: "web", Namespace: "ns1"}},
+		&ReplicaSetV1{ObjectMeta: ObjectMetaV1{Name: "web-1", Namespace: "ns1", OwnerReferences: []metav1.OwnerReference{
+			{Kind: "Deployment", Name: "web", Controller: &controller},
+		}}},
+		&PodV1{ObjectMeta: ObjectMetaV1{Name: "web-1-a", Namespace: "ns1", OwnerReferences: []metav1.OwnerReference{
+			{Kind: "ReplicaSet", Name: "web-1", Controller: &controller},
+		}}},
+		&NetworkPolicyV1{
+			ObjectMeta: ObjectMetaV1{Name: "default-deny", Namespace: "ns1"},
+			Spec:       networking.NetworkPolicySpec{PolicyTypes: []networking.PolicyType{"Ingress", "Egress"}},
+		},
+		&ServiceAccountV1{ObjectMeta: ObjectMetaV1{Name: "web", Namespace: "ns1"}},
+		&ClusterRoleV1{ObjectMeta: ObjectMetaV1{Name: "reader"}},
+	)
+	allowAccessReviews(client, func(attributes *authorizationv1.ResourceAttributes) bool { return true })
+	auditsRBAC = true
+	defer func() { auditsRBAC = false }()
+	resources, err := listKubeResources(context.Background(), client, cluster, append(kubeListers(), networkPolicyLister))
+	require.Nil(t, err)
+	return resources
+}
+
+func writeTestSnapshot(t *testing.T, resources []Resource) string {
+	f, err := ioutil.TempFile("", "kubeaudit_snapshot")
+	require.Nil(t, err)
+	defer f.Close()
+	require.Nil(t, writeSnapshot(f, resources))
+	return f.Name()
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	resources := newSnapshotTestResources(t, "")
+	assert.Len(t, resources, 7)
+
+	var buf bytes.Buffer
+	require.Nil(t, writeSnapshot(&buf, resources))
+	read, err := readSnapshot(&buf)
+	require.Nil(t, err)
+	assert.Len(t, read, len(resources))
+	kinds := map[string]int{}
+	for _, resource := range read {
+		kinds[resource.GetObjectKind().GroupVersionKind().Kind]++
+		assert.Equal(t, "", clusterNameOf(resource))
+	}
+	assert.Equal(t, map[string]int{
+		"Namespace": 1, "Deployment": 1, "ReplicaSet": 1, "Pod": 1, "NetworkPolicy": 1, "ServiceAccount": 1, "ClusterRole": 1,
+	}, kinds)
+}
+
+func TestSnapshotKeepsClusters(t *testing.T) {
+	resources := append(newSnapshotTestResources(t, "staging"), newSnapshotTestResources(t, "production")...)
+	var buf bytes.Buffer
+	require.Nil(t, writeSnapshot(&buf, resources))
+	read, err := readSnapshot(&buf)
+	require.Nil(t, err)
+	clusters := map[string]int{}
+	for _, resource := range read {
+		clusters[clusterNameOf(resource)]++
+	}
+	assert.Equal(t, map[string]int{"staging": 7, "production": 7}, clusters)
+}
+
+func TestAuditSnapshot(t *testing.T) {
+	filename := writeTestSnapshot(t, newSnapshotTestResources(t, ""))
+	defer os.Remove(filename)
+	rootConfig.snapshot = filename
+	defer func() { rootConfig.snapshot = "" }()
+	scanOwnership.reset()
+
+	resources, err := getResources(context.Background())
+	require.Nil(t, err)
+	// The Pod is rolled up into its Deployment, and the RBAC resources are only audited by the commands needing them
+	assert.Len(t, resources, 2)
+
+	results := getResults(resources, auditNetworkPolicies)
+	require.Len(t, results, 1)
+	assert.Equal(t, InfoDefaultDenyNetworkPolicyExists, results[0].Occurrences[0].id)
+}
+
+func TestReadSnapshotInvalid(t *testing.T) {
+	_, err := readSnapshot(bytes.NewBufferString("not a snapshot"))
+	assert.NotNil(t, err)
+
+	_, err = loadSnapshot(filepath.Join(path, "does_not_exist.tar.gz"))
+	assert.NotNil(t, err)
+}
+
+func TestAuditSnapshotConfigMaps(t *testing.T) {
+	configMap := &ConfigMapV1{ObjectMeta: ObjectMetaV1{Name: "app", Namespace: "ns1"}}
+	filename := writeTestSnapshot(t, append(newSnapshotTestResources(t, ""), configMap))
+	defer os.Remove(filename)
+	rootConfig.snapshot = filename
+	defer func() { rootConfig.snapshot = "" }()
+	scanOwnership.reset()
+
+	// ConfigMaps are only handed to the secrets audit
+	resources, err := getResources(context.Background())
+	require.Nil(t, err)
+	assert.Len(t, resources, 2)
+
+	auditsConfigMaps = true
+	defer func() { auditsConfigMaps = false }()
+	scanOwnership.reset()
+	resources, err = getResources(context.Background())
+	require.Nil(t, err)
+	require.Len(t, resources, 3)
+	assert.True(t, IsConfigMapType(resources[2]))
+}
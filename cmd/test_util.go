@@ -14,7 +14,6 @@ import (
 	"github.com/Shopify/kubeaudit/scheme"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
-	apiv1 "k8s.io/api/core/v1"
 	k8sRuntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
@@ -57,13 +56,16 @@ func runAuditTest(t *testing.T, file string, function interface{}, errCodes []in
 	file = filepath.Join(path, file)
 	var image imgFlags
 	var limits limitFlags
+	var vulns vulnFlags
 	switch function.(type) {
 	case (func(imgFlags, Resource) []Result):
 		if len(argStr) != 1 {
 			log.Fatal("Incorrect number of images specified")
 		}
 		image = imgFlags{img: argStr[0]}
-		image.splitImageString()
+		if err := image.parseImageFlag(); err != nil {
+			log.Fatal(err)
+		}
 	case (func(limitFlags, Resource) []Result):
 		if len(argStr) == 2 {
 			limits = limitFlags{cpuArg: argStr[0], memoryArg: argStr[1]}
@@ -73,12 +75,24 @@ func runAuditTest(t *testing.T, file string, function interface{}, errCodes []in
 			log.Fatal("Incorrect number of images specified")
 		}
 		limits.parseLimitFlags()
+	case (func(vulnFlags, Resource) []Result):
+		if len(argStr) != 1 {
+			log.Fatal("Incorrect number of report directories specified")
+		}
+		vulns = vulnFlags{reports: argStr[0]}
+		if err := vulns.loadReports(); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	resources, err := getKubeResourcesManifest(file)
 	assert.Nil(err)
 	// Set manifest for test run
 	rootConfig.manifest = file
+	scanPrivileges.reset()
+	scanPrivileges.index(resources)
+	scanWorkloads.reset()
+	scanWorkloads.index(resources)
 
 	for _, resource := range resources {
 		var currentResults []Result
@@ -89,6 +103,8 @@ func runAuditTest(t *testing.T, file string, function interface{}, errCodes []in
 			currentResults = f(image, resource)
 		case (func(limitFlags, Resource) []Result):
 			currentResults = f(limits, resource)
+		case (func(vulnFlags, Resource) []Result):
+			currentResults = f(vulns, resource)
 		default:
 			name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
 			log.Fatal("Invalid audit function provided: ", name)
@@ -111,10 +127,37 @@ func runAuditTest(t *testing.T, file string, function interface{}, errCodes []in
 	return
 }
 
+// indexOccurrences indexes the occurrences of the results by the key returned for each of them, in the reported order.
+func indexOccurrences(results []Result, key func(Result, Occurrence) string) map[string][]Occurrence {
+	occurrences := map[string][]Occurrence{}
+	for _, result := range results {
+		for _, occ := range result.Occurrences {
+			occurrences[key(result, occ)] = append(occurrences[key(result, occ)], occ)
+		}
+	}
+	return occurrences
+}
+
+// byContainer is the key of indexOccurrences for occurrences of containers.
+func byContainer(result Result, occ Occurrence) string {
+	return occ.container
+}
+
+// occurrenceIDs replaces the indexed occurrences with their ids.
+func occurrenceIDs(occurrences map[string][]Occurrence) map[string][]int {
+	ids := map[string][]int{}
+	for key, occs := range occurrences {
+		for _, occ := range occs {
+			ids[key] = append(ids[key], occ.id)
+		}
+	}
+	return ids
+}
+
 func runAuditTestInNamespace(t *testing.T, namespace string, file string, function interface{}, errCodes []int) {
-	rootConfig.namespace = namespace
+	rootConfig.namespaces = []string{namespace}
 	runAuditTest(t, file, function, errCodes)
-	rootConfig.namespace = apiv1.NamespaceAll
+	rootConfig.namespaces = nil
 }
 
 // NewUnsupportedResource returns a fake unsupported resource for testing purposes
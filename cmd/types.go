@@ -4,10 +4,13 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	appsv1beta1 "k8s.io/api/apps/v1beta1"
 	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	batchv1 "k8s.io/api/batch/v1"
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	apiv1 "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sRuntime "k8s.io/apimachinery/pkg/runtime"
 )
@@ -18,9 +21,33 @@ type CapabilitiesV1 = apiv1.Capabilities
 // CapabilityV1 is a type alias for the v1 version of the k8s API.
 type CapabilityV1 = apiv1.Capability
 
+// ClusterRoleBindingListV1 is a type alias for the v1 version of the k8s rbac API.
+type ClusterRoleBindingListV1 = rbacv1.ClusterRoleBindingList
+
+// ClusterRoleBindingV1 is a type alias for the v1 version of the k8s rbac API.
+type ClusterRoleBindingV1 = rbacv1.ClusterRoleBinding
+
+// ClusterRoleListV1 is a type alias for the v1 version of the k8s rbac API.
+type ClusterRoleListV1 = rbacv1.ClusterRoleList
+
+// ClusterRoleV1 is a type alias for the v1 version of the k8s rbac API.
+type ClusterRoleV1 = rbacv1.ClusterRole
+
+// ConfigMapListV1 is a type alias for the v1 version of the k8s API.
+type ConfigMapListV1 = apiv1.ConfigMapList
+
+// ConfigMapV1 is a type alias for the v1 version of the k8s API.
+type ConfigMapV1 = apiv1.ConfigMap
+
 // ContainerV1 is a type alias for the v1 version of the k8s API.
 type ContainerV1 = apiv1.Container
 
+// ContainerStatusV1 is a type alias for the v1 version of the k8s API.
+type ContainerStatusV1 = apiv1.ContainerStatus
+
+// CronJobListV1Beta1 is a type alias for the v1beta1 version of the k8s batch API.
+type CronJobListV1Beta1 = batchv1beta1.CronJobList
+
 // CronJobV1Beta1 is a type alias for the v1beta1 version of the k8s batch API.
 type CronJobV1Beta1 = batchv1beta1.CronJob
 
@@ -51,6 +78,30 @@ type DeploymentV1Beta1 = appsv1beta1.Deployment
 // DeploymentV1Beta2 is a type alias for the v1beta2 version of the k8s apps API.
 type DeploymentV1Beta2 = appsv1beta2.Deployment
 
+// ExecActionV1 is a type alias for the v1 version of the k8s API.
+type ExecActionV1 = apiv1.ExecAction
+
+// IngressExtensionsV1Beta1 is a type alias for the v1beta1 version of the k8s extensions API.
+type IngressExtensionsV1Beta1 = extensionsv1beta1.Ingress
+
+// IngressListV1Beta1 is a type alias for the v1beta1 version of the k8s networking API.
+type IngressListV1Beta1 = networkingv1beta1.IngressList
+
+// IngressV1Beta1 is a type alias for the v1beta1 version of the k8s networking API.
+type IngressV1Beta1 = networkingv1beta1.Ingress
+
+// JobListV1 is a type alias for the v1 version of the k8s batch API.
+type JobListV1 = batchv1.JobList
+
+// JobV1 is a type alias for the v1 version of the k8s batch API.
+type JobV1 = batchv1.Job
+
+// LimitRangeListV1 is a type alias for the v1 version of the k8s API.
+type LimitRangeListV1 = apiv1.LimitRangeList
+
+// LimitRangeV1 is a type alias for the v1 version of the k8s API.
+type LimitRangeV1 = apiv1.LimitRange
+
 // ListOptionsV1 is a type alias for the v1 version of the k8s meta API.
 type ListOptionsV1 = metav1.ListOptions
 
@@ -69,6 +120,9 @@ type NetworkPolicyV1 = networkingv1.NetworkPolicy
 // ObjectMetaV1 is a type alias for the v1 version of the k8s API.
 type ObjectMetaV1 = metav1.ObjectMeta
 
+// PolicyRuleV1 is a type alias for the v1 version of the k8s rbac API.
+type PolicyRuleV1 = rbacv1.PolicyRule
+
 // PodListV1 is a type alias for the v1 version of the k8s API.
 type PodListV1 = apiv1.PodList
 
@@ -78,18 +132,78 @@ type PodSpecV1 = apiv1.PodSpec
 // PodV1 is a type alias for the v1 version of the k8s API.
 type PodV1 = apiv1.Pod
 
+// ReplicaSetListV1 is a type alias for the v1 version of the k8s apps API.
+type ReplicaSetListV1 = appsv1.ReplicaSetList
+
+// ReplicaSetV1 is a type alias for the v1 version of the k8s apps API.
+type ReplicaSetV1 = appsv1.ReplicaSet
+
 // ReplicationControllerListV1 is a type alias for the v1 version of the k8s API.
 type ReplicationControllerListV1 = apiv1.ReplicationControllerList
 
 // ReplicationControllerV1 is a type alias for the v1 version of the k8s API.
 type ReplicationControllerV1 = apiv1.ReplicationController
 
+// RoleBindingListV1 is a type alias for the v1 version of the k8s rbac API.
+type RoleBindingListV1 = rbacv1.RoleBindingList
+
+// RoleBindingV1 is a type alias for the v1 version of the k8s rbac API.
+type RoleBindingV1 = rbacv1.RoleBinding
+
+// RoleListV1 is a type alias for the v1 version of the k8s rbac API.
+type RoleListV1 = rbacv1.RoleList
+
+// RoleRefV1 is a type alias for the v1 version of the k8s rbac API.
+type RoleRefV1 = rbacv1.RoleRef
+
+// RoleV1 is a type alias for the v1 version of the k8s rbac API.
+type RoleV1 = rbacv1.Role
+
+// ProbeV1 is a type alias for the v1 version of the k8s API.
+type ProbeV1 = apiv1.Probe
+
+// PullNever is a type alias for the v1 version of the k8s API.
+const PullNever = apiv1.PullNever
+
+// PullIfNotPresent is a type alias for the v1 version of the k8s API.
+const PullIfNotPresent = apiv1.PullIfNotPresent
+
+// PodSecurityContextV1 is a type alias for the v1 version of the k8s API.
+type PodSecurityContextV1 = apiv1.PodSecurityContext
+
+// ResourceListV1 is a type alias for the v1 version of the k8s API.
+type ResourceListV1 = apiv1.ResourceList
+
+// ResourceNameV1 is a type alias for the v1 version of the k8s API.
+type ResourceNameV1 = apiv1.ResourceName
+
+// ResourceQuotaListV1 is a type alias for the v1 version of the k8s API.
+type ResourceQuotaListV1 = apiv1.ResourceQuotaList
+
+// ResourceQuotaV1 is a type alias for the v1 version of the k8s API.
+type ResourceQuotaV1 = apiv1.ResourceQuota
+
 // Resource is a type alias for a runtime.Object.
 type Resource k8sRuntime.Object
 
 // SecurityContextV1 is a type alias for the v1 version of the k8s API.
 type SecurityContextV1 = apiv1.SecurityContext
 
+// ServiceAccountListV1 is a type alias for the v1 version of the k8s API.
+type ServiceAccountListV1 = apiv1.ServiceAccountList
+
+// ServiceAccountV1 is a type alias for the v1 version of the k8s API.
+type ServiceAccountV1 = apiv1.ServiceAccount
+
+// ServiceListV1 is a type alias for the v1 version of the k8s API.
+type ServiceListV1 = apiv1.ServiceList
+
+// ServiceV1 is a type alias for the v1 version of the k8s API.
+type ServiceV1 = apiv1.Service
+
+// SubjectV1 is a type alias for the v1 version of the k8s rbac API.
+type SubjectV1 = rbacv1.Subject
+
 // StatefulSetListV1 is a type alias for the v1 version of the k8s apps API.
 type StatefulSetListV1 = appsv1.StatefulSetList
 
@@ -148,6 +262,38 @@ func IsSupportedGroupVersionKind(obj Resource) bool {
 	}
 }
 
+// IsRBACResourceType returns true if obj is a Role, ClusterRole or one of their bindings
+func IsRBACResourceType(obj Resource) bool {
+	switch obj.(type) {
+	case *RoleV1, *ClusterRoleV1, *RoleBindingV1, *ClusterRoleBindingV1:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsConfigMapType returns true if obj is of ConfigMapV1 type
+func IsConfigMapType(obj Resource) bool {
+	_, ok := obj.(*ConfigMapV1)
+	return ok
+}
+
+// IsExposureType returns true if obj is a Service or an Ingress
+func IsExposureType(obj Resource) bool {
+	switch obj.(type) {
+	case *ServiceV1, *IngressV1Beta1, *IngressExtensionsV1Beta1:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsServiceAccountType returns true if obj is of ServiceAccountV1 type
+func IsServiceAccountType(obj Resource) bool {
+	_, ok := obj.(*ServiceAccountV1)
+	return ok
+}
+
 // IsNamespaceType returns true if obj is of NamespaceV1 type
 func IsNamespaceType(obj Resource) bool {
 	switch obj.(type) {
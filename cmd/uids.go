@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// NobodyID is the user and group ID of nobody and nogroup. Many images and workloads run as it, so processes running
+// as it can access each other's files.
+const NobodyID = 65534
+
+// defaultIDRange is used when the config doesn't set the range of user and group IDs. It starts above the IDs of
+// users created on hosts and in images, and stops below nobody.
+var defaultIDRange = KubeauditConfigIDs{MinUID: 10000, MaxUID: 65533, MinGID: 10000, MaxGID: 65533}
+
+// idRange returns the range of user and group IDs from the config, with the defaults for the bounds it doesn't set.
+func idRange() KubeauditConfigIDs {
+	ids := defaultIDRange
+	configured := auditConfigSpec().IDs
+	if configured == nil {
+		return ids
+	}
+	if configured.MinUID != 0 {
+		ids.MinUID = configured.MinUID
+	}
+	if configured.MaxUID != 0 {
+		ids.MaxUID = configured.MaxUID
+	}
+	if configured.MinGID != 0 {
+		ids.MinGID = configured.MinGID
+	}
+	if configured.MaxGID != 0 {
+		ids.MaxGID = configured.MaxGID
+	}
+	return ids
+}
+
+// assignID picks an ID in the range for the resource. The ID is derived from the namespace and name of the resource
+// so that workloads fixed by autofix don't all share the same ID.
+func assignID(result *Result, min, max int64) int64 {
+	if max < min {
+		return min
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(result.Namespace + "/" + result.Name))
+	return min + int64(hash.Sum32())%(max-min+1)
+}
+
+func idMetadata(field string, id int64, source string, min, max int64) Metadata {
+	metadata := Metadata{
+		"Field": field,
+		"ID":    strconv.FormatInt(id, 10),
+		"Range": fmt.Sprintf("%d-%d", min, max),
+	}
+	if source != "" {
+		metadata["Source"] = source
+	}
+	return metadata
+}
+
+// checkID returns the occurrence for an ID which is root, nobody or out of the range, and false if the ID is fine.
+func checkID(field string, id int64, source string, min, max int64) (Occurrence, bool) {
+	occ := Occurrence{metadata: idMetadata(field, id, source, min, max)}
+	switch {
+	case id == 0:
+		occ.id, occ.kind = ErrorIDRoot, Error
+		occ.message = fmt.Sprintf("%s is set to 0 (root), please use an ID between %d and %d!", field, min, max)
+	case id == NobodyID:
+		occ.id, occ.kind = ErrorIDShared, Warn
+		occ.message = fmt.Sprintf("%s is set to %d (nobody) which is shared with other workloads, please use an ID between %d and %d", field, id, min, max)
+	case id < min || id > max:
+		occ.id, occ.kind = ErrorIDOutOfRange, Warn
+		occ.message = fmt.Sprintf("%s is set to %d which is out of the allowed range, please use an ID between %d and %d", field, id, min, max)
+	default:
+		return occ, false
+	}
+	return occ, true
+}
+
+// securityContextSource returns where the effective value of a security context field comes from.
+func securityContextSource(containerSet, podSet bool) string {
+	if containerSet {
+		return "container"
+	}
+	if podSet {
+		return "pod"
+	}
+	return ""
+}
+
+// containerIDOccurrences checks the effective runAsUser and runAsGroup of the container.
+func containerIDOccurrences(podSpec PodSpecV1, container ContainerV1, ids KubeauditConfigIDs) (occurrences []Occurrence) {
+	securityContext := effectiveSecurityContext(podSpec, container)
+	containerSecurityContext := container.SecurityContext
+	if containerSecurityContext == nil {
+		containerSecurityContext = &SecurityContextV1{}
+	}
+	podSecurityContext := podSpec.SecurityContext
+	if podSecurityContext == nil {
+		podSecurityContext = &PodSecurityContextV1{}
+	}
+
+	if securityContext.RunAsUser != nil {
+		source := securityContextSource(containerSecurityContext.RunAsUser != nil, podSecurityContext.RunAsUser != nil)
+		if occ, found := checkID("runAsUser", *securityContext.RunAsUser, source, ids.MinUID, ids.MaxUID); found {
+			if occ.id == ErrorIDRoot && securityContext.RunAsNonRoot != nil && *securityContext.RunAsNonRoot {
+				occ.id = ErrorRunAsNonRootContradiction
+				occ.message = "runAsUser is set to 0 (root) but runAsNonRoot is true, the container will not start!"
+			}
+			occurrences = append(occurrences, occ)
+		}
+	}
+
+	if securityContext.RunAsGroup != nil {
+		source := securityContextSource(containerSecurityContext.RunAsGroup != nil, podSecurityContext.RunAsGroup != nil)
+		if occ, found := checkID("runAsGroup", *securityContext.RunAsGroup, source, ids.MinGID, ids.MaxGID); found {
+			occurrences = append(occurrences, occ)
+		}
+	}
+	return occurrences
+}
+
+// podIDOccurrences checks the fsGroup and supplementalGroups, which can only be set for the whole pod.
+func podIDOccurrences(podSpec PodSpecV1, ids KubeauditConfigIDs) (occurrences []Occurrence) {
+	if podSpec.SecurityContext == nil {
+		return
+	}
+	if podSpec.SecurityContext.FSGroup != nil {
+		if occ, found := checkID("fsGroup", *podSpec.SecurityContext.FSGroup, "pod", ids.MinGID, ids.MaxGID); found {
+			occurrences = append(occurrences, occ)
+		}
+	}
+	for _, group := range podSpec.SecurityContext.SupplementalGroups {
+		if occ, found := checkID("supplementalGroups", group, "pod", ids.MinGID, ids.MaxGID); found {
+			occurrences = append(occurrences, occ)
+		}
+	}
+	return occurrences
+}
+
+// allowIDs turns the occurrences into allowed warnings if the override label is set.
+func allowIDs(occurrences []Occurrence, labelExists bool, reason string, container string) []Occurrence {
+	if !labelExists {
+		return occurrences
+	}
+	if len(occurrences) == 0 {
+		return []Occurrence{{
+			container: container,
+			id:        ErrorMisconfiguredKubeauditAllow,
+			kind:      Warn,
+			message:   "Allowed running with a user or group ID outside the policy, but all IDs follow it",
+			metadata:  Metadata{"Reason": prettifyReason(reason)},
+		}}
+	}
+	var allowed []Occurrence
+	for _, occ := range occurrences {
+		occ.metadata["Reason"] = prettifyReason(reason)
+		allowed = append(allowed, Occurrence{
+			container: container,
+			id:        ErrorIDAllowed,
+			kind:      Warn,
+			message:   "Allowed running with a user or group ID outside the policy",
+			metadata:  occ.metadata,
+		})
+	}
+	return allowed
+}
+
+func checkContainerIDs(podSpec PodSpecV1, container ContainerV1, result *Result) {
+	occurrences := containerIDOccurrences(podSpec, container, idRange())
+	for i := range occurrences {
+		occurrences[i].container = container.Name
+	}
+	labelExists, reason := getContainerOverrideLabelReason(result, container, "allow-uid-gid")
+	result.Occurrences = append(result.Occurrences, allowIDs(occurrences, labelExists, reason, container.Name)...)
+}
+
+func checkPodIDs(podSpec PodSpecV1, result *Result) {
+	occurrences := podIDOccurrences(podSpec, idRange())
+	labelExists, reason := getPodOverrideLabelReason(result, "allow-uid-gid")
+	// A misconfigured pod label is reported by the containers
+	if labelExists && len(occurrences) == 0 {
+		return
+	}
+	result.Occurrences = append(result.Occurrences, allowIDs(occurrences, labelExists, reason, "")...)
+}
+
+func auditIDs(resource Resource) (results []Result) {
+	podSpec, ok := podSpecOf(resource)
+	if !ok {
+		return
+	}
+	for _, container := range getContainers(resource) {
+		result, err, warn := newResultFromResource(resource)
+		if warn != nil {
+			log.Warn(warn)
+			return
+		}
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		checkContainerIDs(podSpec, container, result)
+		if len(result.Occurrences) > 0 {
+			results = append(results, *result)
+		}
+	}
+
+	result, err, warn := newResultFromResource(resource)
+	if warn != nil {
+		log.Warn(warn)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	checkPodIDs(podSpec, result)
+	if len(result.Occurrences) > 0 {
+		results = append(results, *result)
+	}
+	return
+}
+
+var uidsCmd = &cobra.Command{
+	Use:   "uids",
+	Short: "Audit the user and group IDs containers run as",
+	Long: fmt.Sprintf(`This command checks the effective runAsUser and runAsGroup of every
+container, taking the pod security context into account, and the fsGroup and
+supplementalGroups of every pod against the allowed range of IDs.
+
+An ERROR is generated when an ID is 0 (root), or when runAsUser is 0 while
+runAsNonRoot is true, which keeps the container from starting.
+A WARN is generated when an ID is out of the range, or when it is %d (nobody)
+which is shared by many workloads.
+
+The range is %d-%d for user and group IDs by default, and can be set in the
+config file.
+
+Example usage:
+kubeaudit uids`, NobodyID, defaultIDRange.MinUID, defaultIDRange.MaxUID),
+	Run: runAudit(auditIDs),
+}
+
+func init() {
+	RootCmd.AddCommand(uidsCmd)
+}
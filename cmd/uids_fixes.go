@@ -0,0 +1,53 @@
+package cmd
+
+// fixID replaces a user or group ID which doesn't follow the policy with one picked from the range for the resource.
+// Supplementary groups are left alone since removing one may take away access the workload needs.
+func fixID(result *Result, resource Resource, occurrence Occurrence) Resource {
+	podSpec, ok := podSpecOf(resource)
+	if !ok {
+		return resource
+	}
+	ids := idRange()
+	field := occurrence.metadata["Field"]
+
+	// Fields the container inherits from the pod are fixed in the pod security context when asked to
+	inPod := field == "fsGroup" ||
+		(autofixConfig.podSecurityContext && occurrence.metadata["Source"] != "container" && (field == "runAsUser" || field == "runAsGroup"))
+	if inPod {
+		if labelExists, _ := getPodOverrideLabelReason(result, "allow-uid-gid"); labelExists {
+			return resource
+		}
+		securityContext := podSpec.SecurityContext.DeepCopy()
+		if securityContext == nil {
+			securityContext = &PodSecurityContextV1{}
+		}
+		switch field {
+		case "runAsUser":
+			uid := assignID(result, ids.MinUID, ids.MaxUID)
+			securityContext.RunAsUser = &uid
+		case "runAsGroup":
+			gid := assignID(result, ids.MinGID, ids.MaxGID)
+			securityContext.RunAsGroup = &gid
+		case "fsGroup":
+			gid := assignID(result, ids.MinGID, ids.MaxGID)
+			securityContext.FSGroup = &gid
+		}
+		return setPodSecurityContext(resource, securityContext)
+	}
+
+	var containers []ContainerV1
+	for _, container := range getContainers(resource) {
+		if labelExists, _ := getContainerOverrideLabelReason(result, container, "allow-uid-gid"); occurrence.container == container.Name && !labelExists {
+			switch field {
+			case "runAsUser":
+				uid := assignID(result, ids.MinUID, ids.MaxUID)
+				container.SecurityContext.RunAsUser = &uid
+			case "runAsGroup":
+				gid := assignID(result, ids.MinGID, ids.MaxGID)
+				container.SecurityContext.RunAsGroup = &gid
+			}
+		}
+		containers = append(containers, container)
+	}
+	return setContainers(resource, containers)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// byContainerAndField indexes the occurrences by container and field, the pod level ones have no container.
+func byContainerAndField(result Result, occ Occurrence) string {
+	return occ.container + "/" + occ.metadata["Field"]
+}
+
+func TestIDsV1(t *testing.T) {
+	results := runAuditTest(t, "uids_v1.yml", auditIDs, []int{ErrorIDRoot, ErrorIDShared, ErrorIDOutOfRange, ErrorRunAsNonRootContradiction})
+	occurrences := indexOccurrences(results, byContainerAndField)
+
+	assert.Equal(t, ErrorIDRoot, occurrences["root/runAsUser"][0].id)
+	assert.Equal(t, "pod", occurrences["root/runAsUser"][0].metadata["Source"])
+	assert.Equal(t, ErrorRunAsNonRootContradiction, occurrences["contradiction/runAsUser"][0].id)
+	assert.Equal(t, ErrorIDOutOfRange, occurrences["low/runAsUser"][0].id)
+	assert.Equal(t, "container", occurrences["low/runAsUser"][0].metadata["Source"])
+	assert.Equal(t, ErrorIDRoot, occurrences["low/runAsGroup"][0].id)
+	assert.NotContains(t, occurrences, "fine/runAsUser")
+	assert.NotContains(t, occurrences, "root/runAsGroup")
+
+	assert.Equal(t, ErrorIDRoot, occurrences["/fsGroup"][0].id)
+	assert.Len(t, occurrences["/supplementalGroups"], 2)
+	assert.Equal(t, ErrorIDShared, occurrences["/supplementalGroups"][0].id)
+	assert.Equal(t, ErrorIDOutOfRange, occurrences["/supplementalGroups"][1].id)
+}
+
+func TestIDsNilV1(t *testing.T) {
+	runAuditTest(t, "uids_nil_v1.yml", auditIDs, []int{})
+}
+
+func TestIDsAllowedV1(t *testing.T) {
+	runAuditTest(t, "uids_allowed_v1.yml", auditIDs, []int{ErrorIDAllowed, ErrorMisconfiguredKubeauditAllow})
+}
+
+func TestIDsFromConfig(t *testing.T) {
+	rootConfig.auditConfig = "../configs/uids_from_config.yml"
+	defer func() { rootConfig.auditConfig = "" }()
+	assert.Equal(t, KubeauditConfigIDs{MinUID: 1000, MaxUID: 65533, MinGID: 10000, MaxGID: 65533}, idRange())
+	occurrences := indexOccurrences(runAuditTest(t, "uids_v1.yml", auditIDs, []int{ErrorIDRoot, ErrorIDShared, ErrorIDOutOfRange, ErrorRunAsNonRootContradiction}), byContainerAndField)
+	assert.NotContains(t, occurrences, "low/runAsUser")
+}
+
+func TestAssignID(t *testing.T) {
+	id := assignID(&Result{Namespace: "ns", Name: "name"}, 10000, 10009)
+	assert.True(t, id >= 10000 && id <= 10009)
+	assert.Equal(t, id, assignID(&Result{Namespace: "ns", Name: "name"}, 10000, 10009))
+	assert.Equal(t, int64(10000), assignID(&Result{Namespace: "ns", Name: "name"}, 10000, 10000))
+}
+
+func TestFixIDsV1(t *testing.T) {
+	assert, resources := FixTestSetupMultipleResources(t, "uids_v1.yml", auditIDs)
+	podSpec, _ := podSpecOf(resources[0])
+	// Supplementary groups are left alone
+	assert.Equal([]int64{20000, 65534, 500}, podSpec.SecurityContext.SupplementalGroups)
+	assert.True(*podSpec.SecurityContext.FSGroup >= 10000)
+	// The pod still runs as root, but every container overrides it
+	assert.Equal(int64(0), *podSpec.SecurityContext.RunAsUser)
+	for _, container := range getContainers(resources[0]) {
+		securityContext := effectiveSecurityContext(podSpec, container)
+		assert.True(*securityContext.RunAsUser >= 10000, container.Name)
+		assert.True(*securityContext.RunAsGroup >= 10000, container.Name)
+	}
+	assert.Len(indexOccurrences(getResults(resources, auditIDs), byContainerAndField)["/supplementalGroups"], 2)
+}
+
+func TestFixIDsPodSecurityContextV1(t *testing.T) {
+	autofixConfig.podSecurityContext = true
+	defer func() { autofixConfig.podSecurityContext = false }()
+	assert, resources := FixTestSetupMultipleResources(t, "uids_v1.yml", auditIDs)
+	podSpec, _ := podSpecOf(resources[0])
+	assert.True(*podSpec.SecurityContext.RunAsUser >= 10000)
+	for _, container := range getContainers(resources[0]) {
+		switch container.Name {
+		case "root", "contradiction":
+			assert.Nil(container.SecurityContext.RunAsUser)
+		case "low":
+			assert.True(*container.SecurityContext.RunAsUser >= 10000)
+			assert.True(*container.SecurityContext.RunAsGroup >= 10000)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"reflect"
+	"regexp"
 	"runtime"
 	"strings"
 	"sync"
@@ -29,14 +31,6 @@ func newFalse() *bool {
 	return new(bool)
 }
 
-func isInRootConfigNamespace(meta metav1.ObjectMeta) (valid bool) {
-	return isInNamespace(meta, rootConfig.namespace)
-}
-
-func isInNamespace(meta metav1.ObjectMeta, namespace string) (valid bool) {
-	return namespace == apiv1.NamespaceAll || namespace == meta.Namespace
-}
-
 func newResultFromResource(resource Resource) (*Result, error, error) {
 	result := &Result{}
 	switch kubeType := resource.(type) {
@@ -105,12 +99,58 @@ func newResultFromResource(resource Resource) (*Result, error, error) {
 		result.Labels = kubeType.Labels
 		result.Name = kubeType.Name
 		result.Namespace = kubeType.Namespace
+	case *RoleV1:
+		result.KubeType = "role"
+		result.Labels = kubeType.Labels
+		result.Name = kubeType.Name
+		result.Namespace = kubeType.Namespace
+	case *ClusterRoleV1:
+		result.KubeType = "clusterRole"
+		result.Labels = kubeType.Labels
+		result.Name = kubeType.Name
+	case *RoleBindingV1:
+		result.KubeType = "roleBinding"
+		result.Labels = kubeType.Labels
+		result.Name = kubeType.Name
+		result.Namespace = kubeType.Namespace
+	case *ClusterRoleBindingV1:
+		result.KubeType = "clusterRoleBinding"
+		result.Labels = kubeType.Labels
+		result.Name = kubeType.Name
+	case *ServiceAccountV1:
+		result.KubeType = "serviceAccount"
+		result.Labels = kubeType.Labels
+		result.Name = kubeType.Name
+		result.Namespace = kubeType.Namespace
+	case *ConfigMapV1:
+		result.KubeType = "configMap"
+		result.Labels = kubeType.Labels
+		result.Name = kubeType.Name
+		result.Namespace = kubeType.Namespace
+	case *ServiceV1:
+		result.KubeType = "service"
+		result.Labels = kubeType.Labels
+		result.Name = kubeType.Name
+		result.Namespace = kubeType.Namespace
+	case *IngressV1Beta1:
+		result.KubeType = "ingress"
+		result.Labels = kubeType.Labels
+		result.Name = kubeType.Name
+		result.Namespace = kubeType.Namespace
+	case *IngressExtensionsV1Beta1:
+		result.KubeType = "ingress"
+		result.Labels = kubeType.Labels
+		result.Name = kubeType.Name
+		result.Namespace = kubeType.Namespace
 	default:
 		if IsSupportedGroupVersionKind(resource) {
 			return nil, nil, fmt.Errorf("resource type %s not supported", resource.GetObjectKind().GroupVersionKind())
 		}
 		return nil, fmt.Errorf("resource type %s not supported", resource.GetObjectKind().GroupVersionKind()), nil
 	}
+	if meta, ok := resource.(metav1.Object); ok {
+		result.Cluster = meta.GetClusterName()
+	}
 	return result, nil, nil
 }
 
@@ -169,47 +209,397 @@ func newResultFromResourceWithServiceAccountInfo(resource Resource) (*Result, er
 		result.DSA = kubeType.Spec.Template.Spec.DeprecatedServiceAccount
 		result.SA = kubeType.Spec.Template.Spec.ServiceAccountName
 		result.Token = kubeType.Spec.Template.Spec.AutomountServiceAccountToken
-	case *NamespaceV1:
-		// We need to set this here so the audit function will ignore the namespace
+	case *NamespaceV1, *RoleV1, *ClusterRoleV1, *RoleBindingV1, *ClusterRoleBindingV1, *ServiceAccountV1,
+		*ConfigMapV1, *ServiceV1, *IngressV1Beta1, *IngressExtensionsV1Beta1:
+		// We need to set this here so the audit function will ignore resources without pods
 		result.Token = newFalse()
 	}
 
 	return result, nil, nil
 }
 
-func getKubeResources(clientset *kubernetes.Clientset) (resources []Resource) {
-	for _, resource := range getDaemonSets(clientset).Items {
-		if isInRootConfigNamespace(resource.ObjectMeta) {
-			resources = append(resources, resource.DeepCopyObject())
+// kindLister lists all objects of one kind from the cluster and returns them as resources. The group and resource
+// are used to check whether the current user is allowed to list the kind before trying.
+type kindLister struct {
+	kind       string
+	group      string
+	resource   string
+	namespaced bool
+	// ownerOnly kinds aren't audited, they are only needed to roll Pods up to their controllers. Failing to read
+	// them doesn't make the audit incomplete.
+	ownerOnly bool
+	// related kinds are needed to analyse the audited resources, such as the RBAC resources resolving the permissions
+	// of service accounts, so they aren't filtered when listed.
+	related bool
+	list    func(ctx context.Context, clientset kubernetes.Interface, namespace string) ([]Resource, error)
+}
+
+var namespaceLister = kindLister{
+	kind: "Namespace", resource: "namespaces",
+	list: func(ctx context.Context, clientset kubernetes.Interface, _ string) (resources []Resource, err error) {
+		list, err := getNamespaces(ctx, clientset)
+		for i := range list.Items {
+			if isAuditedNamespace(list.Items[i].Name) {
+				resources = append(resources, &list.Items[i])
+			}
 		}
+		return resources, err
+	},
+}
+
+var workloadListers = []kindLister{
+	{
+		kind: "CronJob", group: "batch", resource: "cronjobs", namespaced: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getCronJobs(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+	{
+		kind: "DaemonSet", group: "apps", resource: "daemonsets", namespaced: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getDaemonSets(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+	{
+		kind: "Deployment", group: "apps", resource: "deployments", namespaced: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getDeployments(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+	{
+		kind: "Pod", resource: "pods", namespaced: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getPods(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+	{
+		kind: "ReplicationController", resource: "replicationcontrollers", namespaced: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getReplicationControllers(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+	{
+		kind: "StatefulSet", group: "apps", resource: "statefulsets", namespaced: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getStatefulSets(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+}
+
+var ownerListers = []kindLister{
+	{
+		kind: "ReplicaSet", group: "apps", resource: "replicasets", namespaced: true, ownerOnly: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getReplicaSets(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+	{
+		kind: "Job", group: "batch", resource: "jobs", namespaced: true, ownerOnly: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getJobs(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+}
+
+// configMapLister is only used by the secrets audit, see auditsConfigMaps.
+var configMapLister = kindLister{
+	kind: "ConfigMap", resource: "configmaps", namespaced: true,
+	list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+		list, err := getConfigMaps(ctx, clientset, namespace)
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+		return resources, err
+	},
+}
+
+// serviceListers are only used by the services audit, see auditsServices.
+var serviceListers = []kindLister{
+	{
+		kind: "Service", resource: "services", namespaced: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getServices(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+	{
+		kind: "Ingress", group: "networking.k8s.io", resource: "ingresses", namespaced: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getIngresses(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+}
+
+// rbacListers are only used by the audits which need RBAC resources, see auditsRBAC.
+var rbacListers = []kindLister{
+	{
+		kind: "ServiceAccount", resource: "serviceaccounts", namespaced: true, related: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getServiceAccounts(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+	{
+		kind: "Role", group: "rbac.authorization.k8s.io", resource: "roles", namespaced: true, related: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getRoles(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+	{
+		kind: "RoleBinding", group: "rbac.authorization.k8s.io", resource: "rolebindings", namespaced: true, related: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, namespace string) (resources []Resource, err error) {
+			list, err := getRoleBindings(ctx, clientset, namespace)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+	{
+		kind: "ClusterRole", group: "rbac.authorization.k8s.io", resource: "clusterroles", related: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, _ string) (resources []Resource, err error) {
+			list, err := getClusterRoles(ctx, clientset)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+	{
+		kind: "ClusterRoleBinding", group: "rbac.authorization.k8s.io", resource: "clusterrolebindings", related: true,
+		list: func(ctx context.Context, clientset kubernetes.Interface, _ string) (resources []Resource, err error) {
+			list, err := getClusterRoleBindings(ctx, clientset)
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
+			return resources, err
+		},
+	},
+}
+
+// listKind checks that the kind may be listed in each audited namespace and lists it. When a namespaced kind can't
+// be listed across all namespaces it falls back to listing it namespace by namespace, so a service account which is
+// only bound in some namespaces still audits those. Everything that can't be read is recorded in scanCoverage.
+func listKind(ctx context.Context, clientset kubernetes.Interface, cluster string, lister kindLister, namespaces []string) ([]Resource, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	scopes := []string{apiv1.NamespaceAll}
+	if lister.namespaced {
+		scopes = auditedNamespaceScopes()
 	}
-	for _, resource := range getDeployments(clientset).Items {
-		if isInRootConfigNamespace(resource.ObjectMeta) {
-			resources = append(resources, resource.DeepCopyObject())
+
+	var resources []Resource
+	for _, scope := range scopes {
+		scopeResources, err := listKindInScope(ctx, clientset, cluster, lister, scope, namespaces)
+		if err != nil {
+			return nil, err
 		}
+		resources = append(resources, scopeResources...)
 	}
-	for _, resource := range getPods(clientset).Items {
-		if isInRootConfigNamespace(resource.ObjectMeta) {
-			resources = append(resources, resource.DeepCopyObject())
+	if lister.ownerOnly || lister.related || !lister.namespaced {
+		return resources, nil
+	}
+	// Listing across all namespaces can't leave out excluded namespaces
+	return filterResources(resources), nil
+}
+
+func listKindInScope(ctx context.Context, clientset kubernetes.Interface, cluster string, lister kindLister, scope string, namespaces []string) ([]Resource, error) {
+	addGap := func(namespace, reason string) {
+		if lister.ownerOnly {
+			log.Debugf("Unable to read %s resources, Pods they own are reported individually: %s", lister.kind, reason)
+			return
+		}
+		scanCoverage.addGap(cluster, lister.kind, namespace, reason)
+	}
+
+	allowed, reason := canList(clientset, lister.group, lister.resource, scope)
+	if allowed {
+		resources, err := lister.list(ctx, clientset, scope)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			addGap(scope, err.Error())
+			return nil, nil
+		}
+		return resources, nil
+	}
+
+	if !lister.namespaced || scope != apiv1.NamespaceAll || len(namespaces) == 0 {
+		addGap(scope, reason)
+		return nil, nil
+	}
+
+	var resources []Resource
+	for _, namespace := range namespaces {
+		if allowed, reason := canList(clientset, lister.group, lister.resource, namespace); !allowed {
+			addGap(namespace, reason)
+			continue
 		}
+		namespaceResources, err := lister.list(ctx, clientset, namespace)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			addGap(namespace, err.Error())
+			continue
+		}
+		resources = append(resources, namespaceResources...)
 	}
-	for _, resource := range getReplicationControllers(clientset).Items {
-		if isInRootConfigNamespace(resource.ObjectMeta) {
-			resources = append(resources, resource.DeepCopyObject())
+	return resources, nil
+}
+
+// kubeListers returns the listers of the kinds the current command needs besides namespaces.
+func kubeListers() (listers []kindLister) {
+	for _, lister := range workloadListers {
+		if isAuditedKind(lister.kind) {
+			listers = append(listers, lister)
 		}
 	}
-	for _, resource := range getStatefulSets(clientset).Items {
-		if isInRootConfigNamespace(resource.ObjectMeta) {
-			resources = append(resources, resource.DeepCopyObject())
+	if auditsRBAC {
+		listers = append(listers, rbacListers...)
+	}
+	if auditsConfigMaps && isAuditedKind("ConfigMap") {
+		listers = append(listers, configMapLister)
+	}
+	if auditsServices {
+		for _, lister := range serviceListers {
+			if isAuditedKind(lister.kind) {
+				listers = append(listers, lister)
+			}
 		}
 	}
-	for _, resource := range getNamespaces(clientset).Items {
-		if isInRootConfigNamespace(resource.ObjectMeta) {
-			resources = append(resources, resource.DeepCopyObject())
+	return append(listers, ownerListers...)
+}
+
+// listKubeResources lists the namespaces and then every kind of the listers in parallel. Kinds which can't be read
+// are recorded in scanCoverage instead of failing the whole audit; only a cancelled context aborts the listing. When
+// several clusters are audited every resource is tagged with the name of its cluster.
+func listKubeResources(ctx context.Context, clientset kubernetes.Interface, cluster string, listers []kindLister) ([]Resource, error) {
+	namespaceResources, err := listKind(ctx, clientset, cluster, namespaceLister, nil)
+	if err != nil {
+		return nil, err
+	}
+	var namespaces []string
+	for _, resource := range namespaceResources {
+		namespaces = append(namespaces, getNamespaceName(resource))
+	}
+
+	resourcesPerKind := make([][]Resource, len(listers))
+	errs := make([]error, len(listers))
+	var wg sync.WaitGroup
+	for i, lister := range listers {
+		wg.Add(1)
+		go func(i int, lister kindLister) {
+			defer wg.Done()
+			resourcesPerKind[i], errs[i] = listKind(ctx, clientset, cluster, lister, namespaces)
+		}(i, lister)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
 		}
 	}
+	resources := namespaceResources
+	for _, kindResources := range resourcesPerKind {
+		resources = append(resources, kindResources...)
+	}
+	setClusterName(resources, cluster)
+	return resources, nil
+}
 
-	return
+// getKubeResources lists the resources of a cluster and returns the ones to audit.
+func getKubeResources(ctx context.Context, clientset kubernetes.Interface, cluster string) ([]Resource, error) {
+	resources, err := listKubeResources(ctx, clientset, cluster, kubeListers())
+	if err != nil {
+		return nil, err
+	}
+	return auditedResources(resources), nil
+}
+
+// auditedResources applies the filters to resources read from a cluster or a snapshot and rolls Pods up to their
+// controllers. The RBAC resources are indexed in scanPrivileges and the workloads in scanWorkloads. RBAC resources,
+// Services and Ingresses are only audited by the commands which need them.
+func auditedResources(listed []Resource) []Resource {
+	var resources, owners, rbacResources, exposures, configMaps []Resource
+	for _, resource := range listed {
+		switch resource.(type) {
+		case *ReplicaSetV1, *JobV1:
+			owners = append(owners, resource)
+		case *RoleV1, *ClusterRoleV1, *RoleBindingV1, *ClusterRoleBindingV1, *ServiceAccountV1:
+			rbacResources = append(rbacResources, resource)
+		case *ServiceV1, *IngressV1Beta1, *IngressExtensionsV1Beta1:
+			exposures = append(exposures, resource)
+		case *ConfigMapV1:
+			configMaps = append(configMaps, resource)
+		case *NetworkPolicyV1, *LimitRangeV1, *ResourceQuotaV1:
+			// Network policies, limit ranges and resource quotas are read by the audits of their namespace
+		default:
+			resources = append(resources, resource)
+		}
+	}
+	scanPrivileges.index(rbacResources)
+	scanWorkloads.index(listed)
+	resources = scanOwnership.rollUp(filterResources(resources), owners)
+	if auditsRBAC {
+		resources = append(resources, filterResources(rbacResources)...)
+	}
+	if auditsServices {
+		resources = append(resources, filterResources(exposures)...)
+	}
+	if auditsConfigMaps {
+		resources = append(resources, filterResources(configMaps)...)
+	}
+	return resources
 }
 
 func writeManifestFile(decoded []byte, filename string, toAppend bool) error {
@@ -228,6 +618,20 @@ func writeManifestFile(decoded []byte, filename string, toAppend bool) error {
 	return nil
 }
 
+// manifestSeparator matches the separators of the documents of a manifest, which start a line. Dashes in indented
+// values, such as the PEM blocks of ConfigMaps, don't separate documents.
+var manifestSeparator = regexp.MustCompile(`(?m)^---`)
+
+// splitManifest splits a manifest into its documents.
+func splitManifest(buf []byte) (documents [][]byte) {
+	start := 0
+	for _, loc := range manifestSeparator.FindAllIndex(buf, -1) {
+		documents = append(documents, buf[start:loc[0]])
+		start = loc[1]
+	}
+	return append(documents, buf[start:])
+}
+
 func getKubeResourcesManifest(filename string) (decoded []Resource, err error) {
 	buf, err := ioutil.ReadFile(filename)
 
@@ -235,14 +639,15 @@ func getKubeResourcesManifest(filename string) (decoded []Resource, err error) {
 		log.Error("File not found")
 		return
 	}
-	bufSlice := bytes.Split(buf, []byte("---"))
+	bufSlice := splitManifest(buf)
 
 	decoder := scheme.Codecs.UniversalDeserializer()
 
 	for _, b := range bufSlice {
 		obj, _, err := decoder.Decode(b, nil, nil)
 		if err == nil && obj != nil {
-			if !IsSupportedResourceType(obj) {
+			if !IsSupportedResourceType(obj) && !IsRBACResourceType(obj) && !IsServiceAccountType(obj) && !IsConfigMapType(obj) &&
+				!IsExposureType(obj) {
 				decoded = append(decoded, obj)
 				log.Warnf("Skipping unsupported resource type %s", obj.GetObjectKind().GroupVersionKind())
 				continue
@@ -258,15 +663,60 @@ func getKubeResourcesManifest(filename string) (decoded []Resource, err error) {
 	return
 }
 
-func getResources() (resources []Resource, err error) {
+func getResources(ctx context.Context) (resources []Resource, err error) {
+	if rootConfig.snapshot != "" {
+		resources, err = loadSnapshot(rootConfig.snapshot)
+		if err != nil {
+			return nil, err
+		}
+		return auditedResources(resources), nil
+	}
 	if rootConfig.manifest != "" {
 		resources, err = getKubeResourcesManifest(rootConfig.manifest)
-	} else {
-		if kube, err := kubeClient(); err == nil {
-			resources = getKubeResources(kube)
+		scanPrivileges.index(resources)
+		scanWorkloads.index(resources)
+		return scanOwnership.rollUp(filterResources(resources), resources), err
+	}
+	contexts, err := kubeContexts()
+	if err != nil {
+		return nil, err
+	}
+	if len(contexts) > 0 {
+		return getClustersResources(ctx, contexts)
+	}
+	kube, err := kubeClient()
+	if err != nil {
+		return nil, err
+	}
+	return getKubeResources(ctx, kube, "")
+}
+
+// newAuditContext returns a context which is cancelled on the first SIGINT or once --timeout has passed. A second
+// SIGINT terminates kubeaudit immediately.
+func newAuditContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if rootConfig.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, rootConfig.timeout)
+		cancelAudit := cancel
+		cancel = func() {
+			cancelTimeout()
+			cancelAudit()
 		}
 	}
-	return
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupts:
+			log.Warn("Interrupted, cancelling the audit")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(interrupts)
+	}()
+	return ctx, cancel
 }
 
 func setFormatter() {
@@ -278,48 +728,89 @@ func setFormatter() {
 func checkParams(auditFunc interface{}) (err error) {
 	switch auditFunc.(type) {
 	case (func(image imgFlags, resource Resource) (results []Result)):
-		if len(imgConfig.img) == 0 {
-			return errors.New("Empty image name. Are you missing the image flag?")
+		if err := imgConfig.parseImageFlag(); err != nil {
+			return err
 		}
-		imgConfig.splitImageString()
-		if len(imgConfig.tag) == 0 {
+		if len(imgConfig.img) != 0 && len(imgConfig.ref.Tag) == 0 && len(imgConfig.ref.Digest) == 0 {
 			return errors.New("Empty image tag. Are you missing the image tag?")
 		}
+	case (func(vulns vulnFlags, resource Resource) (results []Result)):
+		if err := vulnConfig.loadReports(); err != nil {
+			return err
+		}
+	case (func(sigs sigFlags, resource Resource) (results []Result)):
+		if err := sigConfig.loadPolicy(); err != nil {
+			return err
+		}
+	case (func(flags inspectFlags, resource Resource) (results []Result)):
+		if err := inspectConfig.loadSources(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+func workerCount(jobs int) int {
+	workers := rootConfig.parallelism
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > jobs {
+		workers = jobs
+	}
+	return workers
+}
+
+func runAuditFunction(auditFunc interface{}, resource Resource) []Result {
+	switch f := auditFunc.(type) {
+	case func(resource Resource) (results []Result):
+		return f(resource)
+	case func(image imgFlags, resource Resource) (results []Result):
+		return f(imgConfig, resource)
+	case func(limits limitFlags, resource Resource) (results []Result):
+		return f(limitConfig, resource)
+	case func(vulns vulnFlags, resource Resource) (results []Result):
+		return f(vulnConfig, resource)
+	case func(sigs sigFlags, resource Resource) (results []Result):
+		return f(sigConfig, resource)
+	case func(flags inspectFlags, resource Resource) (results []Result):
+		return f(inspectConfig, resource)
+	default:
+		name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+		log.Fatal("Invalid audit function provided: ", name)
+	}
+	return nil
+}
+
+// getResults audits the resources concurrently using a bounded pool of workers. Every worker writes into the slot
+// of the resource it audited so no synchronization is needed besides waiting for the pool to drain, and the results
+// are sorted afterwards so the output does not depend on scheduling.
 func getResults(resources []Resource, auditFunc interface{}) []Result {
+	resultsPerResource := make([][]Result, len(resources))
+	indexes := make(chan int)
+
 	var wg sync.WaitGroup
-	wg.Add(len(resources))
-	resultsChannel := make(chan []Result, 1)
-	go func() { resultsChannel <- []Result{} }()
-
-	for _, resource := range resources {
-		results := <-resultsChannel
-		go func(resource Resource) {
-			switch f := auditFunc.(type) {
-			case func(resource Resource) (results []Result):
-				resultsChannel <- append(results, f(resource)...)
-			case func(image imgFlags, resource Resource) (results []Result):
-				resultsChannel <- append(results, f(imgConfig, resource)...)
-			case func(limits limitFlags, resource Resource) (results []Result):
-				resultsChannel <- append(results, f(limitConfig, resource)...)
-			default:
-				name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
-				log.Fatal("Invalid audit function provided: ", name)
+	for i := 0; i < workerCount(len(resources)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				resultsPerResource[index] = runAuditFunction(auditFunc, resources[index])
 			}
-			wg.Done()
-		}(resource)
+		}()
 	}
 
+	for index := range resources {
+		indexes <- index
+	}
+	close(indexes)
 	wg.Wait()
-	close(resultsChannel)
 
 	var results []Result
-	for _, result := range <-resultsChannel {
-		results = append(results, result)
+	for _, resourceResults := range resultsPerResource {
+		results = append(results, resourceResults...)
 	}
+	sortResults(results)
 	return results
 }
 
@@ -330,16 +821,30 @@ func runAudit(auditFunc interface{}) func(cmd *cobra.Command, args []string) {
 			log.Error(err)
 		}
 		setFormatter()
-		resources, err := getResources()
+		ctx, cancel := newAuditContext()
+		defer cancel()
+		scanCoverage.reset()
+		scanOwnership.reset()
+		scanPrivileges.reset()
+		scanWorkloads.reset()
+		resources, err := getResources(ctx)
 		if err != nil {
 			log.Error("getResources failed")
-			log.Error(err)
-			return
+			abortIncompleteScan(err)
 		}
 		results := getResults(resources, auditFunc)
+		if ctx.Err() != nil {
+			abortIncompleteScan(ctx.Err())
+		}
+		scanOwnership.annotate(results)
 		for _, result := range results {
 			result.Print()
 		}
+		printClusterSummary(resources, results)
+		scanCoverage.Print()
+		if !scanCoverage.Complete() {
+			os.Exit(ExitCodeIncompleteScan)
+		}
 	}
 }
 
@@ -361,14 +866,12 @@ func prettifyReason(reason string) string {
 	return reason
 }
 
+// shouldAuditCSC returns true unless the container inherits runAsNonRoot from the pod security context.
 func shouldAuditCSC(podSpec PodSpecV1, container ContainerV1) bool {
 	if container.SecurityContext != nil && container.SecurityContext.RunAsNonRoot != nil {
 		return true
 	}
-	if podSpec.SecurityContext == nil || podSpec.SecurityContext.RunAsNonRoot == nil {
-		return true
-	}
-	return false
+	return effectiveSecurityContext(podSpec, container).RunAsNonRoot == nil
 }
 
 func getContainerOverrideLabelReason(result *Result, container ContainerV1, overrideLabel string) (bool, string) {
@@ -385,6 +888,26 @@ func getPodOverrideLabelReason(result *Result, overrideLabel string) (bool, stri
 	if reason := result.Labels[podOverrideLabel]; reason != "" {
 		return true, reason
 	}
+	return getConfigOverrideReason(overrideLabel)
+}
+
+func getRBACOverrideLabelReason(result *Result, overrideLabel string) (bool, string) {
+	rbacOverrideLabel := "audit.kubernetes.io/rbac/" + overrideLabel
+	if reason := result.Labels[rbacOverrideLabel]; reason != "" {
+		return true, reason
+	}
+	return getConfigOverrideReason(overrideLabel)
+}
+
+func getServiceOverrideLabelReason(result *Result, overrideLabel string) (bool, string) {
+	serviceOverrideLabel := "audit.kubernetes.io/service/" + overrideLabel
+	if reason := result.Labels[serviceOverrideLabel]; reason != "" {
+		return true, reason
+	}
+	return getConfigOverrideReason(overrideLabel)
+}
+
+func getConfigOverrideReason(overrideLabel string) (bool, string) {
 	if rootConfig.auditConfig != "" {
 		var kubeauditConfig = &KubeauditConfig{}
 
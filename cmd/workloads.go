@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"sort"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// workloadScope is the cluster and namespace of workloads.
+type workloadScope struct {
+	cluster   string
+	namespace string
+}
+
+// indexedWorkload is a workload, or a bare pod, with the labels and spec of the pods it runs.
+type indexedWorkload struct {
+	kubeType string
+	name     string
+	labels   map[string]string
+	podSpec  PodSpecV1
+}
+
+func (w indexedWorkload) String() string {
+	return w.kubeType + "/" + w.name
+}
+
+// WorkloadIndex indexes the pods run by every workload which could be read regardless of the filters, so that
+// Services and NetworkPolicies are matched with every pod they select. Pods with a controller are left out since
+// they're indexed through it. It is safe for concurrent use.
+type WorkloadIndex struct {
+	mu        sync.Mutex
+	workloads map[workloadScope][]indexedWorkload
+}
+
+var scanWorkloads WorkloadIndex
+
+func (w *WorkloadIndex) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.workloads = nil
+}
+
+func (w *WorkloadIndex) index(resources []Resource) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.workloads == nil {
+		w.workloads = map[workloadScope][]indexedWorkload{}
+	}
+	for _, resource := range resources {
+		podSpec, ok := podSpecOf(resource)
+		if !ok {
+			continue
+		}
+		meta, ok := resource.(metav1.Object)
+		if !ok || metav1.GetControllerOf(meta) != nil {
+			continue
+		}
+		result, err, warn := newResultFromResource(resource)
+		if err != nil || warn != nil {
+			continue
+		}
+		podLabels := result.Labels
+		// The labels of a CronJob result are the ones of its Jobs, not of their pods
+		if cronJob, ok := resource.(*CronJobV1Beta1); ok {
+			podLabels = cronJob.Spec.JobTemplate.Spec.Template.Labels
+		}
+		scope := workloadScope{cluster: result.Cluster, namespace: result.Namespace}
+		w.workloads[scope] = append(w.workloads[scope], indexedWorkload{
+			kubeType: result.KubeType,
+			name:     result.Name,
+			labels:   podLabels,
+			podSpec:  podSpec,
+		})
+	}
+}
+
+// inNamespace returns the workloads of a namespace sorted by type and name.
+func (w *WorkloadIndex) inNamespace(cluster, namespace string) []indexedWorkload {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	workloads := append([]indexedWorkload{}, w.workloads[workloadScope{cluster: cluster, namespace: namespace}]...)
+	sort.Slice(workloads, func(i, j int) bool { return workloads[i].String() < workloads[j].String() })
+	return workloads
+}
+
+// scopes returns the clusters and namespaces which have workloads, sorted.
+func (w *WorkloadIndex) scopes() (scopes []workloadScope) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for scope := range w.workloads {
+		scopes = append(scopes, scope)
+	}
+	sort.Slice(scopes, func(i, j int) bool {
+		if scopes[i].cluster != scopes[j].cluster {
+			return scopes[i].cluster < scopes[j].cluster
+		}
+		return scopes[i].namespace < scopes[j].namespace
+	})
+	return scopes
+}